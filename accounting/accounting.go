@@ -1,13 +1,257 @@
 package accounting
 
 import (
+	"context"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+
+	"github.com/gaozhengxin/bridgeAccounting/alert"
 	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
 )
 
+const summaryInterval = 10 * time.Minute
+
 var (
 	dbAPI mongodb.AccountingAPI
 )
 
-func StartAccounting() {
+// StartAccounting start accounting worker, until ctx is done
+func StartAccounting(ctx context.Context) {
 	dbAPI = mongodb.NewAccountingAPI()
+	for {
+		if err := makeSummary(params.GetScanConfig()); err != nil {
+			log.Warn("make summary failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("stop accounting")
+			return
+		case <-time.After(summaryInterval):
+		}
+	}
+}
+
+// make summary of the next sequence, which accumulates the confirmed swap events
+// in blocks from the end heights of the previous summary to the confirmed heights of chains
+func makeSummary(cfg *params.ScanConfig) error {
+	var sequence int64
+	if collInfo, err := dbAPI.GetSummaryCollectionInfo(); err == nil {
+		sequence = collInfo.LatestSequence
+	}
+	if sequence > 0 {
+		if err := remakeDirtySummaries(cfg, sequence); err != nil {
+			return err
+		}
+	}
+	startHeights := make(map[string]int64)
+	if sequence > 0 {
+		prevInfo, err := dbAPI.GetSummaryInfo(sequence)
+		if err != nil {
+			return err
+		}
+		for chain, height := range prevInfo.EndHeights {
+			startHeights[chain] = height
+		}
+	}
+
+	endHeights := make(map[string]int64)
+	advanced := false
+	for _, chainCfg := range cfg.Chains {
+		syncInfo, err := dbAPI.GetSyncInfo(chainCfg.Name)
+		if err != nil {
+			continue
+		}
+		start, exist := startHeights[chainCfg.Name]
+		if !exist {
+			start = syncInfo.StartHeight
+			startHeights[chainCfg.Name] = start
+		}
+		end := syncInfo.ConfirmedHeight + 1
+		if end < start {
+			end = start
+		}
+		endHeights[chainCfg.Name] = end
+		advanced = advanced || end > start
+	}
+	if !advanced {
+		return nil
+	}
+
+	sequence++
+	for _, tokenCfgs := range groupTokensByPairID(cfg.Tokens) {
+		summary, err := makePairSummary(sequence, tokenCfgs, startHeights, endHeights)
+		if err != nil {
+			return err
+		}
+		if err = dbAPI.AddSummary(tokenCfgs[0], summary); err != nil {
+			return err
+		}
+		alert.OnSummary(tokenCfgs[0].PairID, summary)
+	}
+	summaryInfo := &mongodb.SummaryInfo{
+		Sequence:     sequence,
+		Tag:          time.Now().UTC().Format(time.RFC3339),
+		StartHeights: startHeights,
+		EndHeights:   endHeights,
+	}
+	if err := dbAPI.AddSummaryInfo(summaryInfo); err != nil {
+		return err
+	}
+	if err := dbAPI.UpdateSummaryCollectionInfo(sequence); err != nil {
+		return err
+	}
+	log.Info("make summary success", "sequence", sequence, "start", startHeights, "end", endHeights)
+	return nil
+}
+
+// make the summaries again from the earliest one whose range contains the dirty height of chain,
+// as swap events are recorded or changed in the summarized blocks later by backfill, gap healing,
+// rescan and matching. the dirty heights are cleared before, and marked again if it fails.
+func remakeDirtySummaries(cfg *params.ScanConfig, latest int64) (err error) {
+	dirtyHeights := make(map[string]int64)
+	for _, chainCfg := range cfg.Chains {
+		syncInfo, err := dbAPI.GetSyncInfo(chainCfg.Name)
+		if err != nil || syncInfo.DirtyHeight == 0 {
+			continue
+		}
+		if err = dbAPI.ClearDirtyHeight(chainCfg.Name, syncInfo.DirtyHeight); err != nil {
+			return err
+		}
+		dirtyHeights[chainCfg.Name] = syncInfo.DirtyHeight
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		for chain, height := range dirtyHeights {
+			if markErr := dbAPI.MarkDirtyHeight(chain, height); markErr != nil {
+				log.Warn("mark dirty height failed", "chain", chain, "height", height, "err", markErr)
+			}
+		}
+	}()
+
+	// the summaries are cumulative, so the ones after the earliest dirty one are all dirty
+	var dirtyInfos []*mongodb.SummaryInfo
+	for sequence := latest; sequence > 0; sequence-- {
+		info, err := dbAPI.GetSummaryInfo(sequence)
+		if err != nil {
+			return err
+		}
+		if !containsDirtyHeight(info, dirtyHeights) {
+			break
+		}
+		dirtyInfos = append([]*mongodb.SummaryInfo{info}, dirtyInfos...)
+	}
+	if len(dirtyInfos) == 0 {
+		return nil
+	}
+	for _, info := range dirtyInfos {
+		for _, tokenCfgs := range groupTokensByPairID(cfg.Tokens) {
+			summary, err := makePairSummary(info.Sequence, tokenCfgs, info.StartHeights, info.EndHeights)
+			if err != nil {
+				return err
+			}
+			if err = dbAPI.AddSummary(tokenCfgs[0], summary); err != nil {
+				return err
+			}
+		}
+	}
+	log.Info("remake dirty summaries success", "from", dirtyInfos[0].Sequence, "to", latest, "dirty", dirtyHeights)
+	return nil
+}
+
+func containsDirtyHeight(info *mongodb.SummaryInfo, dirtyHeights map[string]int64) bool {
+	for chain, height := range dirtyHeights {
+		if end, exist := info.EndHeights[chain]; exist && height < end {
+			return true
+		}
+	}
+	return false
+}
+
+// group tokens by pair ID in config order, the tokens of pair share one summary
+func groupTokensByPairID(tokenCfgs []*params.TokenConfig) [][]*params.TokenConfig {
+	var groups [][]*params.TokenConfig
+	index := make(map[string]int)
+	for _, tokenCfg := range tokenCfgs {
+		i, exist := index[tokenCfg.PairID]
+		if !exist {
+			i = len(groups)
+			index[tokenCfg.PairID] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], tokenCfg)
+	}
+	return groups
+}
+
+// accumulate the confirmed swap events of pair onto the previous summary,
+// the bridge fee is earned by mints and the gas fee is paid by mints and redeems of MPC,
+// the balances of mpc addresses are changed by deposits to them and redeems from them
+func makePairSummary(sequence int64, tokenCfgs []*params.TokenConfig, startHeights, endHeights map[string]int64) (*mongodb.Summary, error) {
+	summary := &mongodb.Summary{Sequence: sequence, AccMPCGasFee: make(map[string]float64), AccMPCBalance: make(map[string]float64)}
+	if prev, err := dbAPI.GetSummary(tokenCfgs[0], sequence-1); err == nil {
+		summary.AccDeposit = prev.AccDeposit
+		summary.AccMint = prev.AccMint
+		summary.AccBurn = prev.AccBurn
+		summary.AccRedeemed = prev.AccRedeemed
+		summary.AccBridgeFee = prev.AccBridgeFee
+		for chain, gasFee := range prev.AccMPCGasFee {
+			summary.AccMPCGasFee[chain] = gasFee
+		}
+		for address, balance := range prev.AccMPCBalance {
+			summary.AccMPCBalance[address] = balance
+		}
+	}
+	for _, tokenCfg := range tokenCfgs {
+		start, end := startHeights[tokenCfg.Chain], endHeights[tokenCfg.Chain]
+		if start >= end {
+			continue
+		}
+		// the events of pair share the tables, so the events of side are summed in the range of its chain
+		accs := map[mongodb.TxType]*float64{
+			mongodb.TypeMint: &summary.AccMint,
+			mongodb.TypeBurn: &summary.AccBurn,
+		}
+		if tokenCfg.IsSrcToken {
+			accs = map[mongodb.TxType]*float64{
+				mongodb.TypeDeposit:  &summary.AccDeposit,
+				mongodb.TypeRedeemed: &summary.AccRedeemed,
+			}
+		}
+		for txtype, acc := range accs {
+			iter, err := dbAPI.GetConfirmedSwapEventsByBlockRange(txtype, tokenCfg, start, end)
+			if err != nil {
+				return nil, err
+			}
+			for {
+				var event mongodb.SwapEvent
+				if !iter.Next(&event) {
+					break
+				}
+				*acc += event.FAmount
+				switch txtype {
+				case mongodb.TypeMint:
+					summary.AccBridgeFee += event.BridgeFee
+					summary.AccMPCGasFee[tokenCfg.Chain] += event.FTxFee
+				case mongodb.TypeDeposit:
+					if event.MPCAddress != "" {
+						summary.AccMPCBalance[event.MPCAddress] += event.FAmount
+					}
+				case mongodb.TypeRedeemed:
+					summary.AccMPCGasFee[tokenCfg.Chain] += event.FTxFee
+					if event.MPCAddress != "" {
+						summary.AccMPCBalance[event.MPCAddress] -= event.FAmount
+					}
+				}
+			}
+			// the summary of partial events is wrong, abort it
+			if err = iter.Close(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return summary, nil
 }
@@ -0,0 +1,226 @@
+package accounting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+var errTestNotFound = errors.New("not found")
+
+// testAccountingAPI in-memory accounting api, the swap events of pair share the tables
+type testAccountingAPI struct {
+	mongodb.AccountingAPI
+
+	syncInfos    map[string]*mongodb.SyncInfo
+	events       map[mongodb.TxType][]*mongodb.SwapEvent
+	summaries    map[int64]*mongodb.Summary
+	summaryInfos map[int64]*mongodb.SummaryInfo
+	latest       int64
+	iterErr      error // the error of iterating swap events
+}
+
+func newTestAccountingAPI() *testAccountingAPI {
+	return &testAccountingAPI{
+		syncInfos:    make(map[string]*mongodb.SyncInfo),
+		events:       make(map[mongodb.TxType][]*mongodb.SwapEvent),
+		summaries:    make(map[int64]*mongodb.Summary),
+		summaryInfos: make(map[int64]*mongodb.SummaryInfo),
+	}
+}
+
+// testSwapEventIter fails after the events if err is set
+type testSwapEventIter struct {
+	events []*mongodb.SwapEvent
+	err    error
+}
+
+func (iter *testSwapEventIter) Next(dst *mongodb.SwapEvent) bool {
+	if len(iter.events) == 0 {
+		return false
+	}
+	*dst = *iter.events[0]
+	iter.events = iter.events[1:]
+	return true
+}
+
+func (iter *testSwapEventIter) Err() error {
+	return iter.err
+}
+
+func (iter *testSwapEventIter) Close() error {
+	return iter.err
+}
+
+func (api *testAccountingAPI) GetSyncInfo(chain string) (*mongodb.SyncInfo, error) {
+	info, exist := api.syncInfos[chain]
+	if !exist {
+		return nil, errTestNotFound
+	}
+	cpy := *info
+	return &cpy, nil
+}
+
+func (api *testAccountingAPI) MarkDirtyHeight(chain string, height int64) error {
+	info := api.syncInfos[chain]
+	if info.DirtyHeight == 0 || height < info.DirtyHeight {
+		info.DirtyHeight = height
+	}
+	return nil
+}
+
+func (api *testAccountingAPI) ClearDirtyHeight(chain string, height int64) error {
+	if info := api.syncInfos[chain]; info.DirtyHeight == height {
+		info.DirtyHeight = 0
+	}
+	return nil
+}
+
+func (api *testAccountingAPI) GetConfirmedSwapEventsByBlockRange(txtype mongodb.TxType, tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	var events []*mongodb.SwapEvent
+	for _, event := range api.events[txtype] {
+		if event.BlockNumber >= start && event.BlockNumber < end {
+			events = append(events, event)
+		}
+	}
+	return &testSwapEventIter{events: events, err: api.iterErr}, nil
+}
+
+func (api *testAccountingAPI) GetSummaryCollectionInfo() (*mongodb.SummaryCollectionInfo, error) {
+	return &mongodb.SummaryCollectionInfo{LatestSequence: api.latest}, nil
+}
+
+func (api *testAccountingAPI) GetSummaryInfo(sequence int64) (*mongodb.SummaryInfo, error) {
+	info, exist := api.summaryInfos[sequence]
+	if !exist {
+		return nil, errTestNotFound
+	}
+	return info, nil
+}
+
+func (api *testAccountingAPI) GetSummary(tokenCfg *params.TokenConfig, sequence int64) (*mongodb.Summary, error) {
+	summary, exist := api.summaries[sequence]
+	if !exist {
+		return nil, errTestNotFound
+	}
+	return summary, nil
+}
+
+func (api *testAccountingAPI) AddSummary(tokenCfg *params.TokenConfig, summary *mongodb.Summary) error {
+	api.summaries[summary.Sequence] = summary
+	return nil
+}
+
+func (api *testAccountingAPI) AddSummaryInfo(info *mongodb.SummaryInfo) error {
+	api.summaryInfos[info.Sequence] = info
+	return nil
+}
+
+func (api *testAccountingAPI) UpdateSummaryCollectionInfo(sequence int64) error {
+	api.latest = sequence
+	return nil
+}
+
+func (api *testAccountingAPI) addEvent(txtype mongodb.TxType, chain string, height int64, amount float64) {
+	api.events[txtype] = append(api.events[txtype], &mongodb.SwapEvent{Chain: chain, BlockNumber: height, FAmount: amount})
+}
+
+// the src token is on a chain of low heights, and the dst token is on a chain of high heights
+func testSummaryConfig(api *testAccountingAPI) *params.ScanConfig {
+	api.syncInfos["src"] = &mongodb.SyncInfo{Chain: "src", StartHeight: 100, ConfirmedHeight: 199}
+	api.syncInfos["dst"] = &mongodb.SyncInfo{Chain: "dst", StartHeight: 5000, ConfirmedHeight: 5099}
+	return &params.ScanConfig{
+		Chains: []*params.ChainConfig{{Name: "src"}, {Name: "dst"}},
+		Tokens: []*params.TokenConfig{
+			{Chain: "src", PairID: "usdt", IsSrcToken: true},
+			{Chain: "dst", PairID: "usdt"},
+		},
+	}
+}
+
+func checkSummary(t *testing.T, api *testAccountingAPI, sequence int64, deposit, mint, burn, redeemed float64) {
+	t.Helper()
+	summary, exist := api.summaries[sequence]
+	if !exist {
+		t.Fatalf("summary %v not made", sequence)
+	}
+	if summary.AccDeposit != deposit || summary.AccMint != mint || summary.AccBurn != burn || summary.AccRedeemed != redeemed {
+		t.Errorf("summary %v mismatch, have %v %v %v %v want %v %v %v %v", sequence,
+			summary.AccDeposit, summary.AccMint, summary.AccBurn, summary.AccRedeemed, deposit, mint, burn, redeemed)
+	}
+}
+
+func TestMakePairSummaryOfTwoChains(t *testing.T) {
+	api := newTestAccountingAPI()
+	defer func(old mongodb.AccountingAPI) { dbAPI = old }(dbAPI)
+	dbAPI = api
+	cfg := testSummaryConfig(api)
+
+	api.addEvent(mongodb.TypeDeposit, "src", 150, 10)
+	api.addEvent(mongodb.TypeRedeemed, "src", 160, 3)
+	api.addEvent(mongodb.TypeDeposit, "src", 5050, 1000) // above the range of src chain
+	api.addEvent(mongodb.TypeMint, "dst", 5010, 9)
+	api.addEvent(mongodb.TypeBurn, "dst", 5020, 4)
+	api.addEvent(mongodb.TypeMint, "dst", 150, 1000) // below the range of dst chain
+
+	if err := makeSummary(cfg); err != nil {
+		t.Fatalf("make summary failed: %v", err)
+	}
+	checkSummary(t, api, 1, 10, 9, 4, 3)
+}
+
+func TestRemakeDirtySummaries(t *testing.T) {
+	api := newTestAccountingAPI()
+	defer func(old mongodb.AccountingAPI) { dbAPI = old }(dbAPI)
+	dbAPI = api
+	cfg := testSummaryConfig(api)
+
+	api.addEvent(mongodb.TypeDeposit, "src", 150, 10)
+	if err := makeSummary(cfg); err != nil {
+		t.Fatalf("make summary failed: %v", err)
+	}
+	api.syncInfos["src"].ConfirmedHeight = 299
+	api.addEvent(mongodb.TypeDeposit, "src", 250, 20)
+	if err := makeSummary(cfg); err != nil {
+		t.Fatalf("make summary failed: %v", err)
+	}
+	checkSummary(t, api, 2, 30, 0, 0, 0)
+
+	// deposit recorded late in the range of the first summary, and burn in the next range
+	api.addEvent(mongodb.TypeDeposit, "src", 120, 5)
+	if err := api.MarkDirtyHeight("src", 120); err != nil {
+		t.Fatal(err)
+	}
+	api.syncInfos["dst"].ConfirmedHeight = 5199
+	api.addEvent(mongodb.TypeBurn, "dst", 5150, 7)
+	if err := makeSummary(cfg); err != nil {
+		t.Fatalf("make summary failed: %v", err)
+	}
+	checkSummary(t, api, 1, 15, 0, 0, 0)
+	checkSummary(t, api, 2, 35, 0, 0, 0)
+	checkSummary(t, api, 3, 35, 0, 7, 0)
+	if dirty := api.syncInfos["src"].DirtyHeight; dirty != 0 {
+		t.Errorf("dirty height is not cleared: %v", dirty)
+	}
+}
+
+func TestMakeSummaryAbortsOnIterError(t *testing.T) {
+	api := newTestAccountingAPI()
+	defer func(old mongodb.AccountingAPI) { dbAPI = old }(dbAPI)
+	dbAPI = api
+	cfg := testSummaryConfig(api)
+
+	api.addEvent(mongodb.TypeDeposit, "src", 150, 10)
+	api.iterErr = errors.New("cursor killed")
+	if err := makeSummary(cfg); err == nil {
+		t.Fatalf("make summary succeeded with iterating error")
+	}
+	if _, exist := api.summaries[1]; exist {
+		t.Errorf("summary is saved with iterating error")
+	}
+	if api.latest != 0 {
+		t.Errorf("summary sequence is updated with iterating error: %v", api.latest)
+	}
+}
@@ -7,7 +7,7 @@ import (
 
 type AccountingAPI interface {
 	AccountingQueryAPI
-	MakeSummaryInfo(tag string, srcStartHeight, srcEndHeight, dstStartHeight, dstEndHeight int64) (*mongodb.SummaryInfo, error)
+	MakeSummaryInfo(tag string, startHeights, endHeights map[string]int64) (*mongodb.SummaryInfo, error)
 	MakeSummary(*params.TokenConfig) (*mongodb.SummaryInfo, error)
 }
 
@@ -0,0 +1,349 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// alert rules
+const (
+	RuleScanLag        = "scan-lag"
+	RuleNoNewBlock     = "no-new-block"
+	RuleMongoReconnect = "mongo-reconnect"
+	RuleDrift          = "drift"
+	RuleUnmatchedSwap  = "unmatched-swap"
+	RuleLargeSwap      = "large-swap"
+	RuleAnomaly        = "anomaly"
+)
+
+const evaluateInterval = 1 * time.Minute
+
+var (
+	dbAPI mongodb.AlertAPI
+
+	lock        sync.Mutex
+	active      = make(map[string]bool)        // keys of active alerts
+	keyLocks    = make(map[string]*sync.Mutex) // serialize the updates of alert
+	chainStates = make(map[string]*chainState)
+	reconnects  uint64
+)
+
+// chainState the latest block reported by scanner of chain
+type chainState struct {
+	latest    uint64
+	changedAt time.Time
+}
+
+// get alert config, nil if alerting is not enabled
+func getConfig() *params.AlertConfig {
+	if dbAPI == nil {
+		return nil
+	}
+	return params.GetScanConfig().Alert
+}
+
+// StartAlerting load the active alerts and evaluate the periodic rules in background, until ctx is done
+func StartAlerting(ctx context.Context) {
+	if params.GetScanConfig().Alert == nil {
+		log.Info("alerting is not enabled")
+		return
+	}
+	dbAPI = mongodb.NewAlertAPI()
+	alerts, err := dbAPI.GetAlerts(true)
+	if err != nil {
+		log.Warn("load active alerts failed", "err", err)
+	}
+	lock.Lock()
+	for _, alert := range alerts {
+		active[alert.Key] = true
+	}
+	reconnects = mongodb.GetReconnectCount()
+	lock.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("stop alerting")
+				return
+			case <-time.After(evaluateInterval):
+			}
+			if cfg := getConfig(); cfg != nil {
+				evaluatePeriodicRules(cfg)
+			}
+		}
+	}()
+	log.Info("start alerting", "active", len(alerts))
+}
+
+// OnScanTick evaluate scan rules after each scan tick of chain,
+// the synced height is zero if the scanner has not synced any block.
+func OnScanTick(chain string, latest, synced uint64) {
+	cfg := getConfig()
+	if cfg == nil {
+		return
+	}
+	lock.Lock()
+	state, exist := chainStates[chain]
+	if !exist || state.latest != latest {
+		chainStates[chain] = &chainState{latest: latest, changedAt: time.Now()}
+	}
+	lock.Unlock()
+
+	if cfg.MaxScanLag == 0 || synced == 0 {
+		return
+	}
+	if latest > synced && latest-synced > cfg.MaxScanLag {
+		fire(cfg, RuleScanLag, chain, fmt.Sprintf("scanner of %v lags %v blocks behind the latest block %v", chain, latest-synced, latest))
+	} else {
+		resolve(cfg, RuleScanLag, chain)
+	}
+}
+
+// OnSwapEvent evaluate the amount limit of pair when new swap event is recorded
+func OnSwapEvent(pairID, swapType string, event *mongodb.SwapEvent) {
+	cfg := getConfig()
+	if cfg == nil {
+		return
+	}
+	limit, exist := cfg.SwapAmountLimits[pairID]
+	if !exist || event.FAmount <= limit {
+		return
+	}
+	fire(cfg, RuleLargeSwap, event.TxHash, fmt.Sprintf("%v of %v amount %v exceeds limit %v, tx %v", swapType, pairID, event.FAmount, limit, event.TxHash))
+}
+
+// OnAnomaly fire alert of the detected anomaly
+func OnAnomaly(anomaly *mongodb.Anomaly) {
+	cfg := getConfig()
+	if cfg == nil {
+		return
+	}
+	fire(cfg, RuleAnomaly, anomaly.Key, fmt.Sprintf("%v of %v amount %v user %v, tx %v at block %v",
+		anomaly.Kind, anomaly.PairID, anomaly.FAmount, anomaly.User, anomaly.TxHash, anomaly.BlockNumber))
+}
+
+// OnAnomalyResolved resolve alert of the anomaly whose swap event is matched later
+func OnAnomalyResolved(anomaly *mongodb.Anomaly) {
+	cfg := getConfig()
+	if cfg == nil {
+		return
+	}
+	resolve(cfg, RuleAnomaly, anomaly.Key)
+}
+
+// OnSummary evaluate the reconciliation drift of pair after summary is made
+func OnSummary(pairID string, summary *mongodb.Summary) {
+	cfg := getConfig()
+	if cfg == nil || cfg.MaxDrift == 0 {
+		return
+	}
+	// deposits are minted with bridge fee deducted, burns are redeemed
+	swapinDrift := summary.AccDeposit - summary.AccMint - summary.AccBridgeFee
+	swapoutDrift := summary.AccBurn - summary.AccRedeemed
+	if abs(swapinDrift) > cfg.MaxDrift || abs(swapoutDrift) > cfg.MaxDrift {
+		fire(cfg, RuleDrift, pairID, fmt.Sprintf("%v drifts at summary %v, swapin %v swapout %v", pairID, summary.Sequence, swapinDrift, swapoutDrift))
+	} else {
+		resolve(cfg, RuleDrift, pairID)
+	}
+}
+
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func evaluatePeriodicRules(cfg *params.AlertConfig) {
+	if cfg.NoNewBlockMinutes > 0 {
+		evaluateNoNewBlock(cfg)
+	}
+	if cfg.MongoReconnect {
+		lock.Lock()
+		count := mongodb.GetReconnectCount()
+		newReconnects := count - reconnects
+		reconnects = count
+		lock.Unlock()
+		if newReconnects > 0 {
+			fire(cfg, RuleMongoReconnect, "mongodb", fmt.Sprintf("reconnected to mongodb %v times in the last %v", newReconnects, evaluateInterval))
+		} else {
+			resolve(cfg, RuleMongoReconnect, "mongodb")
+		}
+	}
+	if cfg.UnmatchedSwapMinutes > 0 {
+		evaluateUnmatchedSwaps(cfg)
+	}
+}
+
+func evaluateNoNewBlock(cfg *params.AlertConfig) {
+	maxDuration := time.Duration(cfg.NoNewBlockMinutes) * time.Minute
+	lock.Lock()
+	stales := make(map[string]*chainState)
+	var fresh []string
+	for chain, state := range chainStates {
+		if time.Since(state.changedAt) > maxDuration {
+			cpy := *state
+			stales[chain] = &cpy
+		} else {
+			fresh = append(fresh, chain)
+		}
+	}
+	lock.Unlock()
+	for chain, state := range stales {
+		fire(cfg, RuleNoNewBlock, chain, fmt.Sprintf("no new block of %v since %v, the latest block is %v", chain, state.changedAt.Format(time.RFC3339), state.latest))
+	}
+	for _, chain := range fresh {
+		resolve(cfg, RuleNoNewBlock, chain)
+	}
+}
+
+// swap types which are waiting for the swap on the other chain
+var unmatchedSwapTypes = []struct {
+	txtype mongodb.TxType
+	name   string
+}{
+	{mongodb.TypeDeposit, "Deposit"},
+	{mongodb.TypeMint, "Mint"},
+	{mongodb.TypeBurn, "Burn"},
+}
+
+// the deposits, mints and burns of pair share collections, so they are checked once per pair
+func evaluateUnmatchedSwaps(cfg *params.AlertConfig) {
+	before := time.Now().Add(-time.Duration(cfg.UnmatchedSwapMinutes) * time.Minute).Unix()
+	checked := make(map[string]struct{})
+	for _, tokenCfg := range params.GetScanConfig().Tokens {
+		if _, exist := checked[tokenCfg.PairID]; exist {
+			continue
+		}
+		checked[tokenCfg.PairID] = struct{}{}
+		for _, unmatched := range unmatchedSwapTypes {
+			txtype, swapType := unmatched.txtype, unmatched.name
+			subject := tokenCfg.PairID + ":" + swapType
+			iter, err := dbAPI.GetUnmatchedSwaps(txtype, tokenCfg, before)
+			if err != nil {
+				log.Warn("get unmatched swaps failed", "pairID", tokenCfg.PairID, "swapType", swapType, "err", err)
+				continue
+			}
+			var count int
+			var oldest mongodb.SwapEvent
+			for {
+				var event mongodb.SwapEvent
+				if !iter.Next(&event) {
+					break
+				}
+				if count == 0 {
+					oldest = event
+				}
+				count++
+			}
+			if err = iter.Close(); err != nil {
+				log.Warn("iterate unmatched swaps failed", "pairID", tokenCfg.PairID, "swapType", swapType, "err", err)
+				continue
+			}
+			if count == 0 {
+				resolve(cfg, RuleUnmatchedSwap, subject)
+				continue
+			}
+			fire(cfg, RuleUnmatchedSwap, subject, fmt.Sprintf("%v unmatched %v of %v older than %v minutes, the oldest is %v at block %v",
+				count, swapType, tokenCfg.PairID, cfg.UnmatchedSwapMinutes, oldest.TxHash, oldest.BlockNumber))
+		}
+	}
+}
+
+func alertKey(rule, subject string) string {
+	return rule + ":" + subject
+}
+
+// lock the alert of key, the alert is read, changed and saved by the goroutines of scanners
+func lockKey(key string) (unlock func()) {
+	lock.Lock()
+	keyLock, exist := keyLocks[key]
+	if !exist {
+		keyLock = new(sync.Mutex)
+		keyLocks[key] = keyLock
+	}
+	lock.Unlock()
+	keyLock.Lock()
+	return keyLock.Unlock
+}
+
+// fire alert and notify it unless it is silenced or notified within the silence duration
+func fire(cfg *params.AlertConfig, rule, subject, message string) {
+	key := alertKey(rule, subject)
+	defer lockKey(key)()
+	now := time.Now().Unix()
+	alert, err := dbAPI.GetAlert(key)
+	switch {
+	case err == nil:
+	case errors.Cause(err) == mgo.ErrNotFound:
+		alert = &mongodb.Alert{Key: key, Rule: rule, Subject: subject}
+	default:
+		// the stored alert is not overwritten, which may be silenced
+		log.Warn("get alert failed", "key", key, "err", err)
+		return
+	}
+	if !alert.Active {
+		alert.Active = true
+		alert.Count = 0
+		alert.FirstTime = now
+	}
+	alert.Count++
+	alert.LastTime = now
+	alert.Message = message
+	notify := now >= alert.SilencedUntil && now-alert.NotifyTime >= int64(cfg.GetSilenceDuration().Seconds())
+	if notify {
+		alert.NotifyTime = now
+	}
+	if err = dbAPI.SetAlert(alert); err != nil {
+		log.Warn("save alert failed", "key", key, "err", err)
+		return
+	}
+	lock.Lock()
+	active[key] = true
+	lock.Unlock()
+
+	log.Warn("alert fired", "key", key, "count", alert.Count, "notify", notify, "message", message)
+	if notify {
+		go notifyAll(cfg, alert, statusFiring)
+	}
+}
+
+// resolve the active alert, the resolution is notified if the alert is notified
+func resolve(cfg *params.AlertConfig, rule, subject string) {
+	key := alertKey(rule, subject)
+	lock.Lock()
+	isActive := active[key]
+	lock.Unlock()
+	if !isActive {
+		return
+	}
+	defer lockKey(key)()
+	alert, err := dbAPI.GetAlert(key)
+	if err != nil {
+		log.Warn("get alert failed", "key", key, "err", err)
+		return
+	}
+	alert.Active = false
+	if err = dbAPI.SetAlert(alert); err != nil {
+		log.Warn("save alert failed", "key", key, "err", err)
+		return
+	}
+	lock.Lock()
+	delete(active, key)
+	lock.Unlock()
+
+	log.Info("alert resolved", "key", key)
+	if alert.NotifyTime >= alert.FirstTime && time.Now().Unix() >= alert.SilencedUntil {
+		go notifyAll(cfg, alert, statusResolved)
+	}
+}
@@ -0,0 +1,92 @@
+package alert
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// testAlertAPI in-memory alerts, the read fails with getErr if it is set
+type testAlertAPI struct {
+	mongodb.AlertAPI
+
+	lock   sync.Mutex
+	alerts map[string]mongodb.Alert
+	getErr error
+}
+
+func (api *testAlertAPI) GetAlert(key string) (*mongodb.Alert, error) {
+	api.lock.Lock()
+	alert, exist := api.alerts[key]
+	getErr := api.getErr
+	api.lock.Unlock()
+	// widen the window between read and write
+	time.Sleep(time.Millisecond)
+	switch {
+	case getErr != nil:
+		return nil, getErr
+	case !exist:
+		return nil, errors.Wrap(mgo.ErrNotFound, "[mongo db] [GetAlert]")
+	}
+	return &alert, nil
+}
+
+func (api *testAlertAPI) SetAlert(alert *mongodb.Alert) error {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+	api.alerts[alert.Key] = *alert
+	return nil
+}
+
+func withTestAlertAPI(t *testing.T) *testAlertAPI {
+	api := &testAlertAPI{alerts: make(map[string]mongodb.Alert)}
+	old := dbAPI
+	dbAPI = api
+	t.Cleanup(func() { dbAPI = old })
+	return api
+}
+
+func TestFireConcurrently(t *testing.T) {
+	api := withTestAlertAPI(t)
+	cfg := &params.AlertConfig{}
+
+	const fires = 20
+	var wg sync.WaitGroup
+	for i := 0; i < fires; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fire(cfg, RuleScanLag, "test", "lags")
+		}()
+	}
+	wg.Wait()
+	if count := api.alerts[alertKey(RuleScanLag, "test")].Count; count != fires {
+		t.Errorf("alert count mismatch, have %v want %v", count, fires)
+	}
+}
+
+func TestFireKeepsAlertOnReadError(t *testing.T) {
+	api := withTestAlertAPI(t)
+	cfg := &params.AlertConfig{}
+
+	key := alertKey(RuleDrift, "usdt")
+	silencedUntil := time.Now().Add(time.Hour).Unix()
+	api.alerts[key] = mongodb.Alert{Key: key, Rule: RuleDrift, Subject: "usdt", Active: true, Count: 3, SilencedUntil: silencedUntil}
+	api.getErr = errors.New("connection reset")
+	fire(cfg, RuleDrift, "usdt", "drifted")
+	if alert := api.alerts[key]; alert.Count != 3 || alert.SilencedUntil != silencedUntil {
+		t.Errorf("alert is overwritten on read error: %+v", alert)
+	}
+
+	api.getErr = nil
+	fire(cfg, RuleDrift, "usdt", "drifted")
+	if alert := api.alerts[key]; alert.Count != 4 || alert.SilencedUntil != silencedUntil {
+		t.Errorf("alert is not updated: %+v", alert)
+	}
+}
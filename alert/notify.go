@@ -0,0 +1,120 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// alert status of notification
+const (
+	statusFiring   = "firing"
+	statusResolved = "resolved"
+)
+
+const notifyTimeout = 10 * time.Second
+
+var httpClient = &http.Client{Timeout: notifyTimeout}
+
+// webhookPayload json payload posted to webhooks
+type webhookPayload struct {
+	Key     string `json:"key"`
+	Rule    string `json:"rule"`
+	Subject string `json:"subject"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Count   int64  `json:"count"`
+	Time    int64  `json:"time"`
+}
+
+// slackPayload payload of slack compatible incoming webhooks
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// notify alert by all channels of config
+func notifyAll(cfg *params.AlertConfig, alert *mongodb.Alert, status string) {
+	for _, url := range cfg.Webhooks {
+		payload := &webhookPayload{
+			Key:     alert.Key,
+			Rule:    alert.Rule,
+			Subject: alert.Subject,
+			Status:  status,
+			Message: alert.Message,
+			Count:   alert.Count,
+			Time:    alert.LastTime,
+		}
+		if err := postJSON(url, payload); err != nil {
+			log.Warn("notify alert by webhook failed", "key", alert.Key, "url", url, "err", err)
+		}
+	}
+	for _, url := range cfg.SlackWebhooks {
+		if err := postJSON(url, &slackPayload{Text: alertText(alert, status)}); err != nil {
+			log.Warn("notify alert by slack webhook failed", "key", alert.Key, "url", url, "err", err)
+		}
+	}
+	if cfg.SMTP != nil {
+		if err := sendMail(cfg.SMTP, alert, status); err != nil {
+			log.Warn("notify alert by email failed", "key", alert.Key, "err", err)
+		}
+	}
+}
+
+func alertText(alert *mongodb.Alert, status string) string {
+	return fmt.Sprintf("[%v] %v: %v", strings.ToUpper(status), alert.Key, alert.Message)
+}
+
+func postJSON(url string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook responds status %v", resp.Status)
+	}
+	return nil
+}
+
+func sendMail(cfg *params.SMTPConfig, alert *mongodb.Alert, status string) error {
+	var auth smtp.Auth
+	if cfg.UserName != "" {
+		auth = smtp.PlainAuth("", cfg.UserName, cfg.Password, cfg.Host)
+	}
+	subject := alertText(alert, status)
+	body := fmt.Sprintf("%v\r\n\r\nrule: %v\r\nsubject: %v\r\ncount: %v\r\nfirst: %v\r\nlast: %v\r\n",
+		alert.Message, alert.Rule, alert.Subject, alert.Count,
+		time.Unix(alert.FirstTime, 0).UTC().Format(time.RFC3339), time.Unix(alert.LastTime, 0).UTC().Format(time.RFC3339))
+	msg := fmt.Sprintf("From: %v\r\nTo: %v\r\nSubject: %v\r\n\r\n%v", cfg.From, strings.Join(cfg.To, ", "), subject, body)
+	// the envelope addresses do not have display names
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return err
+	}
+	to := make([]string, 0, len(cfg.To))
+	for _, item := range cfg.To {
+		addr, err := mail.ParseAddress(item)
+		if err != nil {
+			return err
+		}
+		to = append(to, addr.Address)
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	return smtp.SendMail(addr, auth, from.Address, to, []byte(msg))
+}
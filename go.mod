@@ -5,10 +5,14 @@ go 1.15
 require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/anyswap/CrossChain-Bridge v0.3.6-0.20210423104524-4d1ae8d0de6b
+	github.com/btcsuite/btcutil v1.0.2
 	github.com/davecgh/go-spew v1.1.1
 	github.com/ethereum/go-ethereum v1.10.4
 	github.com/fsnotify/fsnotify v1.4.9
+	github.com/gaozhengxin/bridgeAccount/dev v0.0.0
 	github.com/pkg/errors v0.9.1
 	github.com/urfave/cli/v2 v2.3.0
 	gopkg.in/mgo.v2 v2.0.0-20190816093944-a6b53ec6cb22
 )
+
+replace github.com/gaozhengxin/bridgeAccount/dev => ./dev
@@ -22,7 +22,13 @@ func initApp() {
 	app.HideVersion = true
 	app.Usage = "scan eth like blockchain"
 	app.Commands = []*cli.Command{
-		//scanner.ScanSwapCommand,
+		scanner.StartCommand,
+		scanner.RescanCommand,
+		scanner.TxCommand,
+		scanner.StatusCommand,
+		scanner.AlertsCommand,
+		scanner.BlockCacheCommand,
+		scanner.ExportBlocksCommand,
 		scanner.VersionCommand,
 	}
 	app.Flags = []cli.Flag{
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// metrics published in expvar
+var (
+	AnomaliesDetected = expvar.NewMap("anomalies_detected") // kind -> count
+	AnomaliesResolved = expvar.NewMap("anomalies_resolved") // kind -> count
+)
+
+// StartServer serve metrics at '/debug/vars' of listen address in background, until ctx is done
+func StartServer(ctx context.Context, listen string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{Addr: listen, Handler: mux}
+	go func() {
+		log.Info("start metrics server", "listen", listen)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Warn("metrics server stopped", "err", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+}
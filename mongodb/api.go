@@ -2,7 +2,9 @@ package mongodb
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gaozhengxin/bridgeAccounting/params"
 	"github.com/pkg/errors"
@@ -29,6 +31,9 @@ func (iter *SummaryIterImpl) Next(dst *Summary) bool {
 	return iter.Iter.Next(dst)
 }
 
+// ErrItemIsDup item is duplicate
+var ErrItemIsDup = errors.New("mgoError: Item is duplicate")
+
 func wrapError(err error, tag ...string) error {
 	return errors.Wrap(err, fmt.Sprintf("[mongo db] %s", tag))
 }
@@ -45,10 +50,14 @@ func NewAccountingAPI() AccountingAPI {
 	return new(AccountingAPIImpl)
 }
 
+func NewAlertAPI() AlertAPI {
+	return new(AlertAPIImpl)
+}
+
 type TxType int8
 
 const (
-	TypeDeposit  TxType = iota
+	TypeDeposit TxType = iota
 	TypeMint
 	TypeBurn
 	TypeRedeemed
@@ -88,19 +97,57 @@ type AccountingAPIImpl struct {
 	*AccountingQueryAPIImpl
 }
 
+type AlertAPIImpl struct{}
+
 type BaseQueryAPIImpl struct{}
 
 type AccountingQueryAPIImpl struct{}
 
-func (*BaseQueryAPIImpl) GetSyncInfo() (*SyncInfo, error) {
+func (*BaseQueryAPIImpl) GetSyncInfo(chain string) (*SyncInfo, error) {
 	result := new(SyncInfo)
-	err := collSyncInfo.FindId(bson.M{"_id": syncInfoID}).One(result)
+	err := collSyncInfo.FindId(chain).One(result)
 	if err != nil {
 		return nil, wrapError(err, "GetSyncInfo")
 	}
 	return result, nil
 }
 
+func (*BaseQueryAPIImpl) GetTokenSyncInfo(tokenCfg *params.TokenConfig) (*TokenSyncInfo, error) {
+	result := new(TokenSyncInfo)
+	err := collTokenSyncInfo.FindId(tokenCfg.Key()).One(result)
+	if err != nil {
+		return nil, wrapError(err, "GetTokenSyncInfo")
+	}
+	return result, nil
+}
+
+func (*BaseQueryAPIImpl) GetBlockGaps(chain string) ([]*BlockGap, error) {
+	var result []*BlockGap
+	err := collBlockGaps.Find(bson.M{"chain": chain}).Sort("start").All(&result)
+	if err != nil {
+		return nil, wrapError(err, "GetBlockGaps")
+	}
+	return result, nil
+}
+
+func (*BaseQueryAPIImpl) GetTokenMeta(tokenCfg *params.TokenConfig) (*TokenMeta, error) {
+	result := new(TokenMeta)
+	err := collTokenMeta.FindId(tokenCfg.Key()).One(result)
+	if err != nil {
+		return nil, wrapError(err, "GetTokenMeta")
+	}
+	return result, nil
+}
+
+func (*BaseQueryAPIImpl) GetTokenMetas(chain string) ([]*TokenMeta, error) {
+	var result []*TokenMeta
+	err := collTokenMeta.Find(bson.M{"chain": chain}).Sort("pair_id").All(&result)
+	if err != nil {
+		return nil, wrapError(err, "GetTokenMetas")
+	}
+	return result, nil
+}
+
 func getSwapEvent(txtype TxType, tokenCfg *params.TokenConfig, txhash string) (*SwapEvent, error) {
 	coll, err := selectCollection(txtype, tokenCfg)
 	if err != nil {
@@ -109,7 +156,7 @@ func getSwapEvent(txtype TxType, tokenCfg *params.TokenConfig, txhash string) (*
 
 	txhash = strings.ToLower(txhash)
 	result := new(SwapEvent)
-	err = coll.FindId(bson.M{"_id": txhash}).One(result)
+	err = coll.FindId(txhash).One(result)
 	if err != nil {
 		return nil, wrapError(err, "getSwapEvent")
 	}
@@ -125,7 +172,7 @@ func getSwapEventByBlockRange(
 		return nil, wrapError(err, "getSwapEventByBlockRange", "selectCollection")
 	}
 
-	query := bson.M{"block_number": bson.M{"$gte": start, "$lt": end}}
+	query := bson.M{"chain": tokenCfg.Chain, "block_number": bson.M{"$gte": start, "$lt": end}}
 	iter := coll.Find(query).Iter()
 	swapEventIter := &SwapEventIterImpl{
 		Iter: iter,
@@ -142,7 +189,7 @@ func getSwapEventByTimeRange(
 		return nil, wrapError(err, "getSwapEventByTimeRange", "selectCollection")
 	}
 
-	query := bson.M{"block_time": bson.M{"$gte": start, "$lt": end}}
+	query := bson.M{"chain": tokenCfg.Chain, "block_time": bson.M{"$gte": start, "$lt": end}}
 	iter := coll.Find(query).Iter()
 	swapEventIter := &SwapEventIterImpl{
 		Iter: iter,
@@ -162,7 +209,7 @@ func getSwapEventByUserTimeRange(
 
 	user = strings.ToLower(user)
 
-	query := bson.M{"user": user, "block_time": bson.M{"$gte": start, "$lt": end}}
+	query := bson.M{"chain": tokenCfg.Chain, "user": user, "block_time": bson.M{"$gte": start, "$lt": end}}
 	iter := coll.Find(query).Iter()
 	swapEventIter := &SwapEventIterImpl{
 		Iter: iter,
@@ -255,26 +302,116 @@ func (*BaseQueryAPIImpl) GetRedeemedByUserTimeRange(tokenCfg *params.TokenConfig
 	return getSwapEventByUserTimeRange(TypeRedeemed, tokenCfg, user, start, end)
 }
 
-func (*SyncAPIImpl) SetStartHeight(srcStartHeight, dstStartHeight int64) error {
+func (*SyncAPIImpl) SetStartHeight(chain string, startHeight int64) error {
 	info, err := collSyncInfo.UpsertId(
-		bson.M{"_id": syncInfoID},
-		bson.M{"$set": bson.M{"src_start_height": srcStartHeight, "dst_start_height": dstStartHeight}})
+		chain,
+		bson.M{"$set": bson.M{"start_height": startHeight}})
 	if err != nil {
 		return wrapError(err, "SetStartHeight", spew.Sprintf("%v", info))
 	}
 	return nil
 }
 
-func (*SyncAPIImpl) UpdateSyncedHeight(srcSyncedHeight, dstSyncedHeight int64) error {
+func (*SyncAPIImpl) UpdateSyncedHeight(chain string, syncedHeight int64) error {
 	info, err := collSyncInfo.UpsertId(
-		bson.M{"_id": syncInfoID},
-		bson.M{"$set": bson.M{"src_synced_height": srcSyncedHeight, "dst_synced_height": dstSyncedHeight}})
+		chain,
+		bson.M{"$set": bson.M{"synced_height": syncedHeight}})
 	if err != nil {
 		return wrapError(err, "UpdateSyncedHeight", spew.Sprintf("%v", info))
 	}
 	return nil
 }
 
+func (*SyncAPIImpl) UpdateConfirmedHeight(chain string, confirmedHeight int64) error {
+	info, err := collSyncInfo.UpsertId(
+		chain,
+		bson.M{"$set": bson.M{"confirmed_height": confirmedHeight}})
+	if err != nil {
+		return wrapError(err, "UpdateConfirmedHeight", spew.Sprintf("%v", info))
+	}
+	return nil
+}
+
+func (*SyncAPIImpl) MarkDirtyHeight(chain string, height int64) error {
+	return markDirtyHeight(chain, height)
+}
+
+// lower the dirty height of chain to height if it is above
+func markDirtyHeight(chain string, height int64) error {
+	info, err := collSyncInfo.UpsertId(
+		chain,
+		bson.M{"$min": bson.M{"dirty_height": height}})
+	if err != nil {
+		return wrapError(err, "MarkDirtyHeight", spew.Sprintf("%v", info))
+	}
+	return nil
+}
+
+func (*SyncAPIImpl) SetTokenBackfillRange(tokenCfg *params.TokenConfig, startHeight, endHeight int64) error {
+	info, err := collTokenSyncInfo.UpsertId(
+		tokenCfg.Key(),
+		bson.M{"$set": bson.M{
+			"chain":         tokenCfg.Chain,
+			"pair_id":       tokenCfg.PairID,
+			"start_height":  startHeight,
+			"end_height":    endHeight,
+			"synced_height": startHeight,
+		}})
+	if err != nil {
+		return wrapError(err, "SetTokenBackfillRange", spew.Sprintf("%v", info))
+	}
+	return nil
+}
+
+func (*SyncAPIImpl) UpdateTokenSyncedHeight(tokenCfg *params.TokenConfig, syncedHeight int64) error {
+	err := collTokenSyncInfo.UpdateId(
+		tokenCfg.Key(),
+		bson.M{"$set": bson.M{"synced_height": syncedHeight}})
+	if err != nil {
+		return wrapError(err, "UpdateTokenSyncedHeight")
+	}
+	return nil
+}
+
+// BlockGapKey key of block gap starts from height
+func BlockGapKey(chain string, start int64) string {
+	return fmt.Sprintf("%v:%v", chain, start)
+}
+
+func (*SyncAPIImpl) AddBlockGap(chain string, start, end int64, txIndex int, blockHash, reason string) error {
+	info, err := collBlockGaps.UpsertId(
+		BlockGapKey(chain, start),
+		bson.M{"$set": bson.M{
+			"chain":      chain,
+			"start":      start,
+			"end":        end,
+			"tx_index":   txIndex,
+			"block_hash": blockHash,
+			"reason":     reason,
+			"timestamp":  time.Now().Unix(),
+		}})
+	if err != nil {
+		return wrapError(err, "AddBlockGap", spew.Sprintf("%v", info))
+	}
+	return nil
+}
+
+func (*SyncAPIImpl) RemoveBlockGap(key string) error {
+	err := collBlockGaps.RemoveId(key)
+	if err != nil && err != mgo.ErrNotFound {
+		return wrapError(err, "RemoveBlockGap")
+	}
+	return nil
+}
+
+func (*SyncAPIImpl) SetTokenMeta(meta *TokenMeta) error {
+	info, err := collTokenMeta.UpsertId(meta.Key, meta)
+	if err != nil {
+		return wrapError(err, "SetTokenMeta", spew.Sprintf("%v", info))
+	}
+	return nil
+}
+
 func addSwapEvent(txtype TxType, tokenCfg *params.TokenConfig, data *SwapEvent) error {
 	coll, err := selectCollection(txtype, tokenCfg)
 	if err != nil {
@@ -282,12 +419,217 @@ func addSwapEvent(txtype TxType, tokenCfg *params.TokenConfig, data *SwapEvent)
 	}
 
 	err = coll.Insert(data)
+	if mgo.IsDup(err) {
+		return ErrItemIsDup
+	}
 	if err != nil {
 		return wrapError(err, "addSwapEvent")
 	}
 	return nil
 }
 
+func updateSwapEvent(txtype TxType, tokenCfg *params.TokenConfig, data *SwapEvent) error {
+	coll, err := selectCollection(txtype, tokenCfg)
+	if err != nil {
+		return wrapError(err, "updateSwapEvent", "selectCollection")
+	}
+
+	err = coll.UpdateId(data.TxHash, data)
+	if err != nil {
+		return wrapError(err, "updateSwapEvent")
+	}
+	return nil
+}
+
+// GetUnconfirmedSwapEvents get unconfirmed swap events in blocks below end
+func (*SyncAPIImpl) GetUnconfirmedSwapEvents(txtype TxType, tokenCfg *params.TokenConfig, end int64) (SwapEventIter, error) {
+	coll, err := selectCollection(txtype, tokenCfg)
+	if err != nil {
+		return nil, wrapError(err, "GetUnconfirmedSwapEvents", "selectCollection")
+	}
+
+	query := bson.M{"chain": tokenCfg.Chain, "unconfirmed": true, "block_number": bson.M{"$lt": end}}
+	iter := coll.Find(query).Sort("block_number").Iter()
+	swapEventIter := &SwapEventIterImpl{
+		Iter: iter,
+	}
+	return swapEventIter, nil
+}
+
+func (*SyncAPIImpl) ConfirmSwapEvent(txtype TxType, tokenCfg *params.TokenConfig, txhash string) error {
+	coll, err := selectCollection(txtype, tokenCfg)
+	if err != nil {
+		return wrapError(err, "ConfirmSwapEvent", "selectCollection")
+	}
+
+	selector := bson.M{"_id": strings.ToLower(txhash), "chain": tokenCfg.Chain}
+	err = coll.Update(selector, bson.M{"$unset": bson.M{"unconfirmed": ""}})
+	if err != nil {
+		return wrapError(err, "ConfirmSwapEvent")
+	}
+	return nil
+}
+
+// GetUnmatchedMints get mints whose deposits are not found yet
+func (*SyncAPIImpl) GetUnmatchedMints(tokenCfg *params.TokenConfig) (SwapEventIter, error) {
+	coll, err := selectCollection(TypeMint, tokenCfg)
+	if err != nil {
+		return nil, wrapError(err, "GetUnmatchedMints", "selectCollection")
+	}
+
+	query := bson.M{"chain": tokenCfg.Chain, "matched": bson.M{"$ne": true}, "ref_tx": bson.M{"$exists": true}}
+	iter := coll.Find(query).Iter()
+	swapEventIter := &SwapEventIterImpl{
+		Iter: iter,
+	}
+	return swapEventIter, nil
+}
+
+func (*SyncAPIImpl) MatchMint(tokenCfg *params.TokenConfig, txhash string, bridgeFee float64) error {
+	coll, err := selectCollection(TypeMint, tokenCfg)
+	if err != nil {
+		return wrapError(err, "MatchMint", "selectCollection")
+	}
+
+	selector := bson.M{"_id": strings.ToLower(txhash), "chain": tokenCfg.Chain}
+	err = coll.Update(selector, bson.M{"$set": bson.M{"matched": true, "bridge_fee": bridgeFee}})
+	if err != nil {
+		return wrapError(err, "MatchMint")
+	}
+	return nil
+}
+
+func (*SyncAPIImpl) MatchDeposit(tokenCfg *params.TokenConfig, txhash string) error {
+	coll, err := selectCollection(TypeDeposit, tokenCfg)
+	if err != nil {
+		return wrapError(err, "MatchDeposit", "selectCollection")
+	}
+
+	err = coll.UpdateId(strings.ToLower(txhash), bson.M{"$set": bson.M{"matched": true}})
+	if err != nil {
+		return wrapError(err, "MatchDeposit")
+	}
+	return nil
+}
+
+// GetUnmatchedRedeems get redeems whose burns are not found yet
+func (*SyncAPIImpl) GetUnmatchedRedeems(tokenCfg *params.TokenConfig) (SwapEventIter, error) {
+	coll, err := selectCollection(TypeRedeemed, tokenCfg)
+	if err != nil {
+		return nil, wrapError(err, "GetUnmatchedRedeems", "selectCollection")
+	}
+
+	query := bson.M{"chain": tokenCfg.Chain, "matched": bson.M{"$ne": true}}
+	iter := coll.Find(query).Sort("block_time").Iter()
+	swapEventIter := &SwapEventIterImpl{
+		Iter: iter,
+	}
+	return swapEventIter, nil
+}
+
+// GetUnmatchedBurnsByBind get unmatched burns to bind address in blocks not after the end time, the earliest first.
+// the bind address is compared case insensitive as the redeem receiver is stored in lower case
+func (*SyncAPIImpl) GetUnmatchedBurnsByBind(tokenCfg *params.TokenConfig, bind string, end int64) (SwapEventIter, error) {
+	coll, err := selectCollection(TypeBurn, tokenCfg)
+	if err != nil {
+		return nil, wrapError(err, "GetUnmatchedBurnsByBind", "selectCollection")
+	}
+
+	query := bson.M{
+		"matched":    bson.M{"$ne": true},
+		"bind":       bson.RegEx{Pattern: "^" + regexp.QuoteMeta(bind) + "$", Options: "i"},
+		"block_time": bson.M{"$lte": end},
+	}
+	iter := coll.Find(query).Sort("block_time").Iter()
+	swapEventIter := &SwapEventIterImpl{
+		Iter: iter,
+	}
+	return swapEventIter, nil
+}
+
+// GetSwapEventsByTxHash get swap events of tx, which are keyed by the tx hash itself or with the swap index
+func (*SyncAPIImpl) GetSwapEventsByTxHash(txtype TxType, tokenCfg *params.TokenConfig, txhash string) (SwapEventIter, error) {
+	coll, err := selectCollection(txtype, tokenCfg)
+	if err != nil {
+		return nil, wrapError(err, "GetSwapEventsByTxHash", "selectCollection")
+	}
+
+	txhash = strings.ToLower(txhash)
+	query := bson.M{"_id": bson.RegEx{Pattern: "^" + regexp.QuoteMeta(txhash) + "(:|$)"}}
+	iter := coll.Find(query).Sort("_id").Iter()
+	swapEventIter := &SwapEventIterImpl{
+		Iter: iter,
+	}
+	return swapEventIter, nil
+}
+
+// MatchBurn mark burn matched, return not found error if it is matched already
+func (*SyncAPIImpl) MatchBurn(tokenCfg *params.TokenConfig, txhash string) error {
+	coll, err := selectCollection(TypeBurn, tokenCfg)
+	if err != nil {
+		return wrapError(err, "MatchBurn", "selectCollection")
+	}
+
+	selector := bson.M{"_id": strings.ToLower(txhash), "matched": bson.M{"$ne": true}}
+	err = coll.Update(selector, bson.M{"$set": bson.M{"matched": true}})
+	if err != nil {
+		return wrapError(err, "MatchBurn")
+	}
+	return nil
+}
+
+func (*SyncAPIImpl) MatchRedeemed(tokenCfg *params.TokenConfig, txhash, burnTxHash string) error {
+	coll, err := selectCollection(TypeRedeemed, tokenCfg)
+	if err != nil {
+		return wrapError(err, "MatchRedeemed", "selectCollection")
+	}
+
+	selector := bson.M{"_id": strings.ToLower(txhash), "chain": tokenCfg.Chain}
+	err = coll.Update(selector, bson.M{"$set": bson.M{"matched": true, "ref_tx": strings.ToLower(burnTxHash)}})
+	if err != nil {
+		return wrapError(err, "MatchRedeemed")
+	}
+	return nil
+}
+
+// AddAnomaly add anomaly, return ErrItemIsDup if it is detected already
+func (*SyncAPIImpl) AddAnomaly(anomaly *Anomaly) error {
+	err := collAnomalies.Insert(anomaly)
+	if mgo.IsDup(err) {
+		return ErrItemIsDup
+	}
+	if err != nil {
+		return wrapError(err, "AddAnomaly")
+	}
+	return nil
+}
+
+func (*SyncAPIImpl) GetUnresolvedAnomalies(pairID string) ([]*Anomaly, error) {
+	var result []*Anomaly
+	err := collAnomalies.Find(bson.M{"pair_id": pairID, "resolved": false}).Sort("block_time").All(&result)
+	if err != nil {
+		return nil, wrapError(err, "GetUnresolvedAnomalies")
+	}
+	return result, nil
+}
+
+// SetAddressTx add or replace the watched tx, which may be packed into another block after reorg
+func (*SyncAPIImpl) SetAddressTx(addressTx *AddressTx) error {
+	info, err := collAddressTxs.UpsertId(addressTx.Key, addressTx)
+	if err != nil {
+		return wrapError(err, "SetAddressTx", spew.Sprintf("%v", info))
+	}
+	return nil
+}
+
+func (*SyncAPIImpl) ResolveAnomaly(key string, resolveTime int64) error {
+	err := collAnomalies.UpdateId(key, bson.M{"$set": bson.M{"resolved": true, "resolve_time": resolveTime}})
+	if err != nil {
+		return wrapError(err, "ResolveAnomaly")
+	}
+	return nil
+}
+
 func (*SyncAPIImpl) AddDeposit(tokenCfg *params.TokenConfig, data *SwapEvent) error {
 	return addSwapEvent(TypeDeposit, tokenCfg, data)
 }
@@ -304,9 +646,25 @@ func (*SyncAPIImpl) AddRedeemed(tokenCfg *params.TokenConfig, data *SwapEvent) e
 	return addSwapEvent(TypeRedeemed, tokenCfg, data)
 }
 
+func (*SyncAPIImpl) UpdateDeposit(tokenCfg *params.TokenConfig, data *SwapEvent) error {
+	return updateSwapEvent(TypeDeposit, tokenCfg, data)
+}
+
+func (*SyncAPIImpl) UpdateMint(tokenCfg *params.TokenConfig, data *SwapEvent) error {
+	return updateSwapEvent(TypeMint, tokenCfg, data)
+}
+
+func (*SyncAPIImpl) UpdateBurn(tokenCfg *params.TokenConfig, data *SwapEvent) error {
+	return updateSwapEvent(TypeBurn, tokenCfg, data)
+}
+
+func (*SyncAPIImpl) UpdateRedeemed(tokenCfg *params.TokenConfig, data *SwapEvent) error {
+	return updateSwapEvent(TypeRedeemed, tokenCfg, data)
+}
+
 func (*AccountingQueryAPIImpl) GetSummaryCollectionInfo() (*SummaryCollectionInfo, error) {
 	result := new(SummaryCollectionInfo)
-	err := collSummaryCollectionInfo.FindId(bson.M{"_id": summaryCollectionInfoID}).One(result)
+	err := collSummaryCollectionInfo.FindId(summaryCollectionInfoID).One(result)
 	if err != nil {
 		return nil, wrapError(err, "GetSummaryCollectionInfo")
 	}
@@ -315,7 +673,7 @@ func (*AccountingQueryAPIImpl) GetSummaryCollectionInfo() (*SummaryCollectionInf
 
 func (*AccountingQueryAPIImpl) GetSummaryInfo(sequence int64) (*SummaryInfo, error) {
 	result := new(SummaryInfo)
-	err := collSummaryInfo.FindId(bson.M{"_id": sequence}).One(result)
+	err := collSummaryInfo.FindId(sequence).One(result)
 	if err != nil {
 		return nil, wrapError(err, "GetSummaryInfo")
 	}
@@ -323,20 +681,20 @@ func (*AccountingQueryAPIImpl) GetSummaryInfo(sequence int64) (*SummaryInfo, err
 }
 
 func (*AccountingQueryAPIImpl) GetSummary(tokenCfg *params.TokenConfig, sequence int64) (*Summary, error) {
-	coll := collSummarys[tokenCfg.PairID]
+	coll := collSummary(tokenCfg)
 	if coll == nil {
 		return nil, wrapError(fmt.Errorf("collection not initiated, pairID: %v", tokenCfg.PairID), "GetSummary")
 	}
 	result := new(Summary)
-	err := coll.FindId(bson.M{"_id": sequence}).One(result)
+	err := coll.FindId(sequence).One(result)
 	if err != nil {
-		return nil, wrapError(err, "GetSummaryInfo")
+		return nil, wrapError(err, "GetSummary")
 	}
 	return result, nil
 }
 
 func (*AccountingQueryAPIImpl) GetSummarysBySequenceRange(tokenCfg *params.TokenConfig, start, end int64) (SummaryIter, error) {
-	coll := collSummarys[tokenCfg.PairID]
+	coll := collSummary(tokenCfg)
 	if coll == nil {
 		return nil, wrapError(fmt.Errorf("collection not initiated, pairID: %v", tokenCfg.PairID), "GetSummarysBySequenceRange")
 	}
@@ -347,11 +705,34 @@ func (*AccountingQueryAPIImpl) GetSummarysBySequenceRange(tokenCfg *params.Token
 	return summaryIter, nil
 }
 
+// GetConfirmedSwapEventsByBlockRange get confirmed swap events in blocks of range [start, end)
+func (*AccountingQueryAPIImpl) GetConfirmedSwapEventsByBlockRange(
+	txtype TxType,
+	tokenCfg *params.TokenConfig,
+	start, end int64) (SwapEventIter, error) {
+	coll, err := selectCollection(txtype, tokenCfg)
+	if err != nil {
+		return nil, wrapError(err, "GetConfirmedSwapEventsByBlockRange", "selectCollection")
+	}
+
+	query := bson.M{"chain": tokenCfg.Chain, "unconfirmed": bson.M{"$ne": true}, "block_number": bson.M{"$gte": start, "$lt": end}}
+	iter := coll.Find(query).Iter()
+	swapEventIter := &SwapEventIterImpl{
+		Iter: iter,
+	}
+	return swapEventIter, nil
+}
+
 func (*AccountingAPIImpl) AddSummary(tokenCfg *params.TokenConfig, summary *Summary) error {
-	coll := collSummarys[tokenCfg.PairID]
+	coll := collSummary(tokenCfg)
 	if coll == nil {
 		return wrapError(fmt.Errorf("collection not initiated, pairID: %v", tokenCfg.PairID), "AddSummary")
 	}
+	// upsert so that the interrupted summary can be made again
+	info, err := coll.UpsertId(summary.Sequence, summary)
+	if err != nil {
+		return wrapError(err, "AddSummary", spew.Sprintf("%v", info))
+	}
 	return nil
 }
 
@@ -361,7 +742,7 @@ func (*AccountingAPIImpl) UpdateSummary(
 	accMint,
 	accBurn,
 	accRedeemed float64) error {
-	coll := collSummarys[tokenCfg.PairID]
+	coll := collSummary(tokenCfg)
 	if coll == nil {
 		return wrapError(fmt.Errorf("collection not initiated, pairID: %v", tokenCfg.PairID), "UpdateSummary")
 	}
@@ -369,20 +750,91 @@ func (*AccountingAPIImpl) UpdateSummary(
 }
 
 func (*AccountingAPIImpl) AddSummaryInfo(data *SummaryInfo) error {
-	err := collSummaryInfo.Insert(data)
+	info, err := collSummaryInfo.UpsertId(data.Sequence, data)
 	if err != nil {
-		return wrapError(err, "AddSummaryInfo")
+		return wrapError(err, "AddSummaryInfo", spew.Sprintf("%v", info))
 	}
 	return nil
 }
 
 func (*AccountingAPIImpl) UpdateSummaryCollectionInfo(latestSequence int64) error {
 	info, err := collSummaryCollectionInfo.UpsertId(
-		bson.M{"_id": summaryCollectionInfoID},
-		bson.M{"$set": latestSequence},
+		summaryCollectionInfoID,
+		bson.M{"$set": bson.M{"latest_sequenceid": latestSequence}},
 	)
 	if err != nil {
 		return wrapError(err, "UpdateSummaryCollectionInfo", spew.Sprintf("%v", info))
 	}
 	return nil
 }
+
+func (*AccountingAPIImpl) MarkDirtyHeight(chain string, height int64) error {
+	return markDirtyHeight(chain, height)
+}
+
+// ClearDirtyHeight clear the dirty height of chain if it is not lowered meanwhile
+func (*AccountingAPIImpl) ClearDirtyHeight(chain string, height int64) error {
+	err := collSyncInfo.Update(
+		bson.M{"_id": chain, "dirty_height": height},
+		bson.M{"$unset": bson.M{"dirty_height": ""}})
+	if err != nil && err != mgo.ErrNotFound {
+		return wrapError(err, "ClearDirtyHeight")
+	}
+	return nil
+}
+
+func (*AlertAPIImpl) GetAlert(key string) (*Alert, error) {
+	result := new(Alert)
+	err := collAlerts.FindId(key).One(result)
+	if err != nil {
+		return nil, wrapError(err, "GetAlert")
+	}
+	return result, nil
+}
+
+func (*AlertAPIImpl) GetAlerts(activeOnly bool) ([]*Alert, error) {
+	query := bson.M{}
+	if activeOnly {
+		query["active"] = true
+	}
+	var result []*Alert
+	err := collAlerts.Find(query).Sort("-last_time").All(&result)
+	if err != nil {
+		return nil, wrapError(err, "GetAlerts")
+	}
+	return result, nil
+}
+
+func (*AlertAPIImpl) SetAlert(alert *Alert) error {
+	info, err := collAlerts.UpsertId(alert.Key, alert)
+	if err != nil {
+		return wrapError(err, "SetAlert", spew.Sprintf("%v", info))
+	}
+	return nil
+}
+
+func (*AlertAPIImpl) SilenceAlert(key string, until int64) error {
+	err := collAlerts.UpdateId(key, bson.M{"$set": bson.M{"silenced_until": until}})
+	if err != nil {
+		return wrapError(err, "SilenceAlert")
+	}
+	return nil
+}
+
+// GetUnmatchedSwaps get deposits without mint, mints without deposit or burns without redeem, which are in blocks before the time
+func (*AlertAPIImpl) GetUnmatchedSwaps(txtype TxType, tokenCfg *params.TokenConfig, before int64) (SwapEventIter, error) {
+	coll, err := selectCollection(txtype, tokenCfg)
+	if err != nil {
+		return nil, wrapError(err, "GetUnmatchedSwaps", "selectCollection")
+	}
+
+	query := bson.M{"chain": tokenCfg.Chain, "matched": bson.M{"$ne": true}, "block_time": bson.M{"$lt": before}}
+	if txtype == TypeMint {
+		query["ref_tx"] = bson.M{"$exists": true}
+	}
+	iter := coll.Find(query).Sort("block_time").Iter()
+	swapEventIter := &SwapEventIterImpl{
+		Iter: iter,
+	}
+	return swapEventIter, nil
+}
@@ -1,7 +1,9 @@
 package mongodb
 
 import (
+	"context"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/anyswap/CrossChain-Bridge/log"
@@ -15,6 +17,8 @@ var (
 	session  *mgo.Session
 
 	dialInfo *mgo.DialInfo
+
+	reconnectCount uint64 // times of session refreshed after ping error
 )
 
 // HasSession has session connected
@@ -22,13 +26,27 @@ func HasSession() bool {
 	return session != nil
 }
 
-// MongoServerInit int mongodb server session
-func MongoServerInit(cfg *params.ScanConfig, addrs []string, dbname, user, pass string) {
+// GetReconnectCount get times of reconnecting to database
+func GetReconnectCount() uint64 {
+	return atomic.LoadUint64(&reconnectCount)
+}
+
+// MongoServerInit int mongodb server session, the session is checked until ctx is done
+func MongoServerInit(ctx context.Context, cfg *params.ScanConfig, addrs []string, dbname, user, pass string) {
 	initDialInfo(addrs, dbname, user, pass)
 	mongoConnect(cfg)
 	initCollections(cfg)
 	initCollections2(cfg)
-	go checkMongoSession(cfg)
+	initCollections3()
+	go checkMongoSession(ctx)
+}
+
+// MongoServerClose close mongodb server session
+func MongoServerClose() {
+	if session != nil {
+		session.Close()
+		log.Info("[mongodb] session closed.", "dbName", dialInfo.Database)
+	}
 }
 
 func initDialInfo(addrs []string, db, user, pass string) {
@@ -59,13 +77,19 @@ func mongoConnect(cfg *params.ScanConfig) {
 	database = session.DB(dialInfo.Database)
 	deinintCollections(cfg)
 	deinintCollections2(cfg)
+	deinintCollections3()
 	log.Info("[mongodb] connect database finished.", "dbName", dialInfo.Database)
 }
 
 // fix 'read tcp 127.0.0.1:43502->127.0.0.1:27917: i/o timeout'
-func checkMongoSession(cfg *params.ScanConfig) {
+func checkMongoSession(ctx context.Context) {
 	for {
-		time.Sleep(60 * time.Second)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(60 * time.Second):
+		}
+		cfg := params.GetScanConfig() // may be reloaded
 		if err := ensureMongoConnected(cfg); err != nil {
 			log.Info("[mongodb] check session error", "err", err)
 			log.Info("[mongodb] reconnect database", "dbName", dialInfo.Database)
@@ -95,6 +119,7 @@ func ensureMongoConnected(cfg *params.ScanConfig) (err error) {
 	if err != nil {
 		log.Error("[mongodb] session ping error", "err", err)
 		log.Info("[mongodb] refresh session.", "dbName", dialInfo.Database)
+		atomic.AddUint64(&reconnectCount, 1)
 		session.Refresh()
 		database = session.DB(dialInfo.Database)
 		deinintCollections(cfg)
@@ -11,16 +11,45 @@ type QueryAPI interface {
 
 type SyncAPI interface {
 	BaseQueryAPI
-	SetStartHeight(srcStartHeight, dstStartHeight int64) error
-	UpdateSyncedHeight(srcSyncedHeight, dstSyncedHeight int64) error
+	SetStartHeight(chain string, startHeight int64) error
+	UpdateSyncedHeight(chain string, syncedHeight int64) error
+	UpdateConfirmedHeight(chain string, confirmedHeight int64) error
+	MarkDirtyHeight(chain string, height int64) error
+	SetTokenBackfillRange(tokenCfg *params.TokenConfig, startHeight, endHeight int64) error
+	UpdateTokenSyncedHeight(tokenCfg *params.TokenConfig, syncedHeight int64) error
+	AddBlockGap(chain string, start, end int64, txIndex int, blockHash, reason string) error
+	RemoveBlockGap(key string) error
+	SetTokenMeta(meta *TokenMeta) error
 	AddDeposit(tokenCfg *params.TokenConfig, data *SwapEvent) error
 	AddMint(tokenCfg *params.TokenConfig, data *SwapEvent) error
 	AddBurn(tokenCfg *params.TokenConfig, data *SwapEvent) error
 	AddRedeemed(tokenCfg *params.TokenConfig, data *SwapEvent) error
+	UpdateDeposit(tokenCfg *params.TokenConfig, data *SwapEvent) error
+	UpdateMint(tokenCfg *params.TokenConfig, data *SwapEvent) error
+	UpdateBurn(tokenCfg *params.TokenConfig, data *SwapEvent) error
+	UpdateRedeemed(tokenCfg *params.TokenConfig, data *SwapEvent) error
+	GetUnconfirmedSwapEvents(txtype TxType, tokenCfg *params.TokenConfig, end int64) (SwapEventIter, error)
+	ConfirmSwapEvent(txtype TxType, tokenCfg *params.TokenConfig, txhash string) error
+	GetUnmatchedMints(tokenCfg *params.TokenConfig) (SwapEventIter, error)
+	MatchMint(tokenCfg *params.TokenConfig, txhash string, bridgeFee float64) error
+	MatchDeposit(tokenCfg *params.TokenConfig, txhash string) error
+	GetUnmatchedRedeems(tokenCfg *params.TokenConfig) (SwapEventIter, error)
+	GetUnmatchedBurnsByBind(tokenCfg *params.TokenConfig, bind string, end int64) (SwapEventIter, error)
+	GetSwapEventsByTxHash(txtype TxType, tokenCfg *params.TokenConfig, txhash string) (SwapEventIter, error)
+	MatchBurn(tokenCfg *params.TokenConfig, txhash string) error
+	MatchRedeemed(tokenCfg *params.TokenConfig, txhash, burnTxHash string) error
+	AddAnomaly(anomaly *Anomaly) error
+	GetUnresolvedAnomalies(pairID string) ([]*Anomaly, error)
+	ResolveAnomaly(key string, resolveTime int64) error
+	SetAddressTx(addressTx *AddressTx) error
 }
 
 type BaseQueryAPI interface {
-	GetSyncInfo() (*SyncInfo, error)
+	GetSyncInfo(chain string) (*SyncInfo, error)
+	GetTokenSyncInfo(tokenCfg *params.TokenConfig) (*TokenSyncInfo, error)
+	GetBlockGaps(chain string) ([]*BlockGap, error)
+	GetTokenMeta(tokenCfg *params.TokenConfig) (*TokenMeta, error)
+	GetTokenMetas(chain string) ([]*TokenMeta, error)
 	GetDeposit(tokenCfg *params.TokenConfig, txhash string) (*SwapEvent, error)
 	GetDepositsByBlockRange(tokenCfg *params.TokenConfig, start, end int64) (SwapEventIter, error)
 	GetDepositsByTimeRange(tokenCfg *params.TokenConfig, start, end int64) (SwapEventIter, error)
@@ -49,6 +78,8 @@ type AccountingAPI interface {
 	UpdateSummary(tokenCfg *params.TokenConfig, accDeposit, accMint, accBurn, accRedeemed float64) error
 	AddSummaryInfo(*SummaryInfo) error
 	UpdateSummaryCollectionInfo(int64) error
+	MarkDirtyHeight(chain string, height int64) error
+	ClearDirtyHeight(chain string, height int64) error
 }
 
 type AccountingQueryAPI interface {
@@ -56,10 +87,23 @@ type AccountingQueryAPI interface {
 	GetSummaryInfo(sequence int64) (*SummaryInfo, error)
 	GetSummary(tokenCfg *params.TokenConfig, sequence int64) (*Summary, error)
 	GetSummarysBySequenceRange(tokenCfg *params.TokenConfig, start, end int64) (SummaryIter, error)
+	GetConfirmedSwapEventsByBlockRange(txtype TxType, tokenCfg *params.TokenConfig, start, end int64) (SwapEventIter, error)
 }
 
+type AlertAPI interface {
+	GetAlert(key string) (*Alert, error)
+	GetAlerts(activeOnly bool) ([]*Alert, error)
+	SetAlert(alert *Alert) error
+	SilenceAlert(key string, until int64) error
+	GetUnmatchedSwaps(txtype TxType, tokenCfg *params.TokenConfig, before int64) (SwapEventIter, error)
+}
+
+// SwapEventIter iterate swap events, Next returns false when done or failed,
+// Err returns the failure, Close releases the iterator and returns the failure too.
 type SwapEventIter interface {
 	Next(*SwapEvent) bool
+	Err() error
+	Close() error
 }
 
 type SummaryIter interface {
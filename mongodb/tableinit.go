@@ -1,38 +1,68 @@
 package mongodb
 
 import (
+	"sync"
+
 	"gopkg.in/mgo.v2"
 
 	"github.com/gaozhengxin/bridgeAccounting/params"
 )
 
 var (
-	collSyncInfo  *mgo.Collection
-	collDeposits  = make(map[string]*mgo.Collection)
-	collRedeemeds = make(map[string]*mgo.Collection)
-	collMints     = make(map[string]*mgo.Collection)
-	collBurns     = make(map[string]*mgo.Collection)
+	collSyncInfo      *mgo.Collection
+	collTokenSyncInfo *mgo.Collection
+	collBlockGaps     *mgo.Collection
+	collTokenMeta     *mgo.Collection
+	collDeposits      = make(map[string]*mgo.Collection)
+	collRedeemeds     = make(map[string]*mgo.Collection)
+	collMints         = make(map[string]*mgo.Collection)
+	collBurns         = make(map[string]*mgo.Collection)
+
+	// protect the collection maps, which are changed when reconnect or reload config
+	collLock sync.RWMutex
 )
 
+// get collection from map, create it if not exist (eg. token added by reloading config)
+func getOrInitCollection(colls map[string]*mgo.Collection, tokenCfg *params.TokenConfig, table string) *mgo.Collection {
+	collLock.RLock()
+	coll := colls[tokenCfg.PairID]
+	collLock.RUnlock()
+	if coll != nil || database == nil {
+		return coll
+	}
+	collLock.Lock()
+	defer collLock.Unlock()
+	if coll = colls[tokenCfg.PairID]; coll == nil {
+		coll = database.C(table)
+		colls[tokenCfg.PairID] = coll
+	}
+	return coll
+}
+
 func collDeposit(tokenCfg *params.TokenConfig) *mgo.Collection {
-	return collDeposits[tokenCfg.PairID]
+	return getOrInitCollection(collDeposits, tokenCfg, tbDeposit(tokenCfg))
 }
 
 func collRedeemed(tokenCfg *params.TokenConfig) *mgo.Collection {
-	return collRedeemeds[tokenCfg.PairID]
+	return getOrInitCollection(collRedeemeds, tokenCfg, tbRedeemed(tokenCfg))
 }
 
 func collMint(tokenCfg *params.TokenConfig) *mgo.Collection {
-	return collMints[tokenCfg.PairID]
+	return getOrInitCollection(collMints, tokenCfg, tbMint(tokenCfg))
 }
 
 func collBurn(tokenCfg *params.TokenConfig) *mgo.Collection {
-	return collBurns[tokenCfg.PairID]
+	return getOrInitCollection(collBurns, tokenCfg, tbBurn(tokenCfg))
 }
 
 // do this when reconnect to the database
 func deinintCollections(scanConfig *params.ScanConfig) {
+	collLock.Lock()
+	defer collLock.Unlock()
 	collSyncInfo = database.C(tbSyncInfo)
+	collTokenSyncInfo = database.C(tbTokenSyncInfo)
+	collBlockGaps = database.C(tbBlockGaps)
+	collTokenMeta = database.C(tbTokenMeta)
 	for _, tk := range scanConfig.Tokens {
 		collDeposits[tk.PairID] = database.C(tbDeposit(tk))
 		collRedeemeds[tk.PairID] = database.C(tbRedeemed(tk))
@@ -43,14 +73,30 @@ func deinintCollections(scanConfig *params.ScanConfig) {
 
 func initCollections(scanConfig *params.ScanConfig) {
 	initCollection(tbSyncInfo, collSyncInfo)
-	for _, tk := range scanConfig.Tokens {
-		initCollection(tbDeposit(tk), collDeposit(tk))
-		initCollection(tbRedeemed(tk), collRedeemed(tk))
-		initCollection(tbMint(tk), collMint(tk))
-		initCollection(tbBurn(tk), collBurn(tk))
+	initCollection(tbTokenSyncInfo, collTokenSyncInfo)
+	initCollection(tbBlockGaps, collBlockGaps, "chain", "start")
+	initCollection(tbTokenMeta, collTokenMeta, "chain")
+	InitTokenCollections(scanConfig.Tokens)
+}
+
+// InitTokenCollections create and index swap collections of tokens
+func InitTokenCollections(tokenCfgs []*params.TokenConfig) {
+	for _, tk := range tokenCfgs {
+		initSwapCollection(collDeposit(tk))
+		initSwapCollection(collRedeemed(tk))
+		initSwapCollection(collMint(tk))
+		initSwapCollection(collBurn(tk))
+		initCollection(tbSummary(tk), collSummary(tk))
 	}
 }
 
+func initSwapCollection(collection *mgo.Collection) {
+	_ = collection.EnsureIndexKey("block_number")
+	_ = collection.EnsureIndexKey("chain", "block_number")
+	_ = collection.EnsureIndexKey("block_time")
+	_ = collection.EnsureIndexKey("user", "block_time")
+}
+
 func initCollection(table string, collection *mgo.Collection, indexKey ...string) {
 	collection = database.C(table)
 	if len(indexKey) != 0 && indexKey[0] != "" {
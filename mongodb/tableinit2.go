@@ -13,11 +13,13 @@ var (
 )
 
 func collSummary(tokenCfg *params.TokenConfig) *mgo.Collection {
-	return collSummarys[tokenCfg.PairID]
+	return getOrInitCollection(collSummarys, tokenCfg, tbSummary(tokenCfg))
 }
 
 // do this when reconnect to the database
 func deinintCollections2(scanConfig *params.ScanConfig) {
+	collLock.Lock()
+	defer collLock.Unlock()
 	collSummaryInfo = database.C(tbSummaryInfo)
 	collSummaryCollectionInfo = database.C(tbSummaryCollectionInfo)
 	for _, tk := range scanConfig.Tokens {
@@ -28,7 +30,4 @@ func deinintCollections2(scanConfig *params.ScanConfig) {
 func initCollections2(scanConfig *params.ScanConfig) {
 	initCollection(tbSummaryInfo, collSummaryInfo)
 	initCollection(tbSummaryCollectionInfo, collSummaryCollectionInfo)
-	for _, tk := range scanConfig.Tokens {
-		initCollection(tbSummary(tk), collSummary(tk))
-	}
 }
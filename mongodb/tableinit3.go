@@ -0,0 +1,26 @@
+package mongodb
+
+import (
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	collAlerts     *mgo.Collection
+	collAnomalies  *mgo.Collection
+	collAddressTxs *mgo.Collection
+)
+
+// do this when reconnect to the database
+func deinintCollections3() {
+	collLock.Lock()
+	defer collLock.Unlock()
+	collAlerts = database.C(tbAlerts)
+	collAnomalies = database.C(tbAnomalies)
+	collAddressTxs = database.C(tbAddressTxs)
+}
+
+func initCollections3() {
+	initCollection(tbAlerts, collAlerts, "active")
+	initCollection(tbAnomalies, collAnomalies, "pair_id", "resolved")
+	initCollection(tbAddressTxs, collAddressTxs, "chain", "address", "block_number")
+}
@@ -15,7 +15,10 @@ Swap events on dst chain
 */
 
 const (
-	tbSyncInfo string = "SyncInfo"
+	tbSyncInfo      string = "SyncInfo"
+	tbTokenSyncInfo string = "TokenSyncInfo"
+	tbBlockGaps     string = "BlockGaps"
+	tbTokenMeta     string = "TokenMeta"
 )
 
 func tbDeposit(tokenCfg *params.TokenConfig) string {
@@ -34,21 +37,69 @@ func tbBurn(tokenCfg *params.TokenConfig) string {
 	return "Burn_" + tokenCfg.PairID
 }
 
-var syncInfoID string = "sync_info_id"
-
 type SyncInfo struct {
-	ID                   string `bson:"_id"` // always syncInfoID
-	SrcChainSyncedHeight int64  `bson:"src_synced_height"`
-	SrcChainStartHeight  int64  `bson:"src_start_height"`
-	DstChainSyncedHeight int64  `bson:"dst_synced_height"`
-	DstChainStartHeight  int64  `bson:"dst_start_height"`
+	Chain           string `bson:"_id"` // name of chain config
+	SyncedHeight    int64  `bson:"synced_height"`
+	StartHeight     int64  `bson:"start_height"`
+	ConfirmedHeight int64  `bson:"confirmed_height"` // swap events not above it are all confirmed
+	// lowest height whose swap events are recorded or changed since the summaries are made, 0 if none
+	DirtyHeight int64 `bson:"dirty_height,omitempty"`
+}
+
+// TokenSyncInfo backfill progress of token history in range [StartHeight, EndHeight)
+type TokenSyncInfo struct {
+	Key          string `bson:"_id"` // key of token config
+	Chain        string `bson:"chain"`
+	PairID       string `bson:"pair_id"`
+	StartHeight  int64  `bson:"start_height"`
+	EndHeight    int64  `bson:"end_height"`
+	SyncedHeight int64  `bson:"synced_height"` // scanned range is [StartHeight, SyncedHeight)
+}
+
+// BlockGap blocks in range [Start, End) which are not (fully) scanned
+type BlockGap struct {
+	Key       string `bson:"_id"` // chain:start
+	Chain     string `bson:"chain"`
+	Start     int64  `bson:"start"`
+	End       int64  `bson:"end"`
+	TxIndex   int    `bson:"tx_index"`             // resume the start block from this tx index
+	BlockHash string `bson:"block_hash,omitempty"` // resume only if the start block is not reorged
+	Reason    string `bson:"reason"`
+	Timestamp int64  `bson:"timestamp"`
 }
 
 type SwapEvent struct {
 	TxHash      string  `bson:"_id"`
+	Chain       string  `bson:"chain"` // swap events of pair on all chains are in one collection
 	BlockTime   int64   `bson:"block_time"`
 	BlockNumber int64   `bson:"block_number"`
 	Amount      string  `bson:"amount"`
+	Decimals    int     `bson:"decimals"` // decimals of amount
 	FAmount     float64 `bson:"famount"`
 	User        string  `bson:"user"`
+	Bind        string  `bson:"bind,omitempty"`         // Burn only, the redeemed user on the destination chain
+	InvalidBind bool    `bson:"invalid_bind,omitempty"` // Burn only, bind is not address of the destination chain
+	RefTxHash   string  `bson:"ref_tx,omitempty"`       // Mint, and Redeemed of matched Burn
+	FromChainID string  `bson:"from_chainid,omitempty"` // router only
+	ToChainID   string  `bson:"to_chainid,omitempty"`   // router only
+	Unconfirmed bool    `bson:"unconfirmed,omitempty"`  // block is not final when recorded
+	GasUsed     uint64  `bson:"gas_used,omitempty"`
+	GasPrice    string  `bson:"gas_price,omitempty"`   // effective gas price
+	TxFee       string  `bson:"tx_fee,omitempty"`      // gas used * gas price
+	FTxFee      float64 `bson:"ftx_fee,omitempty"`     // tx fee in native coin
+	Matched     bool    `bson:"matched,omitempty"`     // Mint and Redeemed: the deposit or burn of RefTxHash is found, Deposit and Burn: its mint or redeem is found
+	BridgeFee   float64 `bson:"bridge_fee,omitempty"`  // Mint only, deposit amount minus mint amount
+	MPCAddress  string  `bson:"mpc_address,omitempty"` // Deposit and Redeemed only, the deposit address received or the address sent from
+}
+
+// TokenMeta metadata of token read from its contract
+type TokenMeta struct {
+	Key          string `bson:"_id"` // key of token config
+	Chain        string `bson:"chain"`
+	PairID       string `bson:"pair_id"`
+	TokenAddress string `bson:"token_address"`
+	Decimals     int    `bson:"decimals"`
+	Symbol       string `bson:"symbol"`
+	Name         string `bson:"name"`
+	Timestamp    int64  `bson:"timestamp"`
 }
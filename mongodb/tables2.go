@@ -19,15 +19,18 @@ type Summary struct {
 	AccMint     float64
 	AccBurn     float64
 	AccRedeemed float64
+
+	AccBridgeFee float64            // bridge fee of the matched mints
+	AccMPCGasFee map[string]float64 // chain name -> gas fee in native coin paid by MPC
+	// mpc address -> deposits to it minus redeems from it, of all the historical addresses
+	AccMPCBalance map[string]float64 `bson:",omitempty"`
 }
 
 type SummaryInfo struct {
-	Sequence       int64  `bson:"_id"`
-	Tag            string `bson:"tag"` // for example, date
-	SrcStartHeight int64  `bson:"src_start_height"`
-	SrcEndHeight   int64  `bson:"src_end_height"`
-	DstStartHeight int64  `bson:"dst_start_height"`
-	DstEndHeight   int64  `bson:"dst_end_height"`
+	Sequence     int64            `bson:"_id"`
+	Tag          string           `bson:"tag"`           // for example, date
+	StartHeights map[string]int64 `bson:"start_heights"` // chain name -> height
+	EndHeights   map[string]int64 `bson:"end_heights"`   // chain name -> height
 }
 
 var summaryCollectionInfoID string = "summary_collection_info"
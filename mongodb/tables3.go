@@ -0,0 +1,82 @@
+package mongodb
+
+const (
+	tbAlerts string = "Alerts"
+)
+
+// Alert state of alert, which is de-duplicated by key and not notified again
+// within the silence duration or before SilencedUntil
+type Alert struct {
+	Key           string `bson:"_id"` // rule:subject
+	Rule          string `bson:"rule"`
+	Subject       string `bson:"subject"`
+	Message       string `bson:"message"`
+	Active        bool   `bson:"active"`
+	Count         int64  `bson:"count"` // times fired since it is active
+	FirstTime     int64  `bson:"first_time"`
+	LastTime      int64  `bson:"last_time"`
+	NotifyTime    int64  `bson:"notify_time"`
+	SilencedUntil int64  `bson:"silenced_until"`
+}
+
+const (
+	tbAnomalies string = "Anomalies"
+)
+
+// kinds of anomaly
+const (
+	AnomalyTransferWithoutBurn = "transfer-without-burn" // transfer from deposit address which is not redeem of any burn
+	AnomalyMintWithoutDeposit  = "mint-without-deposit"
+	// native or token transfer from mpc address which is not recorded as redeem, it is never resolved
+	AnomalyUnclassifiedTransfer = "unclassified-transfer"
+)
+
+// Anomaly swap event which is not matched after the anomaly delay,
+// it is resolved if the swap event is matched later
+type Anomaly struct {
+	Key         string  `bson:"_id"` // kind:txhash
+	Kind        string  `bson:"kind"`
+	PairID      string  `bson:"pair_id"`
+	TxHash      string  `bson:"txhash"`
+	BlockNumber int64   `bson:"block_number"`
+	BlockTime   int64   `bson:"block_time"`
+	User        string  `bson:"user"`
+	FAmount     float64 `bson:"famount"`
+	DetectTime  int64   `bson:"detect_time"`
+	Resolved    bool    `bson:"resolved"`
+	ResolveTime int64   `bson:"resolve_time,omitempty"`
+}
+
+const (
+	tbAddressTxs string = "AddressTxs"
+)
+
+// status of watched tx
+const (
+	AddressTxSuccess = "success"
+	AddressTxFailed  = "failed"
+)
+
+// AddressTx tx sent from or to the watched address, recorded in address watch mode
+type AddressTx struct {
+	Key            string           `bson:"_id"` // chain:txhash:address
+	Chain          string           `bson:"chain"`
+	Address        string           `bson:"address"`
+	TxHash         string           `bson:"txhash"`
+	BlockNumber    int64            `bson:"block_number"`
+	BlockTime      int64            `bson:"block_time"`
+	From           string           `bson:"from"`
+	To             string           `bson:"to,omitempty"`       // empty if contract creation
+	Value          string           `bson:"value"`              // native value in wei
+	Selector       string           `bson:"selector,omitempty"` // called method selector
+	Status         string           `bson:"status,omitempty"`   // success or failed, empty if receipt is not available
+	TokenTransfers []*TokenTransfer `bson:"token_transfers,omitempty"`
+}
+
+// TokenTransfer erc20 transfer log from or to the watched address
+type TokenTransfer struct {
+	Token  string `bson:"token"`
+	From   string `bson:"from"`
+	To     string `bson:"to"`
+	Amount string `bson:"amount"`
+}
@@ -0,0 +1,123 @@
+package params
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/anyswap/CrossChain-Bridge/common"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// swap types of abi mapping
+const (
+	SwapTypeDeposit  = "Deposit"
+	SwapTypeMint     = "Mint"
+	SwapTypeBurn     = "Burn"
+	SwapTypeRedeemed = "Redeemed"
+)
+
+// ABIMapping map an abi event or method to a swap type
+type ABIMapping struct {
+	SwapType string // Deposit, Mint, Burn or Redeemed
+	Event    string `toml:",omitempty" json:",omitempty"` // decode from receipt logs
+	Method   string `toml:",omitempty" json:",omitempty"` // decode from tx input
+	Contract string `toml:",omitempty" json:",omitempty"` // log emitter or tx receiver, default is 'TokenAddress'
+
+	UserArg     string `toml:",omitempty" json:",omitempty"` // default is tx sender
+	AmountArg   string
+	RefTxArg    string `toml:",omitempty" json:",omitempty"`
+	BindArg     string `toml:",omitempty" json:",omitempty"`
+	ReceiverArg string `toml:",omitempty" json:",omitempty"` // must equal to the deposit address if specified
+}
+
+// IsEvent is mapping of event
+func (m *ABIMapping) IsEvent() bool {
+	return m.Event != ""
+}
+
+// GetABI get parsed abi of 'ABIFile'
+func (c *TokenConfig) GetABI() *abi.ABI {
+	return c.parsedABI
+}
+
+// HasABIMappings has abi mappings
+func (c *TokenConfig) HasABIMappings() bool {
+	return len(c.ABIMappings) != 0
+}
+
+func (c *TokenConfig) checkABIConfig() error {
+	if c.ABIFile == "" {
+		if c.HasABIMappings() {
+			return errors.New("'ABIMappings' specified without 'ABIFile'")
+		}
+		return nil
+	}
+	if !c.HasABIMappings() {
+		return errors.New("'ABIFile' specified without 'ABIMappings'")
+	}
+	file, err := os.Open(c.ABIFile)
+	if err != nil {
+		return fmt.Errorf("open 'ABIFile' failed. %w", err)
+	}
+	defer file.Close()
+	parsed, err := abi.JSON(file)
+	if err != nil {
+		return fmt.Errorf("parse 'ABIFile' %v failed. %w", c.ABIFile, err)
+	}
+	for _, m := range c.ABIMappings {
+		if err = m.checkConfig(&parsed); err != nil {
+			return err
+		}
+	}
+	c.parsedABI = &parsed
+	return nil
+}
+
+func (m *ABIMapping) checkConfig(parsed *abi.ABI) error {
+	switch m.SwapType {
+	case SwapTypeDeposit, SwapTypeMint, SwapTypeBurn, SwapTypeRedeemed:
+	default:
+		return errors.New("wrong abi mapping 'SwapType' " + m.SwapType)
+	}
+	if m.Contract != "" && !common.IsHexAddress(m.Contract) {
+		return errors.New("wrong abi mapping 'Contract' " + m.Contract)
+	}
+	var args abi.Arguments
+	switch {
+	case m.Event != "" && m.Method != "":
+		return errors.New("abi mapping has both 'Event' and 'Method'")
+	case m.Event != "":
+		event, exist := parsed.Events[m.Event]
+		if !exist {
+			return errors.New("abi mapping event not found " + m.Event)
+		}
+		args = event.Inputs
+	case m.Method != "":
+		method, exist := parsed.Methods[m.Method]
+		if !exist {
+			return errors.New("abi mapping method not found " + m.Method)
+		}
+		args = method.Inputs
+	default:
+		return errors.New("abi mapping has neither 'Event' nor 'Method'")
+	}
+	if m.AmountArg == "" {
+		return errors.New("abi mapping has empty 'AmountArg'")
+	}
+	for _, argName := range []string{m.UserArg, m.AmountArg, m.RefTxArg, m.BindArg, m.ReceiverArg} {
+		if argName != "" && !hasArgument(args, argName) {
+			return fmt.Errorf("abi mapping argument '%v' not found in %v%v", argName, m.Event, m.Method)
+		}
+	}
+	return nil
+}
+
+func hasArgument(args abi.Arguments, name string) bool {
+	for _, arg := range args {
+		if arg.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package params
+
+import (
+	"errors"
+	"net/mail"
+	"time"
+)
+
+const defaultAlertSilenceMinutes = 60
+
+// AlertConfig alert rules and notification channels, the rules with zero threshold are disabled
+type AlertConfig struct {
+	SilenceMinutes int64 `toml:",omitempty" json:",omitempty"` // an active alert is not notified again within it, default is 60
+
+	MaxScanLag           uint64             `toml:",omitempty" json:",omitempty"` // blocks behind the latest block
+	NoNewBlockMinutes    int64              `toml:",omitempty" json:",omitempty"` // the latest block is not changed
+	MongoReconnect       bool               `toml:",omitempty" json:",omitempty"` // reconnect to mongodb
+	MaxDrift             float64            `toml:",omitempty" json:",omitempty"` // unbalanced amount of pair in summary
+	UnmatchedSwapMinutes int64              `toml:",omitempty" json:",omitempty"` // deposit without mint, mint without deposit or burn without redeem
+	SwapAmountLimits     map[string]float64 `toml:",omitempty" json:",omitempty"` // pair ID -> amount limit of single swap
+
+	Webhooks      []string    `toml:",omitempty" json:",omitempty"` // post alert in json
+	SlackWebhooks []string    `toml:",omitempty" json:",omitempty"` // post alert text in slack payload
+	SMTP          *SMTPConfig `toml:",omitempty" json:",omitempty"`
+}
+
+// SMTPConfig send alert by email
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	UserName string `json:"-"`
+	Password string `json:"-"`
+	From     string
+	To       []string
+}
+
+// GetSilenceDuration get silence duration of active alert
+func (c *AlertConfig) GetSilenceDuration() time.Duration {
+	if c.SilenceMinutes == 0 {
+		return defaultAlertSilenceMinutes * time.Minute
+	}
+	return time.Duration(c.SilenceMinutes) * time.Minute
+}
+
+// CheckConfig check alert config
+func (c *AlertConfig) CheckConfig() error {
+	if c.SilenceMinutes < 0 || c.NoNewBlockMinutes < 0 || c.UnmatchedSwapMinutes < 0 || c.MaxDrift < 0 {
+		return errors.New("alert thresholds must not be negative")
+	}
+	for pairID, limit := range c.SwapAmountLimits {
+		if limit <= 0 {
+			return errors.New("alert 'SwapAmountLimits' must be positive of pair " + pairID)
+		}
+	}
+	if len(c.Webhooks) == 0 && len(c.SlackWebhooks) == 0 && c.SMTP == nil {
+		return errors.New("no alert notification channel exist")
+	}
+	if c.SMTP != nil {
+		if err := c.SMTP.CheckConfig(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckConfig check smtp config
+func (c *SMTPConfig) CheckConfig() error {
+	if c.Host == "" || c.Port <= 0 {
+		return errors.New("wrong alert smtp 'Host' or 'Port'")
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return errors.New("wrong alert smtp 'From' " + c.From)
+	}
+	if len(c.To) == 0 {
+		return errors.New("empty alert smtp 'To'")
+	}
+	for _, to := range c.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return errors.New("wrong alert smtp 'To' " + to)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package params
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/anyswap/CrossChain-Bridge/common"
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/btcsuite/btcutil/bech32"
+)
+
+// bind address formats of the destination chain of swapout
+const (
+	BindFormatEVM        = "evm"
+	BindFormatBTC        = "btc"
+	BindFormatBTCTestnet = "btc-testnet"
+	BindFormatLTC        = "ltc"
+	BindFormatLTCTestnet = "ltc-testnet"
+)
+
+// utxoAddressFormat the base58 address versions and the bech32 segwit prefix of btc like chain
+type utxoAddressFormat struct {
+	pubKeyHashVersions []byte
+	scriptHashVersions []byte
+	bech32HRP          string
+}
+
+var utxoAddressFormats = map[string]*utxoAddressFormat{
+	BindFormatBTC:        {[]byte{0x00}, []byte{0x05}, "bc"},
+	BindFormatBTCTestnet: {[]byte{0x6f}, []byte{0xc4}, "tb"},
+	BindFormatLTC:        {[]byte{0x30}, []byte{0x32, 0x05}, "ltc"},
+	BindFormatLTCTestnet: {[]byte{0x6f}, []byte{0x3a, 0xc4}, "tltc"},
+}
+
+// GetBindFormat get bind address format of swapout,
+// default is the address format of btc like src chain of pair, or else evm
+func (c *TokenConfig) GetBindFormat() string {
+	switch {
+	case c.BindFormat != "":
+		return c.BindFormat
+	case c.defaultBindFormat != "":
+		return c.defaultBindFormat
+	default:
+		return BindFormatEVM
+	}
+}
+
+// NormalizeBindAddress check bind address in the bind format of token,
+// and convert it to the canonical form (checksummed hex, or lower case bech32)
+func (c *TokenConfig) NormalizeBindAddress(bind string) (string, error) {
+	format := c.GetBindFormat()
+	if format == BindFormatEVM {
+		if !common.IsHexAddress(bind) {
+			return "", errors.New("not hex address")
+		}
+		return common.HexToAddress(bind).Hex(), nil
+	}
+	utxoFormat, exist := utxoAddressFormats[format]
+	if !exist {
+		return "", errors.New("unknown bind format " + format)
+	}
+	normalized, _, err := utxoFormat.decodeAddress(bind)
+	return normalized, err
+}
+
+// support P2PKH and P2SH in base58, and segwit v0 P2WPKH and P2WSH in bech32,
+// return the normalized address and whether it is P2PKH or P2WPKH
+func (f *utxoAddressFormat) decodeAddress(address string) (normalized string, isPubKeyHash bool, err error) {
+	if strings.HasPrefix(strings.ToLower(address), f.bech32HRP+"1") {
+		hrp, data, err := bech32.Decode(address)
+		if err != nil {
+			return "", false, err
+		}
+		if hrp != f.bech32HRP || len(data) == 0 {
+			return "", false, errors.New("wrong bech32 address")
+		}
+		if data[0] != 0 {
+			return "", false, fmt.Errorf("unsupported witness version %v", data[0])
+		}
+		program, err := bech32.ConvertBits(data[1:], 5, 8, false)
+		if err != nil {
+			return "", false, err
+		}
+		if len(program) != 20 && len(program) != 32 {
+			return "", false, fmt.Errorf("wrong witness program length %v", len(program))
+		}
+		return strings.ToLower(address), len(program) == 20, nil
+	}
+	decoded, version, err := base58.CheckDecode(address)
+	if err != nil {
+		return "", false, err
+	}
+	if len(decoded) != 20 {
+		return "", false, errors.New("wrong base58 address length")
+	}
+	isPubKeyHash = containsByte(f.pubKeyHashVersions, version)
+	if !isPubKeyHash && !containsByte(f.scriptHashVersions, version) {
+		return "", false, fmt.Errorf("wrong base58 address version %v", version)
+	}
+	return address, isPubKeyHash, nil
+}
+
+func containsByte(list []byte, b byte) bool {
+	for _, item := range list {
+		if item == b {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *TokenConfig) checkBindConfig() error {
+	if c.BindFormat == "" {
+		return nil
+	}
+	if c.IsSrcToken || c.IsRouterToken() {
+		return errors.New("'BindFormat' is only for dst swap token")
+	}
+	if _, exist := utxoAddressFormats[c.BindFormat]; !exist && c.BindFormat != BindFormatEVM {
+		return errors.New("wrong 'BindFormat' " + c.BindFormat)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package params
+
+import (
+	"errors"
+)
+
+// chain types
+const (
+	ChainTypeEVM = "evm"
+	ChainTypeBTC = "btc" // bitcoin like chain scanned by electrs (esplora) REST api
+)
+
+// GetChainType get chain type, default is evm
+func (c *ChainConfig) GetChainType() string {
+	if c.ChainType == "" {
+		return ChainTypeEVM
+	}
+	return c.ChainType
+}
+
+// IsBTCChain is bitcoin like chain
+func (c *ChainConfig) IsBTCChain() bool {
+	return c.GetChainType() == ChainTypeBTC
+}
+
+// GetNetwork get address format of bitcoin like chain, default is btc mainnet
+func (c *ChainConfig) GetNetwork() string {
+	if c.Network == "" {
+		return BindFormatBTC
+	}
+	return c.Network
+}
+
+// the blocks of bitcoin like chain are scanned from gateways when they are deeper than 'StableHeight',
+// the features working on evm blocks and receipts are not supported
+func (c *ChainConfig) checkBTCConfig() error {
+	if len(c.Gateways) == 0 {
+		return errors.New("empty 'Gateways' of chain " + c.Name)
+	}
+	if _, exist := utxoAddressFormats[c.GetNetwork()]; !exist {
+		return errors.New("unknown 'Network' " + c.Network + " of chain " + c.Name)
+	}
+	if c.StableHeight < 0 {
+		return errors.New("'StableHeight' is negative of chain " + c.Name)
+	}
+	if c.AnomalyDelayMinutes < 0 {
+		return errors.New("'AnomalyDelayMinutes' is negative of chain " + c.Name)
+	}
+	if c.ArchiveFile != "" || c.ScanReceipt || c.WatchAddresses {
+		return errors.New("'ArchiveFile', 'ScanReceipt' and 'WatchAddresses' are not supported of btc chain " + c.Name)
+	}
+	if c.GetFinalityMode() != FinalityModeDepth {
+		return errors.New("only depth finality is supported of btc chain " + c.Name)
+	}
+	return nil
+}
+
+// token on bitcoin like chain is the native coin of src chain,
+// which is deposited to and redeemed from the mpc addresses
+func (c *TokenConfig) checkBTCTokenConfig() error {
+	if !c.IsSrcToken || !c.IsNativeToken() {
+		return errors.New("token of btc chain " + c.Chain + " must be native src token")
+	}
+	if c.TokenType != TokenTypeSwap || c.CallByContract != "" || c.ABIFile != "" || c.HasABIMappings() {
+		return errors.New("token of btc chain " + c.Chain + " can not be router or call by contract or have abi")
+	}
+	if c.NeedBackfill() {
+		return errors.New("backfill is not supported of token of btc chain " + c.Chain)
+	}
+	return nil
+}
+
+// the burns of pair whose src token is on bitcoin like chain are redeemed to addresses of its format
+func (c *ScanConfig) setDefaultBindFormats() {
+	networks := make(map[string]string) // pairID -> network
+	for _, tokenCfg := range c.Tokens {
+		if tokenCfg.IsSrcToken && tokenCfg.network != "" {
+			networks[tokenCfg.PairID] = tokenCfg.network
+		}
+	}
+	for _, tokenCfg := range c.Tokens {
+		if !tokenCfg.IsSrcToken && !tokenCfg.IsRouterToken() {
+			tokenCfg.defaultBindFormat = networks[tokenCfg.PairID]
+		}
+	}
+}
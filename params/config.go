@@ -4,48 +4,129 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math/big"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/anyswap/CrossChain-Bridge/common"
 	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 )
 
 var (
 	configFile string
-	scanConfig = &ScanConfig{}
+	scanConfig atomic.Value // *ScanConfig
+)
+
+const defaultAnomalyDelayMinutes = 30
+
+// token types
+const (
+	TokenTypeSwap   = ""
+	TokenTypeRouter = "router"
+)
+
+// finality modes of chain
+const (
+	FinalityModeDepth     = "depth"     // blocks deeper than 'StableHeight' are final
+	FinalityModeFinalized = "finalized" // blocks not above the 'finalized' block tag are final
+	FinalityModeSafe      = "safe"      // blocks not above the 'safe' block tag are final
+	FinalityModeChecker   = "checker"   // the final height is provided by external checker
 )
 
 // ScanConfig scan config
 type ScanConfig struct {
-	Tokens []*TokenConfig
-	SrcGateway string
-	SrcScanReceipt bool
-	SrcStartHeightArgument int64
-	SrcEndHeight int64
-	SrcStableHeight int64
-	SrcJobCount int
-	SrcProcessBlockTimeout int64
-
-	DstGateway string
-	DstScanReceipt bool
-	DstStartHeightArgument int64
-	DstEndHeight int64
-	DstStableHeight int64
-	DstJobCount int
-	DstProcessBlockTimeout int64
+	MetricsListen string `toml:",omitempty" json:",omitempty"` // serve metrics if not empty
+
+	MongoDB    *MongoDBConfig
+	BlockCache *BlockCacheConfig `toml:",omitempty" json:",omitempty"`
+	Alert      *AlertConfig      `toml:",omitempty" json:",omitempty"`
+	Chains     []*ChainConfig
+	Tokens     []*TokenConfig
+}
+
+// MongoDBConfig mongodb config
+type MongoDBConfig struct {
+	DBURLs   []string
+	DBName   string
+	UserName string `json:"-"`
+	Password string `json:"-"`
+}
+
+// BlockCacheConfig on-disk cache of stable blocks and receipts
+type BlockCacheConfig struct {
+	Dir       string
+	MaxSizeMB int64
+}
+
+// ChainConfig chain config
+type ChainConfig struct {
+	Name                string
+	ChainID             string
+	Gateways            []string
+	ArchiveFile         string `toml:",omitempty" json:",omitempty"` // scan offline from exported archive instead of gateways
+	ScanReceipt         bool
+	StartHeightArgument int64
+	EndHeight           int64
+	StableHeight        int64
+	JobCount            int
+	ProcessBlockTimeout int64
+
+	// swap events in blocks which are not final are recorded as unconfirmed
+	FinalityMode    string `toml:",omitempty" json:",omitempty"` // default is 'depth'
+	FinalityChecker string `toml:",omitempty" json:",omitempty"` // url of external finality checker
+
+	// the redeems and mints which are not matched after the delay are anomalies
+	AnomalyDelayMinutes int64 `toml:",omitempty" json:",omitempty"` // default is 30
+
+	// record every tx sent from or to the deposit addresses of tokens of chain
+	WatchAddresses bool `toml:",omitempty" json:",omitempty"`
+
+	// 'evm' (default), or 'btc' for bitcoin like chain scanned by electrs REST api 'Gateways',
+	// whose address format is of 'Network', 'btc' (default), 'btc-testnet', 'ltc' or 'ltc-testnet'
+	ChainType string `toml:",omitempty" json:",omitempty"`
+	Network   string `toml:",omitempty" json:",omitempty"`
 }
 
 // TokenConfig token config
 type TokenConfig struct {
-	IsSrcToken bool
+	Chain          string // name of chain config
+	IsSrcToken     bool
 	PairID         string
 	SwapServer     string
 	CallByContract string `toml:",omitempty" json:",omitempty"`
 	TokenAddress   string
 	DepositAddress string `toml:",omitempty" json:",omitempty"`
-	RedeemAddress string `toml:",omitempty" json:",omitempty"`
-	Decimal int `toml:",omitempty" json:",omitempty"`
+	RedeemAddress  string `toml:",omitempty" json:",omitempty"` // redeems are sent from it or 'DepositAddress'
+	Decimal        int    `toml:",omitempty" json:",omitempty"` // override the decimals read from token contract
+
+	// history of mpc key rotations, instead of the above single addresses
+	DepositAddresses []*MPCAddress `toml:",omitempty" json:",omitempty"`
+	RedeemAddresses  []*MPCAddress `toml:",omitempty" json:",omitempty"`
+
+	// bind address format of swapout, 'evm' (default), 'btc', 'btc-testnet', 'ltc' or 'ltc-testnet'
+	BindFormat string `toml:",omitempty" json:",omitempty"`
+
+	// scan history of this token from this height in background,
+	// or from the contract creation height if 'DiscoverStartHeight' is true
+	StartHeight         uint64 `toml:",omitempty" json:",omitempty"`
+	DiscoverStartHeight bool   `toml:",omitempty" json:",omitempty"`
+
+	// router token, swap out is Burn and swap in is Mint,
+	// or Deposit and Redeemed if it is underlying token
+	TokenType      string `toml:",omitempty" json:",omitempty"`
+	RouterContract string `toml:",omitempty" json:",omitempty"`
+	IsUnderlying   bool   `toml:",omitempty" json:",omitempty"`
+
+	ABIFile     string        `toml:",omitempty" json:",omitempty"`
+	ABIMappings []*ABIMapping `toml:",omitempty" json:",omitempty"`
+
+	parsedABI *abi.ABI
+
+	network           string // address format if token is on btc like chain
+	defaultBindFormat string // address format of the btc like src chain of pair
 }
 
 // IsNativeToken is native token
@@ -53,9 +134,45 @@ func (c *TokenConfig) IsNativeToken() bool {
 	return c.TokenAddress == "native"
 }
 
+// Key identify token config
+func (c *TokenConfig) Key() string {
+	return strings.ToLower(fmt.Sprintf("%v:%v:%v:%v:%v:%v", c.Chain, c.PairID, c.TokenType, c.TokenAddress, c.CallByContract, c.depositAddressKey()))
+}
+
+// NeedBackfill need scan history of this token
+func (c *TokenConfig) NeedBackfill() bool {
+	return c.StartHeight != 0 || c.DiscoverStartHeight
+}
+
+// IsRouterToken is router token
+func (c *TokenConfig) IsRouterToken() bool {
+	return c.TokenType == TokenTypeRouter
+}
+
 // GetScanConfig get scan config
 func GetScanConfig() *ScanConfig {
-	return scanConfig
+	config, _ := scanConfig.Load().(*ScanConfig)
+	return config
+}
+
+// GetChainConfig get chain config by name
+func (c *ScanConfig) GetChainConfig(name string) *ChainConfig {
+	for _, chainCfg := range c.Chains {
+		if chainCfg.Name == name {
+			return chainCfg
+		}
+	}
+	return nil
+}
+
+// GetTokenConfigs get token configs of chain
+func (c *ScanConfig) GetTokenConfigs(chain string) (tokenCfgs []*TokenConfig) {
+	for _, tokenCfg := range c.Tokens {
+		if tokenCfg.Chain == chain {
+			tokenCfgs = append(tokenCfgs, tokenCfg)
+		}
+	}
+	return tokenCfgs
 }
 
 // LoadConfig load config
@@ -82,9 +199,9 @@ func LoadConfig(filePath string) *ScanConfig {
 		log.Fatalf("LoadConfig Check config failed. %v", err)
 	}
 
-	configFile = filePath // init config file path
-	scanConfig = config   // init scan config
-	return scanConfig
+	configFile = filePath    // init config file path
+	scanConfig.Store(config) // init scan config
+	return config
 }
 
 // ReloadConfig reload config
@@ -106,11 +223,43 @@ func ReloadConfig() {
 		return
 	}
 	log.Println("ReloadConfig success.")
-	scanConfig = config // reassign scan config
+	oldConfig := GetScanConfig()
+	scanConfig.Store(config) // reassign scan config
+	callReloadCallbacks(oldConfig, config)
 }
 
 // CheckConfig check scan config
 func (c *ScanConfig) CheckConfig() (err error) {
+	if c.MongoDB == nil {
+		return errors.New("no mongodb config exist")
+	}
+	if err = c.MongoDB.CheckConfig(); err != nil {
+		return err
+	}
+	if c.BlockCache != nil {
+		if err = c.BlockCache.CheckConfig(); err != nil {
+			return err
+		}
+	}
+	if c.Alert != nil {
+		if err = c.Alert.CheckConfig(); err != nil {
+			return err
+		}
+	}
+	if len(c.Chains) == 0 {
+		return errors.New("no chain config exist")
+	}
+	chainsMap := make(map[string]struct{})
+	for _, chainCfg := range c.Chains {
+		err = chainCfg.CheckConfig()
+		if err != nil {
+			return err
+		}
+		if _, exist := chainsMap[chainCfg.Name]; exist {
+			return errors.New("duplicate chain config " + chainCfg.Name)
+		}
+		chainsMap[chainCfg.Name] = struct{}{}
+	}
 	if len(c.Tokens) == 0 {
 		return errors.New("no token config exist")
 	}
@@ -118,31 +267,128 @@ func (c *ScanConfig) CheckConfig() (err error) {
 	tokensMap := make(map[string]struct{})
 	exist := false
 	for _, tokenCfg := range c.Tokens {
+		chainCfg := c.GetChainConfig(tokenCfg.Chain)
+		if chainCfg != nil && chainCfg.IsBTCChain() {
+			tokenCfg.network = chainCfg.GetNetwork()
+		}
 		err = tokenCfg.CheckConfig()
 		if err != nil {
 			return err
 		}
+		if chainCfg == nil {
+			return errors.New("token config with unknown 'Chain' " + tokenCfg.Chain)
+		}
 		if tokenCfg.CallByContract != "" {
 			continue
 		}
-		pairIDKey := strings.ToLower(fmt.Sprintf("%v:%v:%v:%v", tokenCfg.TokenAddress, tokenCfg.PairID, tokenCfg.SwapServer))
+		pairIDKey := strings.ToLower(fmt.Sprintf("%v:%v:%v:%v", tokenCfg.Chain, tokenCfg.TokenAddress, tokenCfg.PairID, tokenCfg.SwapServer))
 		if _, exist = pairIDMap[pairIDKey]; exist {
 			return errors.New("duplicate pairID config" + pairIDKey)
 		}
 		pairIDMap[pairIDKey] = struct{}{}
 		if !tokenCfg.IsNativeToken() {
-			tokensKey := strings.ToLower(fmt.Sprintf("%v:%v", tokenCfg.TokenAddress, tokenCfg.DepositAddress))
+			tokensKey := strings.ToLower(fmt.Sprintf("%v:%v:%v", tokenCfg.Chain, tokenCfg.TokenAddress, tokenCfg.depositAddressKey()))
 			if _, exist = tokensMap[tokensKey]; exist {
 				return errors.New("duplicate token config " + tokensKey)
 			}
 			tokensMap[tokensKey] = struct{}{}
 		}
 	}
+	c.setDefaultBindFormats()
 	return nil
 }
 
+// CheckConfig check mongodb config
+func (c *MongoDBConfig) CheckConfig() error {
+	if len(c.DBURLs) == 0 {
+		return errors.New("empty mongodb 'DBURLs'")
+	}
+	if c.DBName == "" {
+		return errors.New("empty mongodb 'DBName'")
+	}
+	return nil
+}
+
+// CheckConfig check block cache config
+func (c *BlockCacheConfig) CheckConfig() error {
+	if c.Dir == "" {
+		return errors.New("empty block cache 'Dir'")
+	}
+	if c.MaxSizeMB <= 0 {
+		return errors.New("block cache 'MaxSizeMB' must be positive")
+	}
+	return nil
+}
+
+// CheckConfig check chain config
+func (c *ChainConfig) CheckConfig() error {
+	if c.Name == "" {
+		return errors.New("empty chain 'Name'")
+	}
+	switch c.GetChainType() {
+	case ChainTypeEVM:
+	case ChainTypeBTC:
+		return c.checkBTCConfig()
+	default:
+		return errors.New("unknown 'ChainType' " + c.ChainType + " of chain " + c.Name)
+	}
+	if _, ok := new(big.Int).SetString(c.ChainID, 0); !ok {
+		return errors.New("wrong 'ChainID' " + c.ChainID + " of chain " + c.Name)
+	}
+	if len(c.Gateways) == 0 && c.ArchiveFile == "" {
+		return errors.New("empty 'Gateways' of chain " + c.Name)
+	}
+	if c.JobCount <= 0 {
+		return errors.New("'JobCount' must be positive of chain " + c.Name)
+	}
+	if c.ProcessBlockTimeout <= 0 {
+		return errors.New("'ProcessBlockTimeout' must be positive of chain " + c.Name)
+	}
+	if c.StableHeight < 0 {
+		return errors.New("'StableHeight' is negative of chain " + c.Name)
+	}
+	if c.AnomalyDelayMinutes < 0 {
+		return errors.New("'AnomalyDelayMinutes' is negative of chain " + c.Name)
+	}
+	switch c.GetFinalityMode() {
+	case FinalityModeDepth, FinalityModeFinalized, FinalityModeSafe:
+	case FinalityModeChecker:
+		if c.FinalityChecker == "" {
+			return errors.New("empty 'FinalityChecker' of chain " + c.Name)
+		}
+	default:
+		return errors.New("unknown 'FinalityMode' " + c.FinalityMode + " of chain " + c.Name)
+	}
+	return nil
+}
+
+// GetFinalityMode get finality mode, default is fixed depth
+func (c *ChainConfig) GetFinalityMode() string {
+	if c.FinalityMode == "" {
+		return FinalityModeDepth
+	}
+	return c.FinalityMode
+}
+
+// GetAnomalyDelay get delay of anomaly detection
+func (c *ChainConfig) GetAnomalyDelay() time.Duration {
+	if c.AnomalyDelayMinutes == 0 {
+		return defaultAnomalyDelayMinutes * time.Minute
+	}
+	return time.Duration(c.AnomalyDelayMinutes) * time.Minute
+}
+
+// GetChainID get chain ID
+func (c *ChainConfig) GetChainID() *big.Int {
+	chainID, _ := new(big.Int).SetString(c.ChainID, 0)
+	return chainID
+}
+
 // CheckConfig check token config
 func (c *TokenConfig) CheckConfig() error {
+	if c.Chain == "" {
+		return errors.New("empty 'Chain'")
+	}
 	if c.PairID == "" {
 		return errors.New("empty 'PairID'")
 	}
@@ -155,8 +401,37 @@ func (c *TokenConfig) CheckConfig() error {
 	if !c.IsNativeToken() && !common.IsHexAddress(c.TokenAddress) {
 		return errors.New("wrong 'TokenAddress' " + c.TokenAddress)
 	}
-	if c.DepositAddress != "" && !common.IsHexAddress(c.DepositAddress) {
-		return errors.New("wrong 'DepositAddress' " + c.DepositAddress)
+	if c.network != "" {
+		if err := c.checkBTCTokenConfig(); err != nil {
+			return err
+		}
+	}
+	if err := c.checkMPCConfig(); err != nil {
+		return err
 	}
-	return nil
+	if err := c.checkBindConfig(); err != nil {
+		return err
+	}
+	if c.DiscoverStartHeight && c.IsNativeToken() {
+		return errors.New("can not discover start height of native token")
+	}
+	switch c.TokenType {
+	case TokenTypeSwap:
+		if c.IsUnderlying {
+			return errors.New("'IsUnderlying' is only for router token")
+		}
+	case TokenTypeRouter:
+		if c.IsNativeToken() {
+			return errors.New("router token can not be native")
+		}
+		if !common.IsHexAddress(c.RouterContract) {
+			return errors.New("wrong 'RouterContract' " + c.RouterContract)
+		}
+		if c.HasABIMappings() {
+			return errors.New("router token can not have 'ABIMappings'")
+		}
+	default:
+		return errors.New("wrong 'TokenType' " + c.TokenType)
+	}
+	return c.checkABIConfig()
 }
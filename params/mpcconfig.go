@@ -0,0 +1,146 @@
+package params
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/anyswap/CrossChain-Bridge/common"
+)
+
+// MPCAddress mpc address which is effective in blocks of range [FromHeight, ToHeight],
+// or from 'FromHeight' on if 'ToHeight' is zero. the ranges may overlap after mpc key rotation,
+// as the old address is still effective for the late deposits to it.
+type MPCAddress struct {
+	Address    string
+	FromHeight uint64 `toml:",omitempty" json:",omitempty"`
+	ToHeight   uint64 `toml:",omitempty" json:",omitempty"`
+}
+
+// IsEffective is effective at height
+func (a *MPCAddress) IsEffective(height uint64) bool {
+	return height >= a.FromHeight && (a.ToHeight == 0 || height <= a.ToHeight)
+}
+
+// GetDepositAddresses get the deposit addresses effective at height
+func (c *TokenConfig) GetDepositAddresses(height uint64) []string {
+	return getEffectiveAddresses(c.DepositAddress, c.DepositAddresses, height)
+}
+
+// GetRedeemAddresses get the redeem addresses effective at height
+func (c *TokenConfig) GetRedeemAddresses(height uint64) []string {
+	return getEffectiveAddresses(c.RedeemAddress, c.RedeemAddresses, height)
+}
+
+// IsDepositAddress is deposit address effective at height
+func (c *TokenConfig) IsDepositAddress(address string, height uint64) bool {
+	return ContainsAddress(c.GetDepositAddresses(height), address)
+}
+
+// IsRedeemSender redeems are sent from the redeem or deposit addresses effective at height
+func (c *TokenConfig) IsRedeemSender(address string, height uint64) bool {
+	return ContainsAddress(c.GetRedeemAddresses(height), address) || c.IsDepositAddress(address, height)
+}
+
+// ContainsAddress addresses contains address case insensitively
+func ContainsAddress(addresses []string, address string) bool {
+	for _, item := range addresses {
+		if strings.EqualFold(item, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMPCAddresses get all the deposit and redeem addresses of all heights
+func (c *TokenConfig) GetMPCAddresses() (addresses []string) {
+	if c.DepositAddress != "" {
+		addresses = append(addresses, c.DepositAddress)
+	}
+	if c.RedeemAddress != "" {
+		addresses = append(addresses, c.RedeemAddress)
+	}
+	for _, mpc := range c.DepositAddresses {
+		addresses = append(addresses, mpc.Address)
+	}
+	for _, mpc := range c.RedeemAddresses {
+		addresses = append(addresses, mpc.Address)
+	}
+	return addresses
+}
+
+// the first deposit address in history identifies token config
+func (c *TokenConfig) depositAddressKey() string {
+	if len(c.DepositAddresses) != 0 {
+		return c.DepositAddresses[0].Address
+	}
+	return c.DepositAddress
+}
+
+func getEffectiveAddresses(address string, history []*MPCAddress, height uint64) (addresses []string) {
+	if len(history) == 0 {
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+		return addresses
+	}
+	for _, mpc := range history {
+		if mpc.FromHeight > height {
+			break
+		}
+		if mpc.IsEffective(height) {
+			addresses = append(addresses, mpc.Address)
+		}
+	}
+	return addresses
+}
+
+func (c *TokenConfig) checkMPCConfig() error {
+	if c.DepositAddress != "" && len(c.DepositAddresses) != 0 {
+		return errors.New("'DepositAddress' and 'DepositAddresses' can not both be specified")
+	}
+	if c.RedeemAddress != "" && len(c.RedeemAddresses) != 0 {
+		return errors.New("'RedeemAddress' and 'RedeemAddresses' can not both be specified")
+	}
+	if c.DepositAddress != "" && !c.isValidMPCAddress(c.DepositAddress) {
+		return errors.New("wrong 'DepositAddress' " + c.DepositAddress)
+	}
+	if c.RedeemAddress != "" && !c.isValidMPCAddress(c.RedeemAddress) {
+		return errors.New("wrong 'RedeemAddress' " + c.RedeemAddress)
+	}
+	if err := c.checkMPCAddresses("DepositAddresses", c.DepositAddresses); err != nil {
+		return err
+	}
+	if err := c.checkMPCAddresses("RedeemAddresses", c.RedeemAddresses); err != nil {
+		return err
+	}
+	if !c.IsSrcToken && (c.RedeemAddress != "" || len(c.RedeemAddresses) != 0) {
+		return errors.New("redeem address is only for src token")
+	}
+	return nil
+}
+
+// the history is ordered by 'FromHeight'
+func (c *TokenConfig) checkMPCAddresses(name string, history []*MPCAddress) error {
+	for i, mpc := range history {
+		if mpc == nil || !c.isValidMPCAddress(mpc.Address) {
+			return fmt.Errorf("wrong address in '%v' at index %v", name, i)
+		}
+		if mpc.ToHeight != 0 && mpc.ToHeight < mpc.FromHeight {
+			return fmt.Errorf("'ToHeight' is lower than 'FromHeight' in '%v' at index %v", name, i)
+		}
+		if i > 0 && mpc.FromHeight < history[i-1].FromHeight {
+			return fmt.Errorf("'FromHeight' in '%v' is not ordered at index %v", name, i)
+		}
+	}
+	return nil
+}
+
+// the mpc addresses on btc like chain are P2PKH or P2WPKH addresses of the mpc public key
+func (c *TokenConfig) isValidMPCAddress(address string) bool {
+	if c.network == "" {
+		return common.IsHexAddress(address)
+	}
+	_, isPubKeyHash, err := utxoAddressFormats[c.network].decodeAddress(address)
+	return err == nil && isPubKeyHash
+}
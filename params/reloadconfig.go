@@ -1,12 +1,16 @@
 package params
 
 import (
+	"context"
+	"reflect"
+	"sync"
+
 	"github.com/anyswap/CrossChain-Bridge/log"
 	"github.com/fsnotify/fsnotify"
 )
 
-// WatchAndReloadScanConfig reload scan config if modified
-func WatchAndReloadScanConfig() {
+// WatchAndReloadScanConfig reload scan config if modified, until ctx is done
+func WatchAndReloadScanConfig(ctx context.Context) {
 	log.Info("start job of watch and reload config")
 	watch, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -34,6 +38,9 @@ func WatchAndReloadScanConfig() {
 
 	for {
 		select {
+		case <-ctx.Done():
+			log.Info("stop job of watch and reload config")
+			return
 		case ev, ok := <-watch.Events:
 			if !ok {
 				continue
@@ -53,3 +60,75 @@ func WatchAndReloadScanConfig() {
 		}
 	}
 }
+
+var (
+	reloadCallbacks    []func(oldConfig, newConfig *ScanConfig)
+	reloadCallbackLock sync.Mutex
+)
+
+// RegisterReloadCallback register callback which is called after config reloaded
+func RegisterReloadCallback(callback func(oldConfig, newConfig *ScanConfig)) {
+	reloadCallbackLock.Lock()
+	defer reloadCallbackLock.Unlock()
+	reloadCallbacks = append(reloadCallbacks, callback)
+}
+
+func callReloadCallbacks(oldConfig, newConfig *ScanConfig) {
+	reloadCallbackLock.Lock()
+	defer reloadCallbackLock.Unlock()
+	for _, callback := range reloadCallbacks {
+		callback(oldConfig, newConfig)
+	}
+}
+
+// ConfigDiff difference between configs
+type ConfigDiff struct {
+	AddedChains   []*ChainConfig
+	RemovedChains []*ChainConfig
+	ChangedChains []*ChainConfig // new chain configs
+	AddedTokens   []*TokenConfig
+	RemovedTokens []*TokenConfig
+}
+
+// IsEmpty has no difference
+func (d *ConfigDiff) IsEmpty() bool {
+	return len(d.AddedChains) == 0 && len(d.RemovedChains) == 0 && len(d.ChangedChains) == 0 &&
+		len(d.AddedTokens) == 0 && len(d.RemovedTokens) == 0
+}
+
+// DiffConfig compute difference from old config to new config
+func DiffConfig(oldConfig, newConfig *ScanConfig) *ConfigDiff {
+	diff := &ConfigDiff{}
+	for _, chainCfg := range newConfig.Chains {
+		oldChainCfg := oldConfig.GetChainConfig(chainCfg.Name)
+		switch {
+		case oldChainCfg == nil:
+			diff.AddedChains = append(diff.AddedChains, chainCfg)
+		case !reflect.DeepEqual(oldChainCfg, chainCfg):
+			diff.ChangedChains = append(diff.ChangedChains, chainCfg)
+		}
+	}
+	for _, chainCfg := range oldConfig.Chains {
+		if newConfig.GetChainConfig(chainCfg.Name) == nil {
+			diff.RemovedChains = append(diff.RemovedChains, chainCfg)
+		}
+	}
+	oldTokens := make(map[string]*TokenConfig, len(oldConfig.Tokens))
+	for _, tokenCfg := range oldConfig.Tokens {
+		oldTokens[tokenCfg.Key()] = tokenCfg
+	}
+	newTokens := make(map[string]*TokenConfig, len(newConfig.Tokens))
+	for _, tokenCfg := range newConfig.Tokens {
+		key := tokenCfg.Key()
+		newTokens[key] = tokenCfg
+		if _, exist := oldTokens[key]; !exist {
+			diff.AddedTokens = append(diff.AddedTokens, tokenCfg)
+		}
+	}
+	for key, tokenCfg := range oldTokens {
+		if _, exist := newTokens[key]; !exist {
+			diff.RemovedTokens = append(diff.RemovedTokens, tokenCfg)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,212 @@
+package scanner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/anyswap/CrossChain-Bridge/tokens"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+func convertSwapType(swapType string) SwapTxType {
+	switch swapType {
+	case params.SwapTypeDeposit:
+		return TypeDeposit
+	case params.SwapTypeMint:
+		return TypeMint
+	case params.SwapTypeBurn:
+		return TypeBurn
+	case params.SwapTypeRedeemed:
+		return TypeRedeemed
+	default:
+		return TypeNull
+	}
+}
+
+func abiMappingContract(mapping *params.ABIMapping, tokenCfg *params.TokenConfig) string {
+	if mapping.Contract != "" {
+		return mapping.Contract
+	}
+	return tokenCfg.TokenAddress
+}
+
+// redeems and mints are sent by mpc, the same as the builtin decoders
+func isABIMappingSender(mapping *params.ABIMapping, tokenCfg *params.TokenConfig, txFrom string, height uint64) bool {
+	switch mapping.SwapType {
+	case params.SwapTypeRedeemed:
+		return tokenCfg.IsRedeemSender(txFrom, height)
+	case params.SwapTypeMint:
+		return tokenCfg.IsDepositAddress(txFrom, height)
+	default:
+		return true
+	}
+}
+
+// verify tx by the abi mappings of token config
+func (scanner *ethSwapScanner) verifyABITransaction(tx *types.Transaction, txFrom common.Address, receipt *types.Receipt, header *types.Header, tokenCfg *params.TokenConfig) (txType SwapTxType, swapData *SwapEvent, err error) {
+	txTo := tx.To().Hex()
+	parsed := tokenCfg.GetABI()
+	height := header.Number.Uint64()
+	depositAddresses := tokenCfg.GetDepositAddresses(height)
+	for _, mapping := range tokenCfg.ABIMappings {
+		if !isABIMappingSender(mapping, tokenCfg, txFrom.Hex(), height) {
+			continue
+		}
+		contract := abiMappingContract(mapping, tokenCfg)
+		if !mapping.IsEvent() {
+			if !strings.EqualFold(txTo, contract) {
+				continue
+			}
+			swapData, err = decodeABIMethodInput(parsed.Methods[mapping.Method], mapping, tx.Data(), depositAddresses)
+		} else {
+			if receipt == nil {
+				if !strings.EqualFold(txTo, contract) && !strings.EqualFold(txTo, tokenCfg.CallByContract) {
+					continue
+				}
+				if receipt, err = scanner.getTxReceipt(tx, header); err != nil {
+					return TypeNull, nil, err
+				}
+			}
+			swapData, err = decodeABIEventLogs(parsed.Events[mapping.Event], mapping, contract, receipt.Logs, depositAddresses)
+		}
+		switch {
+		case errors.Is(err, tokens.ErrTxFuncHashMismatch),
+			errors.Is(err, tokens.ErrDepositLogNotFound):
+			continue
+		case errors.Is(err, tokens.ErrTxWithWrongReceiver):
+			return TypeNull, nil, err
+		}
+		if swapData != nil {
+			swapData.TxHash = tx.Hash()
+			swapData.BlockTime = int64(header.Time)
+			swapData.BlockNumber = header.Number
+			if mapping.UserArg == "" {
+				swapData.User = txFrom
+			}
+		}
+		return convertSwapType(mapping.SwapType), swapData, err
+	}
+	return TypeNull, nil, nil
+}
+
+func decodeABIMethodInput(method abi.Method, mapping *params.ABIMapping, input []byte, depositAddresses []string) (*SwapEvent, error) {
+	if len(input) < 4 {
+		return nil, tokens.ErrTxWithWrongInput
+	}
+	if !bytes.Equal(input[:4], method.ID) {
+		return nil, tokens.ErrTxFuncHashMismatch
+	}
+	args := make(map[string]interface{})
+	if err := method.Inputs.UnpackIntoMap(args, input[4:]); err != nil {
+		return nil, tokens.ErrTxWithWrongInput
+	}
+	return newABISwapEvent(mapping, args, depositAddresses)
+}
+
+func decodeABIEventLogs(event abi.Event, mapping *params.ABIMapping, contract string, logs []*types.Log, depositAddresses []string) (swapData *SwapEvent, err error) {
+	var indexed abi.Arguments
+	for _, arg := range event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	err = tokens.ErrDepositLogNotFound
+	for _, rlog := range logs {
+		if rlog.Removed {
+			continue
+		}
+		if !strings.EqualFold(rlog.Address.Hex(), contract) {
+			continue
+		}
+		if len(rlog.Topics) != len(indexed)+1 || rlog.Topics[0] != event.ID {
+			continue
+		}
+		args := make(map[string]interface{})
+		if err = event.Inputs.NonIndexed().UnpackIntoMap(args, rlog.Data); err != nil {
+			return nil, tokens.ErrTxWithWrongLogData
+		}
+		if err = abi.ParseTopicsIntoMap(args, indexed, rlog.Topics[1:]); err != nil {
+			return nil, tokens.ErrTxWithWrongLogData
+		}
+		swapData, err = newABISwapEvent(mapping, args, depositAddresses)
+		if errors.Is(err, tokens.ErrTxWithWrongReceiver) {
+			continue
+		}
+		return swapData, err
+	}
+	return nil, err
+}
+
+func newABISwapEvent(mapping *params.ABIMapping, args map[string]interface{}, depositAddresses []string) (swapData *SwapEvent, err error) {
+	swapData = &SwapEvent{}
+	if mapping.ReceiverArg != "" {
+		receiver, err := abiArgAddress(args, mapping.ReceiverArg)
+		if err != nil {
+			return nil, err
+		}
+		if !params.ContainsAddress(depositAddresses, receiver.Hex()) {
+			return nil, tokens.ErrTxWithWrongReceiver
+		}
+		swapData.MPCAddress = receiver.Hex()
+	}
+	if swapData.Amount, err = abiArgBigInt(args, mapping.AmountArg); err != nil {
+		return nil, err
+	}
+	if mapping.UserArg != "" {
+		if swapData.User, err = abiArgAddress(args, mapping.UserArg); err != nil {
+			return nil, err
+		}
+	}
+	if mapping.BindArg != "" {
+		if swapData.Bind, err = abiArgString(args, mapping.BindArg); err != nil {
+			return nil, err
+		}
+	}
+	if mapping.RefTxArg != "" {
+		refTx, err := abiArgString(args, mapping.RefTxArg)
+		if err != nil {
+			return nil, err
+		}
+		swapData.RefTxHash = common.HexToHash(refTx)
+	}
+	return swapData, nil
+}
+
+func abiArgAddress(args map[string]interface{}, name string) (common.Address, error) {
+	if addr, ok := args[name].(common.Address); ok {
+		return addr, nil
+	}
+	return common.Address{}, fmt.Errorf("abi argument '%v' is not address: %v", name, args[name])
+}
+
+func abiArgBigInt(args map[string]interface{}, name string) (*big.Int, error) {
+	if value, ok := args[name].(*big.Int); ok {
+		return value, nil
+	}
+	return nil, fmt.Errorf("abi argument '%v' is not uint256: %v", name, args[name])
+}
+
+func abiArgString(args map[string]interface{}, name string) (string, error) {
+	switch value := args[name].(type) {
+	case string:
+		return value, nil
+	case common.Address:
+		return value.Hex(), nil
+	case common.Hash:
+		return value.Hex(), nil
+	case [32]byte:
+		return common.Hash(value).Hex(), nil
+	case []byte:
+		return hexutil.Encode(value), nil
+	default:
+		return "", fmt.Errorf("abi argument '%v' is not string like: %v", name, value)
+	}
+}
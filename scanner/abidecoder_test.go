@@ -0,0 +1,77 @@
+package scanner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// the anyUSDC token of config-example.toml
+func testABITokenConfig(t *testing.T) *params.TokenConfig {
+	tokenCfg := &params.TokenConfig{
+		Chain:          "fantom",
+		PairID:         "anyUSDC",
+		SwapServer:     "http://127.0.0.1:55556/rpc",
+		TokenAddress:   testTokenAddress,
+		DepositAddress: testDepositAddress,
+		ABIFile:        "../abi/anyswapv5erc20.json",
+		ABIMappings: []*params.ABIMapping{
+			{SwapType: params.SwapTypeMint, Event: "LogSwapin", UserArg: "account", AmountArg: "amount", RefTxArg: "txhash"},
+			{SwapType: params.SwapTypeBurn, Event: "LogSwapout", UserArg: "account", AmountArg: "amount", BindArg: "bindaddr"},
+		},
+	}
+	if err := tokenCfg.CheckConfig(); err != nil {
+		t.Fatalf("check token config failed: %v", err)
+	}
+	return tokenCfg
+}
+
+func TestVerifyABITransactionSender(t *testing.T) {
+	tokenCfg := testABITokenConfig(t)
+	tx := types.NewTransaction(0, common.HexToAddress(testTokenAddress), big.NewInt(0), 100000, big.NewInt(1), nil)
+	header := &types.Header{Number: big.NewInt(100), Time: 1600000000}
+	receipt := &types.Receipt{
+		Status: types.ReceiptStatusSuccessful,
+		Logs: []*types.Log{{
+			Address: common.HexToAddress(testTokenAddress),
+			Topics: []common.Hash{
+				swapinLogTopic,
+				common.HexToHash(testRefTxHash),
+				testTopic(testUserAddress),
+			},
+			Data: common.FromHex(testAmountWord()),
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		sender string
+		txType SwapTxType
+	}{
+		{name: "mint from mpc", sender: testDepositAddress, txType: TypeMint},
+		{name: "mint from other address", sender: testUserAddress, txType: TypeNull},
+	}
+	scanner := &ethSwapScanner{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txType, swapData, err := scanner.verifyABITransaction(tx, common.HexToAddress(tt.sender), receipt, header, tokenCfg)
+			if err != nil {
+				t.Fatalf("verify failed: %v", err)
+			}
+			if txType != tt.txType {
+				t.Fatalf("swap type mismatch, have %v want %v", txType, tt.txType)
+			}
+			if txType == TypeNull {
+				return
+			}
+			checkSwapData(t, swapData, testUserAddress, "")
+			if swapData.RefTxHash != common.HexToHash(testRefTxHash) {
+				t.Errorf("ref tx hash mismatch, have %v want %v", swapData.RefTxHash.Hex(), testRefTxHash)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package scanner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/cmd/utils"
+	"github.com/urfave/cli/v2"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+var (
+	allAlertsFlag = &cli.BoolFlag{
+		Name:  "all",
+		Usage: "list resolved alerts too",
+	}
+	silenceFlag = &cli.StringFlag{
+		Name:  "silence",
+		Usage: "silence alert of key, its notifications are suppressed until silence expires",
+	}
+	silenceMinutesFlag = &cli.Uint64Flag{
+		Name:  "minutes",
+		Usage: "silence duration in minutes, 0 to unsilence",
+		Value: 60,
+	}
+
+	// AlertsCommand list and silence alerts
+	AlertsCommand = &cli.Command{
+		Action:    alerts,
+		Name:      "alerts",
+		Usage:     "list or silence alerts",
+		ArgsUsage: " ",
+		Description: `
+list the active alerts, or all alerts if '--all' is specified.
+silence alert by '--silence <key> --minutes <minutes>'.
+`,
+		Flags: []cli.Flag{
+			utils.ConfigFileFlag,
+			allAlertsFlag,
+			silenceFlag,
+			silenceMinutesFlag,
+		},
+	}
+)
+
+func alerts(ctx *cli.Context) error {
+	utils.SetLogger(ctx)
+	cfg := params.LoadConfig(utils.GetConfigFilePath(ctx))
+
+	mongodb.MongoServerInit(ctx.Context, cfg, cfg.MongoDB.DBURLs, cfg.MongoDB.DBName, cfg.MongoDB.UserName, cfg.MongoDB.Password)
+	alertAPI := mongodb.NewAlertAPI()
+
+	if key := ctx.String(silenceFlag.Name); key != "" {
+		if _, err := alertAPI.GetAlert(key); err != nil {
+			return fmt.Errorf("get alert %v failed: %w", key, err)
+		}
+		until := time.Now().Add(time.Duration(ctx.Uint64(silenceMinutesFlag.Name)) * time.Minute)
+		if err := alertAPI.SilenceAlert(key, until.Unix()); err != nil {
+			return err
+		}
+		fmt.Printf("alert %v is silenced until %v\n", key, until.UTC().Format(time.RFC3339))
+		return nil
+	}
+
+	list, err := alertAPI.GetAlerts(!ctx.Bool(allAlertsFlag.Name))
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, alert := range list {
+		fmt.Printf("%v\n  active: %v\n  message: %v\n  count: %v\n  first: %v\n  last: %v\n", alert.Key, alert.Active, alert.Message, alert.Count,
+			time.Unix(alert.FirstTime, 0).UTC().Format(time.RFC3339), time.Unix(alert.LastTime, 0).UTC().Format(time.RFC3339))
+		if alert.SilencedUntil > now {
+			fmt.Printf("  silencedUntil: %v\n", time.Unix(alert.SilencedUntil, 0).UTC().Format(time.RFC3339))
+		}
+	}
+	fmt.Printf("total %v alerts\n", len(list))
+	return nil
+}
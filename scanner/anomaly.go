@@ -0,0 +1,175 @@
+package scanner
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gaozhengxin/bridgeAccounting/alert"
+	"github.com/gaozhengxin/bridgeAccounting/metrics"
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// detect the confirmed swap events which are still not matched after the anomaly delay,
+// they are transfers from deposit address of src tokens without burn, and mints of dst tokens without deposit.
+// the detected anomalies are resolved when their swap events are matched later.
+func detectAnomalies(tokenCfgs []*params.TokenConfig, anomalyDelay time.Duration) {
+	before := time.Now().Add(-anomalyDelay).Unix()
+	for _, tokenCfg := range tokenCfgs {
+		var (
+			swapTxType SwapTxType
+			kind       string
+			iter       mongodb.SwapEventIter
+			err        error
+		)
+		if tokenCfg.IsSrcToken {
+			swapTxType, kind = TypeRedeemed, mongodb.AnomalyTransferWithoutBurn
+			iter, err = dbAPI.GetUnmatchedRedeems(tokenCfg)
+		} else {
+			swapTxType, kind = TypeMint, mongodb.AnomalyMintWithoutDeposit
+			iter, err = dbAPI.GetUnmatchedMints(tokenCfg)
+		}
+		if err != nil {
+			log.Warn("get unmatched swap events failed", "pairID", tokenCfg.PairID, "swapTxType", swapTxType, "err", err)
+			continue
+		}
+		for {
+			var event mongodb.SwapEvent
+			if !iter.Next(&event) {
+				break
+			}
+			if event.Unconfirmed || event.BlockTime >= before {
+				continue
+			}
+			addAnomaly(tokenCfg, kind, &event)
+		}
+		resolveAnomalies(swapTxType, tokenCfg, kind)
+	}
+}
+
+func addAnomaly(tokenCfg *params.TokenConfig, kind string, event *mongodb.SwapEvent) {
+	anomaly := &mongodb.Anomaly{
+		Key:         kind + ":" + event.TxHash,
+		Kind:        kind,
+		PairID:      tokenCfg.PairID,
+		TxHash:      event.TxHash,
+		BlockNumber: event.BlockNumber,
+		BlockTime:   event.BlockTime,
+		User:        event.User,
+		FAmount:     event.FAmount,
+		DetectTime:  time.Now().Unix(),
+	}
+	err := dbAPI.AddAnomaly(anomaly)
+	switch {
+	case errors.Is(err, mongodb.ErrItemIsDup):
+		return
+	case err != nil:
+		log.Warn("add anomaly failed", "key", anomaly.Key, "err", err)
+		return
+	}
+	log.Warn("detect anomaly", "kind", kind, "pairID", tokenCfg.PairID, "txHash", event.TxHash, "block", event.BlockNumber, "user", event.User, "amount", event.FAmount)
+	metrics.AnomaliesDetected.Add(kind, 1)
+	alert.OnAnomaly(anomaly)
+}
+
+// resolve the anomalies of kind whose swap events are matched now
+func resolveAnomalies(swapTxType SwapTxType, tokenCfg *params.TokenConfig, kind string) {
+	anomalies, err := dbAPI.GetUnresolvedAnomalies(tokenCfg.PairID)
+	if err != nil {
+		log.Warn("get unresolved anomalies failed", "pairID", tokenCfg.PairID, "err", err)
+		return
+	}
+	for _, anomaly := range anomalies {
+		if anomaly.Kind != kind {
+			continue
+		}
+		event, err := getSwapEvent(swapTxType, tokenCfg, anomaly.TxHash)
+		if err != nil || !event.Matched {
+			continue
+		}
+		if err = dbAPI.ResolveAnomaly(anomaly.Key, time.Now().Unix()); err != nil {
+			log.Warn("resolve anomaly failed", "key", anomaly.Key, "err", err)
+			continue
+		}
+		log.Info("resolve anomaly", "kind", kind, "pairID", tokenCfg.PairID, "txHash", anomaly.TxHash, "ref", event.RefTxHash)
+		metrics.AnomaliesResolved.Add(kind, 1)
+		alert.OnAnomalyResolved(anomaly)
+	}
+}
+
+// detect the native and token transfers from mpc addresses which are not recorded as redeems,
+// such as the transfers through other contracts and the transfers from retired addresses.
+// the transfers between the mpc addresses of token are not anomalies.
+// the token transfers in txs sent by others, for example by allowance, are found only if receipts are scanned.
+// return error if the receipt of tx sent from mpc address can not be got, or the token decimals are unresolved.
+func (scanner *ethSwapScanner) detectUnclassifiedTransfers(header *types.Header, tx *types.Transaction, receipt *types.Receipt,
+	mpcAddresses map[common.Address][]*params.TokenConfig, redeemed map[*params.TokenConfig]bool) error {
+	txFrom, err := types.Sender(types.LatestSignerForChainID(scanner.chainId), tx)
+	if err != nil {
+		return nil
+	}
+	senderTokens := mpcAddresses[txFrom]
+	if len(senderTokens) != 0 && receipt == nil {
+		if receipt, err = scanner.getBlockTxReceipt(tx, header); err != nil {
+			return err
+		}
+	}
+	if receipt != nil && receipt.Status != types.ReceiptStatusSuccessful {
+		return nil
+	}
+	txHash := strings.ToLower(tx.Hash().Hex())
+
+	if len(senderTokens) != 0 && tx.Value().Sign() > 0 && tx.To() != nil && !isNativeRedeemed(senderTokens, redeemed) {
+		tokenCfg := senderTokens[0]
+		if !isMPCAddressOf(tokenCfg, *tx.To()) {
+			scanner.addUnclassifiedTransfer(tokenCfg, txHash, header, *tx.To(), tx.Value(), nativeDecimals)
+		}
+	}
+	for _, transfer := range parseTokenTransferLogs(receipt) {
+		for _, tokenCfg := range mpcAddresses[transfer.from] {
+			if !strings.EqualFold(tokenCfg.TokenAddress, transfer.token.Hex()) || redeemed[tokenCfg] || isMPCAddressOf(tokenCfg, transfer.to) {
+				continue
+			}
+			meta, err := scanner.getTokenMeta(tokenCfg)
+			if err != nil {
+				log.Warn("unclassified transfer is not recorded", "pairID", tokenCfg.PairID, "txHash", txHash, "err", err)
+				return err
+			}
+			scanner.addUnclassifiedTransfer(tokenCfg, swapEventKey(txHash, transfer.index), header, transfer.to, transfer.amount, meta.Decimals)
+		}
+	}
+	return nil
+}
+
+// the native value sent from mpc address is recorded as redeem of native token
+func isNativeRedeemed(senderTokens []*params.TokenConfig, redeemed map[*params.TokenConfig]bool) bool {
+	for _, tokenCfg := range senderTokens {
+		if tokenCfg.IsNativeToken() && redeemed[tokenCfg] {
+			return true
+		}
+	}
+	return false
+}
+
+func isMPCAddressOf(tokenCfg *params.TokenConfig, address common.Address) bool {
+	return params.ContainsAddress(tokenCfg.GetMPCAddresses(), address.Hex())
+}
+
+func (scanner *ethSwapScanner) addUnclassifiedTransfer(tokenCfg *params.TokenConfig, key string, header *types.Header, to common.Address, amount *big.Int, decimals int) {
+	if scanner.dryRun {
+		return
+	}
+	addAnomaly(tokenCfg, mongodb.AnomalyUnclassifiedTransfer, &mongodb.SwapEvent{
+		TxHash:      key,
+		BlockNumber: header.Number.Int64(),
+		BlockTime:   int64(header.Time),
+		User:        strings.ToLower(to.Hex()),
+		FAmount:     toFloat(amount, decimals),
+	})
+}
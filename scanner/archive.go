@@ -0,0 +1,357 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// blockSource provide blocks and receipts to scanner,
+// it is either a live gateway (*ethclient.Client) or a block archive
+type blockSource interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+var (
+	errArchiveBlockNotFound   = errors.New("block not found in archive")
+	errArchiveReceiptNotFound = errors.New("receipt not found in archive")
+)
+
+// archive file formats, decided by file extension
+const (
+	archiveFormatRLP  = "rlp"
+	archiveFormatJSON = "jsonl"
+)
+
+func getArchiveFormat(path string) string {
+	if strings.HasSuffix(path, "."+archiveFormatRLP) {
+		return archiveFormatRLP
+	}
+	return archiveFormatJSON
+}
+
+// archiveMeta the first record of archive file, blocks are in range [From, To)
+type archiveMeta struct {
+	ChainID *big.Int `json:"chainID"`
+	From    uint64   `json:"from"`
+	To      uint64   `json:"to"`
+}
+
+// archivedBlock one record of archive file per block.
+// receipts are only exported for the transactions which need them.
+type archivedBlock struct {
+	Header       *types.Header        `json:"header"`
+	Transactions []*types.Transaction `json:"transactions"`
+	Receipts     []*types.Receipt     `json:"receipts,omitempty"`
+}
+
+// rlpArchivedBlock rlp encoding of archived block,
+// the receipts are in storage format and their derived fields are recovered when decoding
+type rlpArchivedBlock struct {
+	Header       *types.Header
+	Transactions []*types.Transaction
+	TxIndexes    []uint64 // tx index of receipts
+	GasUsed      []uint64 // gas used of receipts
+	Receipts     []*types.ReceiptForStorage
+}
+
+func (b *archivedBlock) encode(format string) ([]byte, error) {
+	if format == archiveFormatJSON {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+	enc := &rlpArchivedBlock{Header: b.Header, Transactions: b.Transactions}
+	indexes := make(map[common.Hash]uint64, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		indexes[tx.Hash()] = uint64(i)
+	}
+	for _, receipt := range b.Receipts {
+		enc.TxIndexes = append(enc.TxIndexes, indexes[receipt.TxHash])
+		enc.GasUsed = append(enc.GasUsed, receipt.GasUsed)
+		enc.Receipts = append(enc.Receipts, (*types.ReceiptForStorage)(receipt))
+	}
+	return rlp.EncodeToBytes(enc)
+}
+
+func decodeArchivedBlock(data []byte, format string) (*archivedBlock, error) {
+	if format == archiveFormatJSON {
+		var b archivedBlock
+		if err := json.Unmarshal(data, &b); err != nil {
+			return nil, err
+		}
+		return &b, nil
+	}
+	var dec rlpArchivedBlock
+	if err := rlp.DecodeBytes(data, &dec); err != nil {
+		return nil, err
+	}
+	if len(dec.TxIndexes) != len(dec.Receipts) || len(dec.GasUsed) != len(dec.Receipts) {
+		return nil, errors.New("mismatch receipts and tx indexes")
+	}
+	b := &archivedBlock{Header: dec.Header, Transactions: dec.Transactions}
+	blockHash := dec.Header.Hash()
+	for i, stored := range dec.Receipts {
+		txIndex := dec.TxIndexes[i]
+		if txIndex >= uint64(len(dec.Transactions)) {
+			return nil, errors.New("wrong tx index of receipt")
+		}
+		tx := dec.Transactions[txIndex]
+		receipt := (*types.Receipt)(stored)
+		receipt.Type = tx.Type()
+		receipt.GasUsed = dec.GasUsed[i]
+		receipt.TxHash = tx.Hash()
+		receipt.BlockHash = blockHash
+		receipt.BlockNumber = dec.Header.Number
+		receipt.TransactionIndex = uint(txIndex)
+		for _, rlog := range receipt.Logs {
+			rlog.TxHash = receipt.TxHash
+			rlog.TxIndex = uint(txIndex)
+			rlog.BlockHash = blockHash
+			rlog.BlockNumber = dec.Header.Number.Uint64()
+		}
+		b.Receipts = append(b.Receipts, receipt)
+	}
+	return b, nil
+}
+
+// archiveWriter write archive file
+type archiveWriter struct {
+	file   *os.File
+	writer *bufio.Writer
+	format string
+}
+
+func createArchive(path string, meta *archiveMeta) (*archiveWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &archiveWriter{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		format: getArchiveFormat(path),
+	}
+	var data []byte
+	if w.format == archiveFormatJSON {
+		data, err = json.Marshal(meta)
+		data = append(data, '\n')
+	} else {
+		data, err = rlp.EncodeToBytes(meta)
+	}
+	if err == nil {
+		_, err = w.writer.Write(data)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *archiveWriter) writeBlock(b *archivedBlock) error {
+	data, err := b.encode(w.format)
+	if err != nil {
+		return err
+	}
+	_, err = w.writer.Write(data)
+	return err
+}
+
+func (w *archiveWriter) close() error {
+	if err := w.writer.Flush(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+type archiveRecordPos struct {
+	offset int64
+	length int
+}
+
+// blockArchive block source which reads blocks and receipts from archive file.
+// the file is indexed when open, and the records are read on demand.
+type blockArchive struct {
+	path   string
+	format string
+	file   *os.File
+	meta   *archiveMeta
+	latest uint64
+
+	blocks   map[uint64]*archiveRecordPos // height -> record
+	receipts map[common.Hash]uint64       // tx hash -> height
+
+	lock   sync.Mutex
+	recent map[uint64]*archivedBlock // recently decoded records
+}
+
+// max count of recently decoded records kept in memory
+const archiveRecentRecords = 64
+
+func openBlockArchive(path string) (*blockArchive, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	archive := &blockArchive{
+		path:     path,
+		format:   getArchiveFormat(path),
+		file:     file,
+		blocks:   make(map[uint64]*archiveRecordPos),
+		receipts: make(map[common.Hash]uint64),
+		recent:   make(map[uint64]*archivedBlock),
+	}
+	if err = archive.index(); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("index archive %v failed: %w", path, err)
+	}
+	log.Info("open block archive success", "path", path, "chainID", archive.meta.ChainID, "from", archive.meta.From, "to", archive.meta.To, "blocks", len(archive.blocks))
+	return archive, nil
+}
+
+// read all records to build the index of blocks and receipts
+func (a *blockArchive) index() error {
+	reader := bufio.NewReaderSize(a.file, 1024*1024)
+	var stream *rlp.Stream
+	if a.format == archiveFormatRLP {
+		stream = rlp.NewStream(reader, 0)
+	}
+	nextRecord := func() ([]byte, error) {
+		if stream != nil {
+			return stream.Raw()
+		}
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) > 0 {
+			err = nil
+		}
+		return line, err
+	}
+
+	var offset int64
+	for first := true; ; first = false {
+		data, err := nextRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		pos := &archiveRecordPos{offset: offset, length: len(data)}
+		offset += int64(len(data))
+		if first {
+			a.meta = &archiveMeta{}
+			if a.format == archiveFormatJSON {
+				err = json.Unmarshal(data, a.meta)
+			} else {
+				err = rlp.DecodeBytes(data, a.meta)
+			}
+			if err != nil {
+				return fmt.Errorf("decode archive meta failed: %w", err)
+			}
+			continue
+		}
+		block, err := decodeArchivedBlock(data, a.format)
+		if err != nil {
+			return fmt.Errorf("decode archived block at offset %v failed: %w", pos.offset, err)
+		}
+		height := block.Header.Number.Uint64()
+		a.blocks[height] = pos
+		if height > a.latest {
+			a.latest = height
+		}
+		for _, receipt := range block.Receipts {
+			a.receipts[receipt.TxHash] = height
+		}
+	}
+	if a.meta == nil {
+		return errors.New("empty archive")
+	}
+	return nil
+}
+
+func (a *blockArchive) close() error {
+	return a.file.Close()
+}
+
+func (a *blockArchive) getBlock(height uint64) (*archivedBlock, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if block, exist := a.recent[height]; exist {
+		return block, nil
+	}
+	pos, exist := a.blocks[height]
+	if !exist {
+		return nil, errArchiveBlockNotFound
+	}
+	data := make([]byte, pos.length)
+	if _, err := a.file.ReadAt(data, pos.offset); err != nil {
+		return nil, err
+	}
+	block, err := decodeArchivedBlock(data, a.format)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.recent) >= archiveRecentRecords {
+		a.recent = make(map[uint64]*archivedBlock)
+	}
+	a.recent[height] = block
+	return block, nil
+}
+
+// HeaderByNumber impl blockSource, nil number means the latest block
+func (a *blockArchive) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	height := a.latest
+	if number != nil {
+		height = number.Uint64()
+	}
+	block, err := a.getBlock(height)
+	if err != nil {
+		return nil, err
+	}
+	return block.Header, nil
+}
+
+// BlockByNumber impl blockSource
+func (a *blockArchive) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	block, err := a.getBlock(number.Uint64())
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBlockWithHeader(block.Header).WithBody(block.Transactions, nil), nil
+}
+
+// TransactionReceipt impl blockSource
+func (a *blockArchive) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	height, exist := a.receipts[txHash]
+	if !exist {
+		return nil, errArchiveReceiptNotFound
+	}
+	block, err := a.getBlock(height)
+	if err != nil {
+		return nil, err
+	}
+	for _, receipt := range block.Receipts {
+		if receipt.TxHash == txHash {
+			return receipt, nil
+		}
+	}
+	return nil, errArchiveReceiptNotFound
+}
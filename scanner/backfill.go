@@ -0,0 +1,137 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// progress is recorded every this number of blocks
+const backfillProgressInterval = 1000
+
+// backfill history of tokens before the main scanner start height
+func (scanner *ethSwapScanner) startTokenBackfills(end uint64) {
+	for _, tokenCfg := range scanner.getTokenConfigs() {
+		if tokenCfg.NeedBackfill() {
+			tokenCfg := tokenCfg
+			scanner.goJob(func() { scanner.backfillToken(tokenCfg, end) })
+		}
+	}
+}
+
+// backfill the token added by config reload, to the synced height of chain
+func (scanner *ethSwapScanner) backfillAddedToken(tokenCfg *params.TokenConfig) {
+	var end uint64
+	if syncInfo, err := dbAPI.GetSyncInfo(tokenCfg.Chain); err == nil && syncInfo.SyncedHeight > 0 {
+		end = uint64(syncInfo.SyncedHeight) + 1
+	} else {
+		end = scanner.loopGetLatestBlockNumber()
+	}
+	scanner.goJob(func() { scanner.backfillToken(tokenCfg, end) })
+}
+
+// scan history of the token in range [start height, end) in background,
+// resume the unfinished backfill if exist
+func (scanner *ethSwapScanner) backfillToken(tokenCfg *params.TokenConfig, end uint64) {
+	var start, synced uint64
+	info, err := dbAPI.GetTokenSyncInfo(tokenCfg)
+	if err == nil && info.EndHeight > 0 {
+		start = uint64(info.StartHeight)
+		synced = uint64(info.SyncedHeight)
+		end = uint64(info.EndHeight)
+	} else {
+		start, err = scanner.getTokenStartHeight(tokenCfg)
+		if err != nil {
+			log.Warn("get token start height failed", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "err", err)
+			return
+		}
+		if start >= end {
+			return
+		}
+		if err = dbAPI.SetTokenBackfillRange(tokenCfg, int64(start), int64(end)); err != nil {
+			log.Warn("set token backfill range failed", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "err", err)
+			return
+		}
+		synced = start
+	}
+	if synced >= end {
+		return
+	}
+
+	log.Info("start backfill token", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "start", start, "synced", synced, "end", end)
+	backfiller := scanner.cloneForTokens([]*params.TokenConfig{tokenCfg}, end)
+	scheduler := newBlockScheduler(backfiller, synced, end)
+	updateSyncedHeight := func(watermark uint64) {
+		synced = watermark
+		if err := dbAPI.UpdateTokenSyncedHeight(tokenCfg, int64(synced)); err != nil {
+			log.Warn("update token synced height failed", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "synced", synced, "err", err)
+		}
+	}
+	scheduler.onProgress = func(watermark uint64) {
+		if watermark == end || watermark-synced >= backfillProgressInterval {
+			updateSyncedHeight(watermark)
+		}
+	}
+	scheduler.run()
+	if backfiller.isStopped() {
+		if watermark := scheduler.getWatermark(); watermark > synced {
+			updateSyncedHeight(watermark)
+		}
+		log.Info("backfill token stopped", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "synced", synced)
+		return
+	}
+	log.Info("backfill token finished", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "start", start, "end", end)
+}
+
+func (scanner *ethSwapScanner) getTokenStartHeight(tokenCfg *params.TokenConfig) (uint64, error) {
+	if !tokenCfg.DiscoverStartHeight {
+		return tokenCfg.StartHeight, nil
+	}
+	contract := tokenCfg.TokenAddress
+	if tokenCfg.IsRouterToken() {
+		contract = tokenCfg.RouterContract
+	}
+	return scanner.findContractCreationHeight(common.HexToAddress(contract))
+}
+
+// binary search the lowest height at which the contract code exists
+func (scanner *ethSwapScanner) findContractCreationHeight(contract common.Address) (uint64, error) {
+	if scanner.offline != nil {
+		return 0, errors.New("can not discover start height when scan offline")
+	}
+	latest := scanner.loopGetLatestBlockNumber()
+	hasCode := func(height uint64) (bool, error) {
+		code, err := scanner.client().CodeAt(scanner.ctx, contract, new(big.Int).SetUint64(height))
+		if err != nil {
+			return false, err
+		}
+		return len(code) > 0, nil
+	}
+	exist, err := hasCode(latest)
+	if err != nil {
+		return 0, err
+	}
+	if !exist {
+		return 0, errors.New("contract code not found: " + contract.Hex())
+	}
+	low, high := uint64(0), latest
+	for low < high {
+		mid := low + (high-low)/2
+		exist, err = hasCode(mid)
+		if err != nil {
+			return 0, fmt.Errorf("get code at height %v failed (archive node is required): %w", mid, err)
+		}
+		if exist {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+	log.Info("found contract creation height", "chain", scanner.chain, "contract", contract.Hex(), "height", low)
+	return low, nil
+}
@@ -0,0 +1,364 @@
+package scanner
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+const (
+	cachedBlockFile   = "block.json"
+	cachedReceiptExt  = ".receipt.json"
+	cachedBlockDirSep = "_"
+)
+
+// cachedBlock minimal block data used by scanning
+type cachedBlock struct {
+	Header       *types.Header        `json:"header"`
+	Transactions []*types.Transaction `json:"transactions"`
+}
+
+// cachedReceipt minimal receipt data used by scanning
+type cachedReceipt struct {
+	Status  uint64       `json:"status"`
+	GasUsed uint64       `json:"gasUsed"`
+	Logs    []*types.Log `json:"logs"`
+}
+
+type blockCacheEntry struct {
+	chainID string
+	height  uint64
+	hash    common.Hash
+	size    int64
+}
+
+// blockCache on-disk cache of stable blocks and receipts with LRU eviction.
+// blocks are stored in directory '<dir>/<chainID>/<height>_<blockHash>',
+// which contains the block file and receipt files of its transactions.
+type blockCache struct {
+	dir     string
+	maxSize int64
+
+	lock    sync.Mutex
+	size    int64
+	lru     *list.List                        // front is the most recently used
+	entries map[string]*list.Element          // key is chainID/blockHash
+	heights map[string]map[uint64]common.Hash // chainID -> height -> block hash
+}
+
+var blkCache *blockCache // nil if not configured
+
+// init the global block cache if configured
+func initBlockCache(cfg *params.BlockCacheConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	cache, err := openBlockCache(cfg.Dir, cfg.MaxSizeMB*1024*1024)
+	if err != nil {
+		return err
+	}
+	blkCache = cache
+	return nil
+}
+
+func openBlockCache(dir string, maxSize int64) (*blockCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	cache := &blockCache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		heights: make(map[string]map[uint64]common.Hash),
+	}
+	type loadedEntry struct {
+		entry   *blockCacheEntry
+		modTime time.Time
+	}
+	var loaded []*loadedEntry
+	chainDirs, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, chainDir := range chainDirs {
+		if !chainDir.IsDir() {
+			continue
+		}
+		chainID := chainDir.Name()
+		blockDirs, err := ioutil.ReadDir(filepath.Join(dir, chainID))
+		if err != nil {
+			return nil, err
+		}
+		for _, blockDir := range blockDirs {
+			height, hash, ok := parseCachedBlockDir(blockDir.Name())
+			if !ok || !blockDir.IsDir() {
+				continue
+			}
+			blockPath := filepath.Join(dir, chainID, blockDir.Name())
+			size, modTime, err := statCachedBlockDir(blockPath)
+			if err != nil {
+				log.Warn("remove broken cached block", "path", blockPath, "err", err)
+				_ = os.RemoveAll(blockPath)
+				continue
+			}
+			entry := &blockCacheEntry{chainID: chainID, height: height, hash: hash, size: size}
+			loaded = append(loaded, &loadedEntry{entry: entry, modTime: modTime})
+		}
+	}
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].modTime.After(loaded[j].modTime)
+	})
+	for _, item := range loaded {
+		cache.addEntry(item.entry, false)
+	}
+	cache.evict()
+	log.Info("open block cache success", "dir", dir, "blocks", cache.lru.Len(), "size", cache.size, "maxSize", maxSize)
+	return cache, nil
+}
+
+func parseCachedBlockDir(name string) (height uint64, hash common.Hash, ok bool) {
+	parts := strings.Split(name, cachedBlockDirSep)
+	if len(parts) != 2 {
+		return 0, hash, false
+	}
+	height, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, hash, false
+	}
+	return height, common.HexToHash(parts[1]), true
+}
+
+// return total size of files and the modify time of block file
+func statCachedBlockDir(path string) (size int64, modTime time.Time, err error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return 0, modTime, err
+	}
+	for _, file := range files {
+		size += file.Size()
+		if file.Name() == cachedBlockFile {
+			modTime = file.ModTime()
+		}
+	}
+	if modTime.IsZero() {
+		return 0, modTime, fmt.Errorf("block file not found")
+	}
+	return size, modTime, nil
+}
+
+func cacheEntryKey(chainID string, hash common.Hash) string {
+	return chainID + "/" + hash.Hex()
+}
+
+func (c *blockCache) blockDir(entry *blockCacheEntry) string {
+	return filepath.Join(c.dir, entry.chainID, fmt.Sprintf("%d%s%s", entry.height, cachedBlockDirSep, entry.hash.Hex()))
+}
+
+// add entry with lock hold
+func (c *blockCache) addEntry(entry *blockCacheEntry, front bool) {
+	var elem *list.Element
+	if front {
+		elem = c.lru.PushFront(entry)
+	} else {
+		elem = c.lru.PushBack(entry)
+	}
+	c.entries[cacheEntryKey(entry.chainID, entry.hash)] = elem
+	heights, exist := c.heights[entry.chainID]
+	if !exist {
+		heights = make(map[uint64]common.Hash)
+		c.heights[entry.chainID] = heights
+	}
+	heights[entry.height] = entry.hash
+	c.size += entry.size
+}
+
+// remove entry with lock hold
+func (c *blockCache) removeEntry(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*blockCacheEntry)
+	delete(c.entries, cacheEntryKey(entry.chainID, entry.hash))
+	if heights, exist := c.heights[entry.chainID]; exist && heights[entry.height] == entry.hash {
+		delete(heights, entry.height)
+	}
+	c.size -= entry.size
+	if err := os.RemoveAll(c.blockDir(entry)); err != nil {
+		log.Warn("remove cached block failed", "chainID", entry.chainID, "height", entry.height, "err", err)
+	}
+}
+
+// evict the least recently used blocks with lock hold
+func (c *blockCache) evict() {
+	for c.size > c.maxSize && c.lru.Len() > 0 {
+		c.removeEntry(c.lru.Back())
+	}
+}
+
+// get entry and mark it as recently used with lock hold
+func (c *blockCache) touch(chainID string, hash common.Hash) *blockCacheEntry {
+	elem, exist := c.entries[cacheEntryKey(chainID, hash)]
+	if !exist {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*blockCacheEntry)
+	now := time.Now()
+	_ = os.Chtimes(filepath.Join(c.blockDir(entry), cachedBlockFile), now, now)
+	return entry
+}
+
+func (c *blockCache) getBlock(chainID *big.Int, height uint64) *types.Block {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cid := chainID.String()
+	hash, exist := c.heights[cid][height]
+	if !exist {
+		return nil
+	}
+	entry := c.touch(cid, hash)
+	if entry == nil {
+		return nil
+	}
+	var cached cachedBlock
+	if err := readJSONFile(filepath.Join(c.blockDir(entry), cachedBlockFile), &cached); err != nil || cached.Header == nil {
+		log.Warn("read cached block failed", "chainID", cid, "height", height, "err", err)
+		c.removeEntry(c.entries[cacheEntryKey(cid, hash)])
+		return nil
+	}
+	block := types.NewBlockWithHeader(cached.Header).WithBody(cached.Transactions, nil)
+	if block.Hash() != hash {
+		log.Warn("cached block hash mismatch", "chainID", cid, "height", height, "have", block.Hash().Hex(), "want", hash.Hex())
+		c.removeEntry(c.entries[cacheEntryKey(cid, hash)])
+		return nil
+	}
+	return block
+}
+
+// put stable block into cache
+func (c *blockCache) putBlock(chainID *big.Int, block *types.Block) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	cid := chainID.String()
+	if _, exist := c.entries[cacheEntryKey(cid, block.Hash())]; exist {
+		return
+	}
+	entry := &blockCacheEntry{chainID: cid, height: block.NumberU64(), hash: block.Hash()}
+	if oldHash, exist := c.heights[cid][entry.height]; exist {
+		// replaced by reorg
+		c.removeEntry(c.entries[cacheEntryKey(cid, oldHash)])
+	}
+	dir := c.blockDir(entry)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Warn("create cached block dir failed", "dir", dir, "err", err)
+		return
+	}
+	cached := &cachedBlock{Header: block.Header(), Transactions: block.Transactions()}
+	size, err := writeJSONFile(filepath.Join(dir, cachedBlockFile), cached)
+	if err != nil {
+		log.Warn("write cached block failed", "chainID", cid, "height", entry.height, "err", err)
+		_ = os.RemoveAll(dir)
+		return
+	}
+	entry.size = size
+	c.addEntry(entry, true)
+	c.evict()
+}
+
+func (c *blockCache) getReceipt(chainID *big.Int, blockHash, txHash common.Hash) *types.Receipt {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry := c.touch(chainID.String(), blockHash)
+	if entry == nil {
+		return nil
+	}
+	var cached cachedReceipt
+	if err := readJSONFile(filepath.Join(c.blockDir(entry), txHash.Hex()+cachedReceiptExt), &cached); err != nil {
+		return nil
+	}
+	return &types.Receipt{
+		Status:      cached.Status,
+		GasUsed:     cached.GasUsed,
+		Logs:        cached.Logs,
+		TxHash:      txHash,
+		BlockHash:   blockHash,
+		BlockNumber: new(big.Int).SetUint64(entry.height),
+	}
+}
+
+// put receipt into cache if its block is cached
+func (c *blockCache) putReceipt(chainID *big.Int, receipt *types.Receipt) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	elem, exist := c.entries[cacheEntryKey(chainID.String(), receipt.BlockHash)]
+	if !exist {
+		return
+	}
+	entry := elem.Value.(*blockCacheEntry)
+	path := filepath.Join(c.blockDir(entry), receipt.TxHash.Hex()+cachedReceiptExt)
+	if common.FileExist(path) {
+		return
+	}
+	cached := &cachedReceipt{Status: receipt.Status, GasUsed: receipt.GasUsed, Logs: receipt.Logs}
+	size, err := writeJSONFile(path, cached)
+	if err != nil {
+		log.Warn("write cached receipt failed", "chainID", entry.chainID, "txHash", receipt.TxHash.Hex(), "err", err)
+		return
+	}
+	entry.size += size
+	c.size += size
+	c.evict()
+}
+
+// purge cached blocks of the chain, or all chains if chainID is nil
+func (c *blockCache) purge(chainID *big.Int) (count int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if chainID == nil || elem.Value.(*blockCacheEntry).chainID == chainID.String() {
+			c.removeEntry(elem)
+			count++
+		}
+		elem = next
+	}
+	return count
+}
+
+func readJSONFile(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// write to temp file then rename, return the written size
+func writeJSONFile(path string, v interface{}) (int64, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, data, 0600); err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
@@ -0,0 +1,532 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+
+	"github.com/gaozhengxin/bridgeAccounting/alert"
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+const (
+	btcDecimals        = 8
+	esploraTxsPageSize = 25 // txs of block in one page of electrs api
+	btcRequestTimeout  = 30 * time.Second
+	btcPollInterval    = 30 * time.Second
+)
+
+// esploraBlock block of electrs (esplora) REST api
+type esploraBlock struct {
+	ID        string `json:"id"`
+	Height    uint64 `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	TxCount   int    `json:"tx_count"`
+}
+
+// esploraTx tx of electrs REST api, the values and fee are in satoshi
+type esploraTx struct {
+	TxID string         `json:"txid"`
+	Vin  []*esploraVin  `json:"vin"`
+	Vout []*esploraVout `json:"vout"`
+	Fee  uint64         `json:"fee"`
+}
+
+type esploraVin struct {
+	IsCoinbase bool         `json:"is_coinbase"`
+	Prevout    *esploraVout `json:"prevout"` // the spent output, nil if coinbase
+}
+
+type esploraVout struct {
+	ScriptPubKeyAddress string `json:"scriptpubkey_address"` // empty if the script has no address
+	Value               uint64 `json:"value"`
+}
+
+// btcSwapScanner scanner of bitcoin like chain by the electrs REST api of gateways,
+// which records the deposits to and the redeems from the mpc addresses of its native tokens.
+// only the blocks deeper than 'StableHeight' are scanned in order, so the swap events are confirmed when recorded.
+type btcSwapScanner struct {
+	chain    string
+	gateways []string
+
+	startHeightArgument int64
+	endHeight           uint64
+	stableHeight        uint64
+	anomalyDelay        time.Duration
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	quit     chan struct{}
+	inflight *sync.WaitGroup
+
+	tokens []*params.TokenConfig // only scan these tokens if not nil
+
+	gatewayIndex  uint32
+	rpcInterval   time.Duration
+	rpcRetryCount int
+	pollInterval  time.Duration
+}
+
+func newBTCSwapScanner(parentCtx context.Context, chainCfg *params.ChainConfig) *btcSwapScanner {
+	ctx, cancel := context.WithCancel(parentCtx)
+	scanner := &btcSwapScanner{
+		ctx:           ctx,
+		cancel:        cancel,
+		quit:          make(chan struct{}),
+		inflight:      new(sync.WaitGroup),
+		rpcInterval:   1 * time.Second,
+		rpcRetryCount: 3,
+		pollInterval:  btcPollInterval,
+	}
+	scanner.chain = chainCfg.Name
+	scanner.gateways = chainCfg.Gateways
+	scanner.startHeightArgument = chainCfg.StartHeightArgument
+	scanner.endHeight = uint64(chainCfg.EndHeight)
+	scanner.stableHeight = uint64(chainCfg.StableHeight)
+	scanner.anomalyDelay = chainCfg.GetAnomalyDelay()
+
+	log.Info("get chain argument success",
+		"chain", scanner.chain,
+		"type", chainCfg.GetChainType(),
+		"network", chainCfg.GetNetwork(),
+		"gateways", scanner.gateways,
+		"start", scanner.startHeightArgument,
+		"end", scanner.endHeight,
+		"stable", scanner.stableHeight,
+	)
+	return scanner
+}
+
+// keep the gateways which respond the latest block number
+func (scanner *btcSwapScanner) initClient() error {
+	var available []string
+	for _, gateway := range scanner.gateways {
+		height, err := scanner.getLatestBlockNumber(gateway)
+		if err != nil {
+			log.Warn("connect gateway failed", "chain", scanner.chain, "gateway", gateway, "err", err)
+			continue
+		}
+		log.Info("connect gateway success", "chain", scanner.chain, "gateway", gateway, "height", height)
+		available = append(available, gateway)
+	}
+	if len(available) == 0 {
+		return fmt.Errorf("no available gateway in %v", scanner.gateways)
+	}
+	scanner.gateways = available
+	return nil
+}
+
+// scan from the synced height instead of the start height of config
+func (scanner *btcSwapScanner) resumeFrom(height int64) {
+	scanner.startHeightArgument = height
+}
+
+// the tokens of btc chain have no backfill
+func (scanner *btcSwapScanner) backfillAddedToken(tokenCfg *params.TokenConfig) {
+	log.Warn("backfill is not supported on btc chain", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID)
+}
+
+func (scanner *btcSwapScanner) gateway() string {
+	index := atomic.LoadUint32(&scanner.gatewayIndex)
+	return scanner.gateways[int(index)%len(scanner.gateways)]
+}
+
+// switch to the next gateway after request failure
+func (scanner *btcSwapScanner) switchGateway() {
+	if len(scanner.gateways) > 1 {
+		atomic.AddUint32(&scanner.gatewayIndex, 1)
+	}
+}
+
+func (scanner *btcSwapScanner) isStopped() bool {
+	select {
+	case <-scanner.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// run job in goroutine, the job is waited when stop scanner
+func (scanner *btcSwapScanner) goJob(job func()) {
+	scanner.inflight.Add(1)
+	go func() {
+		defer scanner.inflight.Done()
+		job()
+	}()
+}
+
+// stop scanning new blocks and wait the scanning block finished,
+// then cancel the requests. return false if timeout.
+func (scanner *btcSwapScanner) stop(timeout time.Duration) bool {
+	log.Info("stop scanner", "chain", scanner.chain, "timeout", timeout)
+	close(scanner.quit)
+	defer scanner.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		scanner.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Info("stop scanner finished", "chain", scanner.chain)
+		return true
+	case <-time.After(timeout):
+		log.Warn("stop scanner timeout", "chain", scanner.chain, "timeout", timeout)
+		return false
+	}
+}
+
+// get token configs of this chain, or the specified ones if exist
+func (scanner *btcSwapScanner) getTokenConfigs() []*params.TokenConfig {
+	if scanner.tokens != nil {
+		return scanner.tokens
+	}
+	return params.GetScanConfig().GetTokenConfigs(scanner.chain)
+}
+
+func (scanner *btcSwapScanner) run() {
+	latest := scanner.loopGetLatestBlockNumber()
+	from := scanner.getStableHeight(latest)
+	if scanner.startHeightArgument != 0 {
+		if scanner.startHeightArgument > 0 {
+			from = uint64(scanner.startHeightArgument)
+		} else if uint64(-scanner.startHeightArgument) < from {
+			from -= uint64(-scanner.startHeightArgument)
+		} else {
+			from = 0
+		}
+		if err := dbAPI.SetStartHeight(scanner.chain, int64(from)); err != nil {
+			log.Warn("set start height failed", "chain", scanner.chain, "start", from, "err", err)
+		}
+	}
+	scanner.checkStartGap(from)
+	scanner.goJob(scanner.auditGaps)
+	scanner.scanLoop(from)
+}
+
+// the highest block deeper than the stable height
+func (scanner *btcSwapScanner) getStableHeight(latest uint64) uint64 {
+	if latest < scanner.stableHeight {
+		return 0
+	}
+	return latest - scanner.stableHeight
+}
+
+func (scanner *btcSwapScanner) scanLoop(from uint64) {
+	log.Info("start scan loop job", "chain", scanner.chain, "from", from, "stable", scanner.stableHeight)
+	var synced uint64
+	for {
+		latest := scanner.loopGetLatestBlockNumber()
+		if scanner.isStopped() {
+			return
+		}
+		alert.OnScanTick(scanner.chain, latest, synced)
+		next := scanner.scanStableBlocks(from, latest)
+		if next > from {
+			from, synced = next, next-1
+		}
+		if scanner.isStopped() {
+			return
+		}
+		scanner.settleSwapEvents()
+		if scanner.endHeight != 0 && from >= scanner.endHeight {
+			return
+		}
+		select {
+		case <-scanner.quit:
+			return
+		case <-time.After(scanner.pollInterval):
+		}
+	}
+}
+
+// scan the stable blocks from height in order, the failed block is retried at most maxBlockRetries times
+// and then recorded as gap to heal later. return the next height to scan, the synced height is updated to the one before it.
+func (scanner *btcSwapScanner) scanStableBlocks(from, latest uint64) uint64 {
+	if latest < scanner.stableHeight {
+		return from
+	}
+	to := scanner.getStableHeight(latest)
+	if scanner.endHeight != 0 && to >= scanner.endHeight {
+		to = scanner.endHeight - 1
+	}
+	next, retries := from, 0
+	for next <= to && !scanner.isStopped() {
+		if err := scanner.scanBlock(next); err != nil {
+			log.Warn("scan block failed", "chain", scanner.chain, "height", next, "retries", retries, "err", err)
+			if retries < maxBlockRetries {
+				retries++
+				time.Sleep(scanner.rpcInterval)
+				continue
+			}
+			scanner.recordGap(next, next+1, err.Error())
+		}
+		next, retries = next+1, 0
+	}
+	if next > from {
+		scanner.updateSyncedHeight(next - 1)
+	}
+	return next
+}
+
+// the synced blocks are stable, they are confirmed too
+func (scanner *btcSwapScanner) updateSyncedHeight(height uint64) {
+	if err := dbAPI.UpdateSyncedHeight(scanner.chain, int64(height)); err != nil {
+		log.Warn("update synced height failed", "chain", scanner.chain, "height", height, "err", err)
+	}
+	if err := dbAPI.UpdateConfirmedHeight(scanner.chain, int64(height)); err != nil {
+		log.Warn("update confirmed height failed", "chain", scanner.chain, "height", height, "err", err)
+	}
+}
+
+// the swap events are confirmed when recorded, match the redeems with burns and detect anomalies
+func (scanner *btcSwapScanner) settleSwapEvents() {
+	tokenCfgs := scanner.getTokenConfigs()
+	matchRedeems(tokenCfgs)
+	detectAnomalies(tokenCfgs, scanner.anomalyDelay)
+}
+
+// scan txs of block page by page, return error if the block should be rescanned
+func (scanner *btcSwapScanner) scanBlock(height uint64) error {
+	block, err := scanner.getBlock(height)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < block.TxCount; start += esploraTxsPageSize {
+		txs, err := scanner.getBlockTxs(block.ID, start)
+		if err != nil {
+			return err
+		}
+		for _, tx := range txs {
+			if err = scanner.scanTransaction(block, tx); err != nil {
+				return err
+			}
+		}
+	}
+	log.Info("scan block success", "chain", scanner.chain, "height", height, "hash", block.ID, "txs", block.TxCount)
+	return nil
+}
+
+func (scanner *btcSwapScanner) scanTransaction(block *esploraBlock, tx *esploraTx) error {
+	for _, tokenCfg := range scanner.getTokenConfigs() {
+		swapTxType, swapEvents := verifyBTCTransaction(tokenCfg, block, tx)
+		if swapTxType == TypeNull {
+			continue
+		}
+		for _, swapEvent := range swapEvents {
+			if err := scanner.recordSwapEvent(swapTxType, tokenCfg, swapEvent); err != nil {
+				log.Warn("swap event is not recorded", "pairID", tokenCfg.PairID, "swapTxType", swapTxType, "txHash", swapEvent.TxHash, "err", err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// the tx spending from a redeem sender is redeemed to each of its outputs to the non mpc addresses,
+// which is keyed by the tx hash and the output index if there are several of them.
+// otherwise the tx paying to the deposit addresses is deposit from the address of its first input.
+// the tx hash is the txid with '0x' prefix, which is the ref tx hash of the mint of deposit.
+// the tx fee is set to the first swap event only.
+func verifyBTCTransaction(tokenCfg *params.TokenConfig, block *esploraBlock, tx *esploraTx) (SwapTxType, []*mongodb.SwapEvent) {
+	var sender, redeemSender string
+	for _, vin := range tx.Vin {
+		if vin.IsCoinbase || vin.Prevout == nil || vin.Prevout.ScriptPubKeyAddress == "" {
+			continue
+		}
+		address := vin.Prevout.ScriptPubKeyAddress
+		if sender == "" {
+			sender = address
+		}
+		if redeemSender == "" && tokenCfg.IsRedeemSender(address, block.Height) {
+			redeemSender = address
+		}
+	}
+
+	txHash := "0x" + strings.ToLower(tx.TxID)
+	var swapEvents []*mongodb.SwapEvent
+	if redeemSender != "" {
+		// the change and the transfers between mpc addresses are not redeemed
+		mpcAddresses := tokenCfg.GetMPCAddresses()
+		var outputs []uint
+		for i, vout := range tx.Vout {
+			address := vout.ScriptPubKeyAddress
+			if address == "" || vout.Value == 0 || params.ContainsAddress(mpcAddresses, address) {
+				continue
+			}
+			outputs = append(outputs, uint(i))
+		}
+		for _, i := range outputs {
+			key := txHash
+			if len(outputs) > 1 {
+				key = swapEventKey(txHash, i)
+			}
+			vout := tx.Vout[i]
+			swapEvents = append(swapEvents, newBTCSwapEvent(tokenCfg, block, key, vout.ScriptPubKeyAddress, redeemSender, vout.Value))
+		}
+		if len(swapEvents) == 0 {
+			return TypeNull, nil
+		}
+		setBTCTxFee(swapEvents[0], tx)
+		return TypeRedeemed, swapEvents
+	}
+
+	var (
+		mpc    string
+		amount uint64
+	)
+	for _, vout := range tx.Vout {
+		address := vout.ScriptPubKeyAddress
+		if address == "" || !tokenCfg.IsDepositAddress(address, block.Height) {
+			continue
+		}
+		if mpc == "" {
+			mpc = address
+		}
+		amount += vout.Value
+	}
+	if amount == 0 {
+		return TypeNull, nil
+	}
+	swapEvent := newBTCSwapEvent(tokenCfg, block, txHash, sender, mpc, amount)
+	setBTCTxFee(swapEvent, tx)
+	return TypeDeposit, []*mongodb.SwapEvent{swapEvent}
+}
+
+func newBTCSwapEvent(tokenCfg *params.TokenConfig, block *esploraBlock, key, user, mpc string, amount uint64) *mongodb.SwapEvent {
+	decimals := btcDecimals
+	if tokenCfg.Decimal != 0 {
+		decimals = tokenCfg.Decimal
+	}
+	value := new(big.Int).SetUint64(amount)
+	return &mongodb.SwapEvent{
+		TxHash:      key,
+		Chain:       tokenCfg.Chain,
+		BlockTime:   block.Timestamp,
+		BlockNumber: int64(block.Height),
+		Amount:      value.String(),
+		Decimals:    decimals,
+		FAmount:     toFloat(value, decimals),
+		User:        user,
+		MPCAddress:  mpc,
+	}
+}
+
+func setBTCTxFee(swapEvent *mongodb.SwapEvent, tx *esploraTx) {
+	fee := new(big.Int).SetUint64(tx.Fee)
+	swapEvent.TxFee = fee.String()
+	swapEvent.FTxFee = toFloat(fee, btcDecimals)
+}
+
+// the stable blocks are only rescanned after restart, so the existing swap event is not changed
+func (scanner *btcSwapScanner) recordSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	err := addSwapEvent(swapTxType, tokenCfg, data)
+	switch {
+	case err == nil:
+		alert.OnSwapEvent(tokenCfg.PairID, swapTxType.String(), data)
+	case strings.Contains(err.Error(), swapExistKeywords):
+	default:
+		return err
+	}
+	return nil
+}
+
+func (scanner *btcSwapScanner) loopGetLatestBlockNumber() uint64 {
+	for !scanner.isStopped() { // retry until success
+		height, err := scanner.getLatestBlockNumber(scanner.gateway())
+		if err == nil {
+			log.Info("get latest block number success", "chain", scanner.chain, "height", height)
+			return height
+		}
+		log.Warn("get latest block number failed", "chain", scanner.chain, "err", err)
+		scanner.switchGateway()
+		time.Sleep(scanner.rpcInterval)
+	}
+	return 0
+}
+
+func (scanner *btcSwapScanner) getLatestBlockNumber(gateway string) (uint64, error) {
+	body, err := scanner.httpGet(gateway, "/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(bytes.TrimSpace(body)), 10, 64)
+}
+
+func (scanner *btcSwapScanner) getBlock(height uint64) (*esploraBlock, error) {
+	body, err := scanner.callGateway(fmt.Sprintf("/block-height/%d", height))
+	if err != nil {
+		return nil, err
+	}
+	hash := string(bytes.TrimSpace(body))
+	var block esploraBlock
+	if err = scanner.callGatewayJSON("/block/"+hash, &block); err != nil {
+		return nil, err
+	}
+	if block.ID != hash || block.Height != height {
+		return nil, fmt.Errorf("block mismatch, want %v at %v, have %v at %v", hash, height, block.ID, block.Height)
+	}
+	return &block, nil
+}
+
+func (scanner *btcSwapScanner) getBlockTxs(hash string, start int) (txs []*esploraTx, err error) {
+	err = scanner.callGatewayJSON(fmt.Sprintf("/block/%v/txs/%d", hash, start), &txs)
+	return txs, err
+}
+
+func (scanner *btcSwapScanner) callGatewayJSON(path string, result interface{}) error {
+	body, err := scanner.callGateway(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, result)
+}
+
+// get from the current gateway with retry, switch gateway after failure
+func (scanner *btcSwapScanner) callGateway(path string) (body []byte, err error) {
+	for i := 0; i < scanner.rpcRetryCount; i++ {
+		if body, err = scanner.httpGet(scanner.gateway(), path); err == nil {
+			return body, nil
+		}
+		log.Warn("call gateway failed", "chain", scanner.chain, "path", path, "err", err)
+		scanner.switchGateway()
+		time.Sleep(scanner.rpcInterval)
+	}
+	return nil, err
+}
+
+func (scanner *btcSwapScanner) httpGet(gateway, path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(scanner.ctx, btcRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(gateway, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gateway responds status %v: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/anyswap/CrossChain-Bridge/cmd/utils"
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/urfave/cli/v2"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+var (
+	// BlockCacheCommand manage the on-disk block cache
+	BlockCacheCommand = &cli.Command{
+		Name:      "blockcache",
+		Usage:     "manage the on-disk block and receipt cache",
+		ArgsUsage: " ",
+		Description: `
+prewarm or purge the block cache configured by 'BlockCache' in config file.
+`,
+		Subcommands: []*cli.Command{
+			{
+				Action:    prewarmBlockCache,
+				Name:      "prewarm",
+				Usage:     "fetch stable blocks (and receipts if 'ScanReceipt') in range [from, to) into cache",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					utils.ConfigFileFlag,
+					chainFlag,
+					fromHeightFlag,
+					toHeightFlag,
+					jobsFlag,
+				},
+			},
+			{
+				Action:    purgeBlockCache,
+				Name:      "purge",
+				Usage:     "remove cached blocks of chain, or all chains if '--chain' is not specified",
+				ArgsUsage: " ",
+				Flags: []cli.Flag{
+					utils.ConfigFileFlag,
+					chainFlag,
+				},
+			},
+		},
+	}
+)
+
+func loadBlockCache(ctx *cli.Context) (*params.ScanConfig, error) {
+	utils.SetLogger(ctx)
+	cfg := params.LoadConfig(utils.GetConfigFilePath(ctx))
+	if cfg.BlockCache == nil {
+		return nil, errors.New("block cache is not configured")
+	}
+	return cfg, initBlockCache(cfg.BlockCache)
+}
+
+func prewarmBlockCache(ctx *cli.Context) error {
+	cfg, err := loadBlockCache(ctx)
+	if err != nil {
+		return err
+	}
+	chainCfg, err := getEVMChainConfig(cfg, ctx.String(chainFlag.Name))
+	if err != nil {
+		return err
+	}
+	from := ctx.Uint64(fromHeightFlag.Name)
+	to := ctx.Uint64(toHeightFlag.Name)
+	if from >= to {
+		return fmt.Errorf("wrong prewarm range [%v, %v)", from, to)
+	}
+
+	scanner := newEthSwapScanner(ctx.Context, chainCfg)
+	if jobs := ctx.Uint64(jobsFlag.Name); jobs > 0 {
+		scanner.jobCount = jobs
+	}
+	if err = scanner.initClient(); err != nil {
+		return err
+	}
+	if latest := scanner.loopGetLatestBlockNumber(); to+scanner.stableHeight > latest+1 {
+		return fmt.Errorf("only stable blocks are cached, 'to' must not exceed %v", latest+1-scanner.stableHeight)
+	}
+
+	var cached, failed uint64
+	heights := make(chan uint64)
+	wg := new(sync.WaitGroup)
+	for i := uint64(0); i < scanner.jobCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for height := range heights {
+				block, err := scanner.loopGetBlock(height)
+				if err != nil {
+					atomic.AddUint64(&failed, 1)
+					continue
+				}
+				if scanner.scanReceipt {
+					for _, tx := range block.Transactions() {
+						if _, err = scanner.loopGetTxReceipt(tx.Hash(), block.Hash()); err != nil {
+							log.Warn("prewarm tx receipt failed", "txHash", tx.Hash().Hex(), "err", err)
+						}
+					}
+				}
+				atomic.AddUint64(&cached, 1)
+			}
+		}()
+	}
+	for h := from; h < to; h++ {
+		heights <- h
+	}
+	close(heights)
+	wg.Wait()
+
+	fmt.Printf("cached: %v\nfailed: %v\n", cached, failed)
+	return nil
+}
+
+func purgeBlockCache(ctx *cli.Context) error {
+	cfg, err := loadBlockCache(ctx)
+	if err != nil {
+		return err
+	}
+	var count int
+	if chain := ctx.String(chainFlag.Name); chain != "" {
+		chainCfg, err := getEVMChainConfig(cfg, chain)
+		if err != nil {
+			return err
+		}
+		count = blkCache.purge(chainCfg.GetChainID())
+	} else {
+		count = blkCache.purge(nil)
+	}
+	fmt.Printf("purged: %v\n", count)
+	return nil
+}
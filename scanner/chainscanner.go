@@ -0,0 +1,48 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// chainScanner scanner of the swaps on chain, which is started and stopped by the manager
+type chainScanner interface {
+	initClient() error
+	// scan from the synced height instead of the start height of config
+	resumeFrom(height int64)
+	// scan blocks until stopped, or to the end height
+	run()
+	// run job in goroutine, the job is waited when stop scanner
+	goJob(job func())
+	stop(timeout time.Duration) bool
+	// scan history of the token added by config reload in background
+	backfillAddedToken(tokenCfg *params.TokenConfig)
+}
+
+var (
+	_ chainScanner = (*ethSwapScanner)(nil)
+	_ chainScanner = (*btcSwapScanner)(nil)
+)
+
+// new scanner by the chain type
+func newChainScanner(ctx context.Context, chainCfg *params.ChainConfig) chainScanner {
+	if chainCfg.IsBTCChain() {
+		return newBTCSwapScanner(ctx, chainCfg)
+	}
+	return newEthSwapScanner(ctx, chainCfg)
+}
+
+// get config of evm chain, as the commands working on blocks and receipts do not support other chains
+func getEVMChainConfig(cfg *params.ScanConfig, chain string) (*params.ChainConfig, error) {
+	chainCfg := cfg.GetChainConfig(chain)
+	if chainCfg == nil {
+		return nil, fmt.Errorf("chain config not found: %v", chain)
+	}
+	if chainCfg.IsBTCChain() {
+		return nil, fmt.Errorf("not supported on btc chain: %v", chain)
+	}
+	return chainCfg, nil
+}
@@ -0,0 +1,211 @@
+package scanner
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/anyswap/CrossChain-Bridge/cmd/utils"
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/urfave/cli/v2"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+var (
+	outputFlag = &cli.StringFlag{
+		Name:  "output",
+		Usage: "output archive file, RLP format if ends with '.rlp', otherwise JSON-lines",
+	}
+
+	allReceiptsFlag = &cli.BoolFlag{
+		Name:  "allReceipts",
+		Usage: "export receipts of all transactions, default only the ones needed by the token configs",
+	}
+
+	archiveFlag = &cli.StringFlag{
+		Name:  "archive",
+		Usage: "scan offline from the archive exported by 'export-blocks'",
+	}
+
+	// ExportBlocksCommand export blocks and receipts to archive file
+	ExportBlocksCommand = &cli.Command{
+		Action:    exportBlocks,
+		Name:      "export-blocks",
+		Usage:     "export blocks and receipts in range [from, to) to archive file",
+		ArgsUsage: " ",
+		Description: `
+export blocks and receipts to archive file, which can be scanned offline
+by setting 'ArchiveFile' of chain config or the '--archive' flag of rescan.
+`,
+		Flags: []cli.Flag{
+			utils.ConfigFileFlag,
+			chainFlag,
+			fromHeightFlag,
+			toHeightFlag,
+			outputFlag,
+			jobsFlag,
+			allReceiptsFlag,
+		},
+	}
+)
+
+func exportBlocks(ctx *cli.Context) error {
+	utils.SetLogger(ctx)
+	cfg := params.LoadConfig(utils.GetConfigFilePath(ctx))
+
+	chainCfg, err := getEVMChainConfig(cfg, ctx.String(chainFlag.Name))
+	if err != nil {
+		return err
+	}
+	from := ctx.Uint64(fromHeightFlag.Name)
+	to := ctx.Uint64(toHeightFlag.Name)
+	if from >= to {
+		return fmt.Errorf("wrong export range [%v, %v)", from, to)
+	}
+	output := ctx.String(outputFlag.Name)
+	if output == "" {
+		return fmt.Errorf("empty output file")
+	}
+	if err := initBlockCache(cfg.BlockCache); err != nil {
+		return err
+	}
+
+	scanner := newEthSwapScanner(ctx.Context, chainCfg)
+	if jobs := ctx.Uint64(jobsFlag.Name); jobs > 0 {
+		scanner.jobCount = jobs
+	}
+	scanner.archiveFile = "" // export from gateways
+	if err := scanner.initClient(); err != nil {
+		return err
+	}
+
+	writer, err := createArchive(output, &archiveMeta{ChainID: scanner.chainId, From: from, To: to})
+	if err != nil {
+		return err
+	}
+	needReceipt := receiptFilter(cfg.GetTokenConfigs(chainCfg.Name), scanner.chainId, scanner.scanReceipt || ctx.Bool(allReceiptsFlag.Name), scanner.watchAddresses)
+
+	// fetch blocks concurrently in batches, and write them in order
+	var receipts int
+	for start := from; start < to; start += scanner.jobCount {
+		end := start + scanner.jobCount
+		if end > to {
+			end = to
+		}
+		blocks := make([]*archivedBlock, end-start)
+		errs := make([]error, end-start)
+		wg := new(sync.WaitGroup)
+		for h := start; h < end; h++ {
+			wg.Add(1)
+			go func(h uint64) {
+				defer wg.Done()
+				blocks[h-start], errs[h-start] = scanner.fetchArchivedBlock(h, needReceipt)
+			}(h)
+		}
+		wg.Wait()
+		for i, block := range blocks {
+			if errs[i] != nil {
+				_ = writer.close()
+				return fmt.Errorf("export block %v failed: %w", start+uint64(i), errs[i])
+			}
+			if err = writer.writeBlock(block); err != nil {
+				_ = writer.close()
+				return err
+			}
+			receipts += len(block.Receipts)
+		}
+		log.Info("export blocks", "chain", chainCfg.Name, "exported", end)
+	}
+	if err = writer.close(); err != nil {
+		return err
+	}
+	fmt.Printf("blocks: %v\nreceipts: %v\noutput: %v\n", to-from, receipts, output)
+	return nil
+}
+
+func (scanner *ethSwapScanner) fetchArchivedBlock(height uint64, needReceipt func(*types.Transaction) bool) (*archivedBlock, error) {
+	block, err := scanner.loopGetBlock(height)
+	if err != nil {
+		return nil, err
+	}
+	archived := &archivedBlock{
+		Header:       block.Header(),
+		Transactions: block.Transactions(),
+	}
+	for _, tx := range block.Transactions() {
+		if !needReceipt(tx) {
+			continue
+		}
+		receipt, err := scanner.loopGetTxReceipt(tx.Hash(), block.Hash())
+		if err != nil {
+			return nil, err
+		}
+		archived.Receipts = append(archived.Receipts, receipt)
+	}
+	return archived, nil
+}
+
+// txs sent to the contracts of token configs need receipts when verify,
+// the native swap txs need receipts for their gas fee,
+// and the txs sent from or to the deposit and redeem addresses need receipts in address watch mode
+func receiptFilter(tokenCfgs []*params.TokenConfig, chainID *big.Int, all, watch bool) func(*types.Transaction) bool {
+	contracts := make(map[string]struct{})
+	nativeMPCs := make(map[string]struct{})
+	watched := make(map[string]struct{})
+	addContract := func(contract string) {
+		if contract != "" {
+			contracts[strings.ToLower(contract)] = struct{}{}
+		}
+	}
+	for _, tokenCfg := range tokenCfgs {
+		if !tokenCfg.IsNativeToken() {
+			addContract(tokenCfg.TokenAddress)
+		}
+		for _, address := range tokenCfg.GetMPCAddresses() {
+			if tokenCfg.IsNativeToken() {
+				nativeMPCs[strings.ToLower(address)] = struct{}{}
+			}
+			if watch {
+				watched[strings.ToLower(address)] = struct{}{}
+			}
+		}
+		addContract(tokenCfg.RouterContract)
+		addContract(tokenCfg.CallByContract)
+		for _, mapping := range tokenCfg.ABIMappings {
+			addContract(abiMappingContract(mapping, tokenCfg))
+		}
+	}
+	signer := types.LatestSignerForChainID(chainID)
+	return func(tx *types.Transaction) bool {
+		if tx.To() != nil {
+			if all {
+				return true
+			}
+			to := strings.ToLower(tx.To().Hex())
+			if _, exist := contracts[to]; exist {
+				return true
+			}
+			if _, exist := nativeMPCs[to]; exist {
+				return true
+			}
+			if _, exist := watched[to]; exist {
+				return true
+			}
+		}
+		if len(watched) == 0 && (tx.To() == nil || len(nativeMPCs) == 0 || tx.Value().Sign() == 0) {
+			return false
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return false
+		}
+		if _, exist := watched[strings.ToLower(from.Hex())]; exist {
+			return true
+		}
+		_, exist := nativeMPCs[strings.ToLower(from.Hex())]
+		return exist && tx.To() != nil && tx.Value().Sign() != 0
+	}
+}
@@ -0,0 +1,227 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// get receipt of tx in block, which is needed for the gas fee of swap tx and the logs of watched tx,
+// return nil receipt if the archive is exported without it
+func (scanner *ethSwapScanner) getBlockTxReceipt(tx *types.Transaction, header *types.Header) (*types.Receipt, error) {
+	receipt, err := scanner.loopGetTxReceipt(tx.Hash(), header.Hash())
+	switch {
+	case errors.Is(err, errArchiveReceiptNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("get tx receipt failed: %w", err)
+	case receipt == nil || receipt.BlockHash != header.Hash():
+		return nil, fmt.Errorf("tx is not in block %v", header.Hash().Hex())
+	}
+	return receipt, nil
+}
+
+// set gas used and effective gas price of swap tx, nothing is set if receipt is nil
+func setTxFee(swapData *SwapEvent, tx *types.Transaction, receipt *types.Receipt, header *types.Header) {
+	if receipt == nil {
+		return
+	}
+	gasPrice := tx.GasPrice()
+	if header.BaseFee != nil {
+		if tip, err := tx.EffectiveGasTip(header.BaseFee); err == nil {
+			gasPrice = new(big.Int).Add(tip, header.BaseFee)
+		}
+	}
+	swapData.GasUsed = receipt.GasUsed
+	swapData.GasPrice = gasPrice
+}
+
+// match mint with its deposit, the bridge fee is the difference of their amounts.
+// nothing is written, the deposit is marked matched by markDepositMatched after the mint is recorded.
+func matchDeposit(tokenCfg *params.TokenConfig, mint *mongodb.SwapEvent) {
+	if mint.RefTxHash == "" {
+		return
+	}
+	deposit, err := getRefSwapEvent(TypeDeposit, tokenCfg, mint.RefTxHash)
+	if err != nil {
+		return
+	}
+	bridgeFee, err := computeBridgeFee(deposit, mint)
+	if err != nil {
+		log.Warn("compute bridge fee failed", "pairID", tokenCfg.PairID, "txHash", mint.TxHash, "deposit", deposit.TxHash, "err", err)
+		return
+	}
+	mint.Matched = true
+	mint.BridgeFee = bridgeFee
+}
+
+// mark the deposit of the recorded mint matched, so that the stuck deposits can be found
+func markDepositMatched(tokenCfg *params.TokenConfig, mint *mongodb.SwapEvent) {
+	if !mint.Matched || mint.RefTxHash == "" {
+		return
+	}
+	deposit, err := getRefSwapEvent(TypeDeposit, tokenCfg, mint.RefTxHash)
+	if err != nil || deposit.Matched {
+		return
+	}
+	if err = dbAPI.MatchDeposit(tokenCfg, deposit.TxHash); err != nil {
+		log.Warn("match deposit failed", "pairID", tokenCfg.PairID, "txHash", deposit.TxHash, "err", err)
+	}
+}
+
+// get the swap event referred by tx hash, the ref tx hash of router swap has no index of the keyed swap event,
+// then the first unmatched swap event of the tx is referred, or the first one if all are matched
+func getRefSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, refTxHash string) (*mongodb.SwapEvent, error) {
+	event, err := getSwapEvent(swapTxType, tokenCfg, refTxHash)
+	if err == nil || swapEventTxHash(refTxHash) != refTxHash {
+		return event, err
+	}
+	iter, err := dbAPI.GetSwapEventsByTxHash(mongodb.TxType(swapTxType), tokenCfg, refTxHash)
+	if err != nil {
+		return nil, err
+	}
+	var first *mongodb.SwapEvent
+	for {
+		event = new(mongodb.SwapEvent)
+		if !iter.Next(event) {
+			break
+		}
+		if !event.Matched {
+			return event, nil
+		}
+		if first == nil {
+			first = event
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("swap event of ref tx %v not found", refTxHash)
+	}
+	return first, nil
+}
+
+// the bridge fee is the deposit amount minus the mint amount, which are scaled to the same decimals,
+// it is computed in big int to avoid the float rounding, and converted to float at the end
+func computeBridgeFee(deposit, mint *mongodb.SwapEvent) (float64, error) {
+	depositAmount, ok := new(big.Int).SetString(deposit.Amount, 10)
+	if !ok {
+		return 0, fmt.Errorf("wrong deposit amount %v", deposit.Amount)
+	}
+	mintAmount, ok := new(big.Int).SetString(mint.Amount, 10)
+	if !ok {
+		return 0, fmt.Errorf("wrong mint amount %v", mint.Amount)
+	}
+	decimals := deposit.Decimals
+	if mint.Decimals > decimals {
+		decimals = mint.Decimals
+	}
+	scale := func(amount *big.Int, from int) *big.Int {
+		return amount.Mul(amount, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals-from)), nil))
+	}
+	fee := new(big.Int).Sub(scale(depositAmount, deposit.Decimals), scale(mintAmount, mint.Decimals))
+	return toFloat(fee, decimals), nil
+}
+
+// match the mints of tokens whose deposits are recorded after them
+func matchMints(tokenCfgs []*params.TokenConfig) {
+	for _, tokenCfg := range tokenCfgs {
+		if tokenCfg.IsSrcToken {
+			continue
+		}
+		iter, err := dbAPI.GetUnmatchedMints(tokenCfg)
+		if err != nil {
+			log.Warn("get unmatched mints failed", "pairID", tokenCfg.PairID, "err", err)
+			continue
+		}
+		for {
+			var mint mongodb.SwapEvent
+			if !iter.Next(&mint) {
+				break
+			}
+			matchDeposit(tokenCfg, &mint)
+			if !mint.Matched {
+				continue
+			}
+			if err = dbAPI.MatchMint(tokenCfg, mint.TxHash, mint.BridgeFee); err != nil {
+				log.Warn("match mint failed", "pairID", tokenCfg.PairID, "txHash", mint.TxHash, "err", err)
+				continue
+			}
+			markDepositMatched(tokenCfg, &mint)
+			// the bridge fee is summarized again if the mint is summarized
+			markDirtyHeight(tokenCfg.Chain, mint.BlockNumber)
+			log.Info("match mint success", "pairID", tokenCfg.PairID, "txHash", mint.TxHash, "deposit", mint.RefTxHash, "bridgeFee", mint.BridgeFee)
+		}
+	}
+}
+
+// match the redeems of tokens with their burns,
+// the burn is the ref tx if exists, or else the earliest unmatched one to the receiver with enough amount
+func matchRedeems(tokenCfgs []*params.TokenConfig) {
+	for _, tokenCfg := range tokenCfgs {
+		if !tokenCfg.IsSrcToken {
+			continue
+		}
+		iter, err := dbAPI.GetUnmatchedRedeems(tokenCfg)
+		if err != nil {
+			log.Warn("get unmatched redeems failed", "pairID", tokenCfg.PairID, "err", err)
+			continue
+		}
+		for {
+			var redeemed mongodb.SwapEvent
+			if !iter.Next(&redeemed) {
+				break
+			}
+			burnTxHash := matchBurn(tokenCfg, &redeemed)
+			if burnTxHash == "" {
+				continue
+			}
+			if err = dbAPI.MatchRedeemed(tokenCfg, redeemed.TxHash, burnTxHash); err != nil {
+				log.Warn("match redeemed failed", "pairID", tokenCfg.PairID, "txHash", redeemed.TxHash, "burn", burnTxHash, "err", err)
+				continue
+			}
+			log.Info("match redeemed success", "pairID", tokenCfg.PairID, "txHash", redeemed.TxHash, "burn", burnTxHash)
+		}
+	}
+}
+
+// find and mark the burn of redeem matched, return its tx hash or empty if not found
+func matchBurn(tokenCfg *params.TokenConfig, redeemed *mongodb.SwapEvent) string {
+	if redeemed.RefTxHash != "" {
+		burn, err := getRefSwapEvent(TypeBurn, tokenCfg, redeemed.RefTxHash)
+		if err != nil {
+			return ""
+		}
+		if !burn.Matched {
+			if err = dbAPI.MatchBurn(tokenCfg, burn.TxHash); err != nil {
+				log.Warn("match burn failed", "pairID", tokenCfg.PairID, "txHash", burn.TxHash, "err", err)
+			}
+		}
+		return burn.TxHash
+	}
+	iter, err := dbAPI.GetUnmatchedBurnsByBind(tokenCfg, redeemed.User, redeemed.BlockTime)
+	if err != nil {
+		log.Warn("get unmatched burns failed", "pairID", tokenCfg.PairID, "bind", redeemed.User, "err", err)
+		return ""
+	}
+	for {
+		var burn mongodb.SwapEvent
+		if !iter.Next(&burn) {
+			break
+		}
+		// the bridge fee is deducted from the burn amount
+		if burn.FAmount < redeemed.FAmount {
+			continue
+		}
+		// fails if the burn is matched by another redeem meanwhile
+		if err = dbAPI.MatchBurn(tokenCfg, burn.TxHash); err != nil {
+			continue
+		}
+		return burn.TxHash
+	}
+	return ""
+}
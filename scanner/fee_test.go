@@ -0,0 +1,124 @@
+package scanner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+func TestComputeBridgeFee(t *testing.T) {
+	tests := []struct {
+		name    string
+		deposit *mongodb.SwapEvent
+		mint    *mongodb.SwapEvent
+		fee     float64
+		wantErr bool
+	}{
+		{
+			name:    "same decimals",
+			deposit: &mongodb.SwapEvent{Amount: "2000000000000000000", Decimals: 18},
+			mint:    &mongodb.SwapEvent{Amount: "1990000000000000000", Decimals: 18},
+			fee:     0.01,
+		},
+		{
+			name:    "different decimals",
+			deposit: &mongodb.SwapEvent{Amount: "1500000", Decimals: 8},
+			mint:    &mongodb.SwapEvent{Amount: "14000000000000000", Decimals: 18},
+			fee:     0.001,
+		},
+		{
+			name:    "mint more than deposit",
+			deposit: &mongodb.SwapEvent{Amount: "1000000", Decimals: 6},
+			mint:    &mongodb.SwapEvent{Amount: "1250000000000000000", Decimals: 18},
+			fee:     -0.25,
+		},
+		{
+			name:    "wrong amount",
+			deposit: &mongodb.SwapEvent{Amount: "0.5", Decimals: 6},
+			mint:    &mongodb.SwapEvent{Amount: "1", Decimals: 6},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, err := computeBridgeFee(tt.deposit, tt.mint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error mismatch, have %v want error %v", err, tt.wantErr)
+			}
+			if fee != tt.fee {
+				t.Errorf("bridge fee mismatch, have %v want %v", fee, tt.fee)
+			}
+		})
+	}
+}
+
+func TestGetRefSwapEventOfRouterSwaps(t *testing.T) {
+	db := newMemorySyncAPI()
+	defer func(api mongodb.SyncAPI) { dbAPI = api }(dbAPI)
+	dbAPI = db
+
+	tokenCfg := &params.TokenConfig{Chain: "test", PairID: "anyDAI", TokenType: params.TokenTypeRouter}
+	txHash := "0x7a3bd3a33ad8cb7e8f1a7d2c3e1d9b6f2e4a6c8b0d2f4a6c8e0b2d4f6a8c0e2f"
+	for _, index := range []uint{3, 7} {
+		if err := db.AddBurn(tokenCfg, &mongodb.SwapEvent{TxHash: swapEventKey(txHash, index), Chain: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	burn, err := getRefSwapEvent(TypeBurn, tokenCfg, txHash)
+	if err != nil || burn.TxHash != swapEventKey(txHash, 3) {
+		t.Fatalf("ref burn mismatch, have %v err %v want %v", burn, err, swapEventKey(txHash, 3))
+	}
+	if err = db.MatchBurn(tokenCfg, burn.TxHash); err != nil {
+		t.Fatal(err)
+	}
+	if burn, err = getRefSwapEvent(TypeBurn, tokenCfg, txHash); err != nil || burn.TxHash != swapEventKey(txHash, 7) {
+		t.Fatalf("ref burn after matched mismatch, have %v err %v want %v", burn, err, swapEventKey(txHash, 7))
+	}
+	if _, err = getRefSwapEvent(TypeBurn, tokenCfg, "0x"+strings.Repeat("00", 32)); err == nil {
+		t.Errorf("ref burn of unknown tx is found")
+	}
+}
+
+func TestDepositMatchedAfterMintRecorded(t *testing.T) {
+	db := newMemorySyncAPI()
+	defer func(api mongodb.SyncAPI) { dbAPI = api }(dbAPI)
+	dbAPI = db
+
+	srcToken := &params.TokenConfig{Chain: "src", PairID: "usdt", IsSrcToken: true}
+	dstToken := &params.TokenConfig{Chain: "dst", PairID: "usdt"}
+	depositHash := "0x" + strings.Repeat("11", 32)
+	if err := db.AddDeposit(srcToken, &mongodb.SwapEvent{TxHash: depositHash, Chain: "src", Amount: "2000000", Decimals: 6}); err != nil {
+		t.Fatal(err)
+	}
+	newMint := func() *mongodb.SwapEvent {
+		mint := &mongodb.SwapEvent{TxHash: "0x" + strings.Repeat("22", 32), Chain: "dst", Amount: "1990000000000000000", Decimals: 18, RefTxHash: depositHash}
+		matchDeposit(dstToken, mint)
+		if !mint.Matched || mint.BridgeFee != 0.01 {
+			t.Fatalf("mint is not matched with deposit: %+v", mint)
+		}
+		return mint
+	}
+	depositMatched := func() bool {
+		deposit, err := db.GetDeposit(srcToken, depositHash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return deposit.Matched
+	}
+
+	if newMint(); depositMatched() {
+		t.Fatalf("deposit is matched before the mint is recorded")
+	}
+	scanner := &ethSwapScanner{chain: "dst", dryRun: true, rescanStats: &rescanStats{}}
+	scanner.recordSwapEvent(TypeMint, dstToken, newMint())
+	if depositMatched() {
+		t.Fatalf("deposit is matched in dry run")
+	}
+	scanner.dryRun = false
+	scanner.recordSwapEvent(TypeMint, dstToken, newMint())
+	if !depositMatched() {
+		t.Errorf("deposit is not matched after the mint is recorded")
+	}
+}
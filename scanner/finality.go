@@ -0,0 +1,220 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+var errSwapTxNotFound = errors.New("swap tx not found")
+
+const (
+	confirmInterval        = 15 * time.Second
+	finalityCheckerTimeout = 10 * time.Second
+)
+
+// get the latest final block number by the finality mode of chain
+func (scanner *ethSwapScanner) getFinalizedHeight() (uint64, error) {
+	switch scanner.finalityMode {
+	case params.FinalityModeFinalized, params.FinalityModeSafe:
+		return scanner.getTaggedBlockNumber(scanner.finalityMode)
+	case params.FinalityModeChecker:
+		return scanner.getCheckerFinalizedHeight()
+	default:
+		latest := scanner.loopGetLatestBlockNumber()
+		if latest < scanner.stableHeight {
+			return 0, nil
+		}
+		return latest - scanner.stableHeight, nil
+	}
+}
+
+// get block number of the 'finalized' or 'safe' block tag,
+// the blocks of offline source are regarded as final as it has no block tags
+func (scanner *ethSwapScanner) getTaggedBlockNumber(tag string) (uint64, error) {
+	if scanner.offline != nil {
+		return scanner.loopGetLatestBlockNumber(), nil
+	}
+	var err error
+	for i := 0; i < scanner.rpcRetryCount; i++ { // with retry
+		var head *struct {
+			Number *hexutil.Big `json:"number"`
+		}
+		err = scanner.rpcClient().CallContext(scanner.ctx, &head, "eth_getBlockByNumber", tag, false)
+		if err == nil && (head == nil || head.Number == nil) {
+			err = fmt.Errorf("%v block not found", tag)
+		}
+		if err == nil {
+			return head.Number.ToInt().Uint64(), nil
+		}
+		log.Warn("get block number of tag failed", "chain", scanner.chain, "tag", tag, "err", err)
+		scanner.switchClient()
+		time.Sleep(scanner.rpcInterval)
+	}
+	return 0, err
+}
+
+// get finalized height from external checker, which responds '{"finalized": <height>}'
+func (scanner *ethSwapScanner) getCheckerFinalizedHeight() (uint64, error) {
+	ctx, cancel := context.WithTimeout(scanner.ctx, finalityCheckerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scanner.finalityChecker, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("finality checker responds status %v", resp.Status)
+	}
+	var result struct {
+		Finalized *uint64 `json:"finalized"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if result.Finalized == nil {
+		return 0, errors.New("finality checker responds no finalized height")
+	}
+	return *result.Finalized, nil
+}
+
+// is block of height final, the swap events in it are confirmed when recorded
+func (scanner *ethSwapScanner) isFinalizedHeight(height uint64) bool {
+	if scanner.finalityMode == params.FinalityModeDepth {
+		return scanner.isStableHeight(height)
+	}
+	finalized := atomic.LoadUint64(scanner.finalizedHeight)
+	if finalized == 0 {
+		var err error
+		if finalized, err = scanner.getFinalizedHeight(); err != nil {
+			log.Warn("get finalized height failed", "chain", scanner.chain, "err", err)
+			return false
+		}
+		atomic.StoreUint64(scanner.finalizedHeight, finalized)
+	}
+	return height <= finalized
+}
+
+// settle the recorded swap events periodically, until the scanner is stopped
+func (scanner *ethSwapScanner) confirmLoop() {
+	log.Info("start confirm loop job", "chain", scanner.chain, "finality", scanner.finalityMode)
+	for {
+		scanner.settleSwapEvents()
+		select {
+		case <-scanner.quit:
+			return
+		case <-time.After(confirmInterval):
+		}
+	}
+}
+
+// confirm the recorded swap events, match them with the swaps on the other chains,
+// and detect the anomalies which are still not matched
+func (scanner *ethSwapScanner) settleSwapEvents() {
+	scanner.confirmSwapEvents()
+	tokenCfgs := scanner.getTokenConfigs()
+	matchMints(tokenCfgs)
+	matchRedeems(tokenCfgs)
+	detectAnomalies(tokenCfgs, scanner.anomalyDelay)
+}
+
+// confirm the unconfirmed swap events whose blocks become final,
+// and advance the confirmed height of chain below the ones which can not be confirmed
+func (scanner *ethSwapScanner) confirmSwapEvents() {
+	finalized, err := scanner.getFinalizedHeight()
+	if err != nil {
+		log.Warn("get finalized height failed", "chain", scanner.chain, "err", err)
+		return
+	}
+	atomic.StoreUint64(scanner.finalizedHeight, finalized)
+
+	confirmed := finalized
+	for _, tokenCfg := range scanner.getTokenConfigs() {
+		for swapTxType := TypeDeposit; swapTxType <= TypeRedeemed; swapTxType++ {
+			if scanner.isStopped() {
+				return
+			}
+			pending := scanner.confirmTokenSwapEvents(swapTxType, tokenCfg, finalized)
+			if pending != 0 && pending-1 < confirmed {
+				confirmed = pending - 1
+			}
+		}
+	}
+	// the blocks not scanned yet may have swap events not recorded
+	if scanner.endHeight != 0 {
+		if scanner.endHeight-1 < confirmed {
+			confirmed = scanner.endHeight - 1
+		}
+	} else {
+		syncInfo, err := dbAPI.GetSyncInfo(scanner.chain)
+		if err != nil {
+			log.Warn("get sync info failed", "chain", scanner.chain, "err", err)
+			return
+		}
+		if uint64(syncInfo.SyncedHeight) < confirmed {
+			confirmed = uint64(syncInfo.SyncedHeight)
+		}
+	}
+	if err := dbAPI.UpdateConfirmedHeight(scanner.chain, int64(confirmed)); err != nil {
+		log.Warn("update confirmed height failed", "chain", scanner.chain, "height", confirmed, "err", err)
+	}
+}
+
+// confirm the unconfirmed swap events in final blocks,
+// return the lowest block which still has unconfirmed swap event, or 0 if none
+func (scanner *ethSwapScanner) confirmTokenSwapEvents(swapTxType SwapTxType, tokenCfg *params.TokenConfig, finalized uint64) (pending uint64) {
+	iter, err := dbAPI.GetUnconfirmedSwapEvents(mongodb.TxType(swapTxType), tokenCfg, int64(finalized)+1)
+	if err != nil {
+		log.Warn("get unconfirmed swap events failed", "pairID", tokenCfg.PairID, "swapTxType", swapTxType, "err", err)
+		return 0
+	}
+	var event mongodb.SwapEvent
+	for iter.Next(&event) {
+		err = scanner.confirmSwapEvent(swapTxType, tokenCfg, &event)
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, errSwapTxNotFound):
+			// not counted as it is unconfirmed, and is updated if the tx is packed again
+			log.Warn("unconfirmed swap tx is not found", "pairID", tokenCfg.PairID, "swapTxType", swapTxType, "txHash", event.TxHash, "block", event.BlockNumber)
+			continue
+		}
+		log.Warn("confirm swap event failed", "pairID", tokenCfg.PairID, "swapTxType", swapTxType, "txHash", event.TxHash, "block", event.BlockNumber, "err", err)
+		if pending == 0 || uint64(event.BlockNumber) < pending {
+			pending = uint64(event.BlockNumber)
+		}
+	}
+	return pending
+}
+
+// confirm swap event if its tx is still in the recorded block
+func (scanner *ethSwapScanner) confirmSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, event *mongodb.SwapEvent) error {
+	receipt, err := scanner.loopGetTxReceipt(common.HexToHash(swapEventTxHash(event.TxHash)), common.Hash{})
+	if errors.Is(err, ethereum.NotFound) || errors.Is(err, errArchiveReceiptNotFound) || (err == nil && receipt == nil) {
+		return errSwapTxNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if receipt.BlockNumber == nil || receipt.BlockNumber.Cmp(big.NewInt(event.BlockNumber)) != 0 {
+		return fmt.Errorf("tx is packed in block %v, please rescan", receipt.BlockNumber)
+	}
+	return dbAPI.ConfirmSwapEvent(mongodb.TxType(swapTxType), tokenCfg, event.TxHash)
+}
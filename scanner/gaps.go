@@ -0,0 +1,158 @@
+package scanner
+
+import (
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+)
+
+// interval of auditing and healing block gaps
+const gapAuditInterval = 60 * time.Second
+
+// the partially processed block of hash is resumed from the tx index
+func (scanner *ethSwapScanner) recordGap(start, end uint64, txIndex int, blockHash, reason string) {
+	log.Warn("record block gap", "chain", scanner.chain, "start", start, "end", end, "txIndex", txIndex, "blockHash", blockHash, "reason", reason)
+	if err := dbAPI.AddBlockGap(scanner.chain, int64(start), int64(end), txIndex, blockHash, reason); err != nil {
+		log.Error("record block gap failed", "chain", scanner.chain, "start", start, "end", end, "err", err)
+	}
+}
+
+// record the unscanned blocks between the last synced height and the new start height
+func (scanner *ethSwapScanner) checkStartGap(start uint64) {
+	if scanner.dryRun {
+		return
+	}
+	syncInfo, err := dbAPI.GetSyncInfo(scanner.chain)
+	if err != nil || syncInfo.SyncedHeight <= 0 {
+		return
+	}
+	synced := uint64(syncInfo.SyncedHeight)
+	if start > synced+1 {
+		scanner.recordGap(synced+1, start, 0, "", "unscanned since last run")
+	}
+}
+
+// periodically rescan the gaps below the synced height
+func (scanner *ethSwapScanner) auditGaps() {
+	ticker := time.NewTicker(gapAuditInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-scanner.quit:
+			return
+		case <-ticker.C:
+			scanner.healGaps()
+		}
+	}
+}
+
+func (scanner *ethSwapScanner) healGaps() {
+	syncInfo, err := dbAPI.GetSyncInfo(scanner.chain)
+	if err != nil {
+		return
+	}
+	gaps, err := dbAPI.GetBlockGaps(scanner.chain)
+	if err != nil {
+		log.Warn("get block gaps failed", "chain", scanner.chain, "err", err)
+		return
+	}
+	for _, gap := range gaps {
+		if gap.End > syncInfo.SyncedHeight+1 || gap.Start >= gap.End {
+			continue
+		}
+		if scanner.isStopped() {
+			return
+		}
+		log.Info("heal block gap", "chain", scanner.chain, "start", gap.Start, "end", gap.End, "txIndex", gap.TxIndex, "reason", gap.Reason)
+		// failed blocks are recorded as new gaps when rescan
+		if err = dbAPI.RemoveBlockGap(gap.Key); err != nil {
+			log.Warn("remove block gap failed", "chain", scanner.chain, "key", gap.Key, "err", err)
+			continue
+		}
+		start, end := uint64(gap.Start), uint64(gap.End)
+		healer := scanner.cloneForTokens(nil, end)
+		if gap.TxIndex > 0 {
+			// resume the partially processed block
+			worker := newBlockWorker(0, healer.processBlockTimeout)
+			next, blockHash, err := healer.scanBlockFrom(worker, start, gap.TxIndex, gap.BlockHash, false)
+			worker.timer.Stop()
+			if err != nil {
+				healer.recordGap(start, start+1, next, blockHash, err.Error())
+			}
+			start++
+		}
+		if start < end {
+			// the unfinished heights are recorded as new gaps if stopped
+			healer.doScanRangeJob(start, end)
+		}
+		if healer.isStopped() {
+			return
+		}
+	}
+}
+
+// the failed btc block is rescanned as a whole, so no tx index is recorded
+func (scanner *btcSwapScanner) recordGap(start, end uint64, reason string) {
+	log.Warn("record block gap", "chain", scanner.chain, "start", start, "end", end, "reason", reason)
+	if err := dbAPI.AddBlockGap(scanner.chain, int64(start), int64(end), 0, "", reason); err != nil {
+		log.Error("record block gap failed", "chain", scanner.chain, "start", start, "end", end, "err", err)
+	}
+}
+
+// record the unscanned blocks between the last synced height and the new start height of btc chain
+func (scanner *btcSwapScanner) checkStartGap(start uint64) {
+	syncInfo, err := dbAPI.GetSyncInfo(scanner.chain)
+	if err != nil || syncInfo.SyncedHeight <= 0 {
+		return
+	}
+	synced := uint64(syncInfo.SyncedHeight)
+	if start > synced+1 {
+		scanner.recordGap(synced+1, start, "unscanned since last run")
+	}
+}
+
+// periodically rescan the gaps below the synced height of btc chain
+func (scanner *btcSwapScanner) auditGaps() {
+	ticker := time.NewTicker(gapAuditInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-scanner.quit:
+			return
+		case <-ticker.C:
+			scanner.healGaps()
+		}
+	}
+}
+
+// the btc block is rescanned as a whole, as the recorded swap events are not changed
+func (scanner *btcSwapScanner) healGaps() {
+	syncInfo, err := dbAPI.GetSyncInfo(scanner.chain)
+	if err != nil {
+		return
+	}
+	gaps, err := dbAPI.GetBlockGaps(scanner.chain)
+	if err != nil {
+		log.Warn("get block gaps failed", "chain", scanner.chain, "err", err)
+		return
+	}
+	for _, gap := range gaps {
+		if gap.End > syncInfo.SyncedHeight+1 || gap.Start >= gap.End {
+			continue
+		}
+		log.Info("heal block gap", "chain", scanner.chain, "start", gap.Start, "end", gap.End, "reason", gap.Reason)
+		if err = dbAPI.RemoveBlockGap(gap.Key); err != nil {
+			log.Warn("remove block gap failed", "chain", scanner.chain, "key", gap.Key, "err", err)
+			continue
+		}
+		for height := uint64(gap.Start); height < uint64(gap.End); height++ {
+			if scanner.isStopped() {
+				scanner.recordGap(height, uint64(gap.End), "unfinished when stop")
+				return
+			}
+			if err = scanner.scanBlock(height); err != nil {
+				scanner.recordGap(height, height+1, err.Error())
+			}
+		}
+	}
+}
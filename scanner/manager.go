@@ -0,0 +1,118 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// timeout of stopping scanner of removed or changed chain when reload config
+const reloadStopTimeout = 60 * time.Second
+
+var (
+	runningScanners     = make(map[string]chainScanner) // chain name -> scanner
+	runningScannersLock sync.Mutex
+)
+
+func getRunningScanner(chain string) chainScanner {
+	runningScannersLock.Lock()
+	defer runningScannersLock.Unlock()
+	return runningScanners[chain]
+}
+
+// start scanner of chain, resume from the synced height if specified
+func startChainScanner(ctx context.Context, chainCfg *params.ChainConfig, resume bool) error {
+	scanner := newChainScanner(ctx, chainCfg)
+	if err := scanner.initClient(); err != nil {
+		return err
+	}
+	if resume {
+		syncInfo, err := dbAPI.GetSyncInfo(chainCfg.Name)
+		if err == nil && syncInfo.SyncedHeight > 0 {
+			log.Info("resume scanner from synced height", "chain", chainCfg.Name, "height", syncInfo.SyncedHeight)
+			scanner.resumeFrom(syncInfo.SyncedHeight)
+		}
+	}
+	runningScannersLock.Lock()
+	runningScanners[chainCfg.Name] = scanner
+	runningScannersLock.Unlock()
+	scanner.goJob(scanner.run)
+	return nil
+}
+
+func stopChainScanner(chain string, timeout time.Duration) {
+	runningScannersLock.Lock()
+	scanner, exist := runningScanners[chain]
+	delete(runningScanners, chain)
+	runningScannersLock.Unlock()
+	if exist {
+		scanner.stop(timeout)
+	}
+}
+
+// stop all running scanners concurrently, wait at most timeout
+func stopAllChainScanners(timeout time.Duration) {
+	runningScannersLock.Lock()
+	scanners := runningScanners
+	runningScanners = make(map[string]chainScanner)
+	runningScannersLock.Unlock()
+
+	wg := new(sync.WaitGroup)
+	for _, scanner := range scanners {
+		wg.Add(1)
+		go func(scanner chainScanner) {
+			defer wg.Done()
+			scanner.stop(timeout)
+		}(scanner)
+	}
+	wg.Wait()
+}
+
+// apply the difference of reloaded config
+func onConfigReload(ctx context.Context, oldConfig, newConfig *params.ScanConfig) {
+	diff := params.DiffConfig(oldConfig, newConfig)
+	if diff.IsEmpty() {
+		return
+	}
+	log.Info("apply reloaded config",
+		"addedChains", len(diff.AddedChains),
+		"removedChains", len(diff.RemovedChains),
+		"changedChains", len(diff.ChangedChains),
+		"addedTokens", len(diff.AddedTokens),
+		"removedTokens", len(diff.RemovedTokens),
+	)
+
+	mongodb.InitTokenCollections(diff.AddedTokens)
+
+	for _, chainCfg := range diff.RemovedChains {
+		stopChainScanner(chainCfg.Name, reloadStopTimeout)
+	}
+	for _, chainCfg := range diff.ChangedChains {
+		stopChainScanner(chainCfg.Name, reloadStopTimeout)
+		if err := startChainScanner(ctx, chainCfg, true); err != nil {
+			log.Error("restart chain scanner failed", "chain", chainCfg.Name, "err", err)
+		}
+	}
+	for _, chainCfg := range diff.AddedChains {
+		if err := startChainScanner(ctx, chainCfg, false); err != nil {
+			log.Error("start chain scanner failed", "chain", chainCfg.Name, "err", err)
+		}
+	}
+
+	for _, tokenCfg := range diff.AddedTokens {
+		if !tokenCfg.NeedBackfill() {
+			continue
+		}
+		scanner := getRunningScanner(tokenCfg.Chain)
+		if scanner == nil {
+			log.Warn("backfill token without running scanner", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID)
+			continue
+		}
+		scanner.backfillAddedToken(tokenCfg)
+	}
+}
@@ -0,0 +1,604 @@
+package scanner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// memorySyncAPI in-memory implementation of mongodb.SyncAPI, used by the simulation tests
+type memorySyncAPI struct {
+	lock           sync.Mutex
+	syncInfos      map[string]*mongodb.SyncInfo
+	tokenSyncInfos map[string]*mongodb.TokenSyncInfo
+	blockGaps      map[string]*mongodb.BlockGap
+	tokenMetas     map[string]*mongodb.TokenMeta
+	swapEvents     map[string]map[string]*mongodb.SwapEvent // table -> tx hash -> swap event
+	anomalies      map[string]*mongodb.Anomaly
+	addressTxs     map[string]*mongodb.AddressTx
+}
+
+// newMemorySyncAPI new in-memory SyncAPI
+func newMemorySyncAPI() *memorySyncAPI {
+	return &memorySyncAPI{
+		syncInfos:      make(map[string]*mongodb.SyncInfo),
+		tokenSyncInfos: make(map[string]*mongodb.TokenSyncInfo),
+		blockGaps:      make(map[string]*mongodb.BlockGap),
+		tokenMetas:     make(map[string]*mongodb.TokenMeta),
+		swapEvents:     make(map[string]map[string]*mongodb.SwapEvent),
+		anomalies:      make(map[string]*mongodb.Anomaly),
+		addressTxs:     make(map[string]*mongodb.AddressTx),
+	}
+}
+
+type memorySwapEventIter struct {
+	events []*mongodb.SwapEvent
+}
+
+func (iter *memorySwapEventIter) Next(dst *mongodb.SwapEvent) bool {
+	if len(iter.events) == 0 {
+		return false
+	}
+	*dst = *iter.events[0]
+	iter.events = iter.events[1:]
+	return true
+}
+
+func (iter *memorySwapEventIter) Err() error {
+	return nil
+}
+
+func (iter *memorySwapEventIter) Close() error {
+	return nil
+}
+
+// memoryTableName name of the swap event table of token, it is not validated
+func memoryTableName(txtype mongodb.TxType, tokenCfg *params.TokenConfig) string {
+	return fmt.Sprintf("%v_%v", txtype, tokenCfg.PairID)
+}
+
+func memoryTable(txtype mongodb.TxType, tokenCfg *params.TokenConfig) (string, error) {
+	switch txtype {
+	case mongodb.TypeDeposit, mongodb.TypeMint, mongodb.TypeBurn, mongodb.TypeRedeemed:
+		return memoryTableName(txtype, tokenCfg), nil
+	default:
+		return "", fmt.Errorf("invalid txtype: %v", txtype)
+	}
+}
+
+func memoryDBError(err error, tag ...string) error {
+	return errors.Wrap(err, fmt.Sprintf("[memory db] %s", tag))
+}
+
+// GetSwapEvents get all swap events of type sorted by block number and tx hash
+func (m *memorySyncAPI) GetSwapEvents(txtype mongodb.TxType, tokenCfg *params.TokenConfig) []*mongodb.SwapEvent {
+	return m.filterSwapEvents(txtype, tokenCfg, func(*mongodb.SwapEvent) bool { return true })
+}
+
+func (m *memorySyncAPI) filterSwapEvents(txtype mongodb.TxType, tokenCfg *params.TokenConfig, filter func(*mongodb.SwapEvent) bool) []*mongodb.SwapEvent {
+	table, err := memoryTable(txtype, tokenCfg)
+	if err != nil {
+		return nil
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var result []*mongodb.SwapEvent
+	for _, event := range m.swapEvents[table] {
+		if filter(event) {
+			cpy := *event
+			result = append(result, &cpy)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].BlockNumber != result[j].BlockNumber {
+			return result[i].BlockNumber < result[j].BlockNumber
+		}
+		return result[i].TxHash < result[j].TxHash
+	})
+	return result
+}
+
+// filter swap events of the chain of token config
+func (m *memorySyncAPI) filterChainSwapEvents(txtype mongodb.TxType, tokenCfg *params.TokenConfig, filter func(*mongodb.SwapEvent) bool) []*mongodb.SwapEvent {
+	return m.filterSwapEvents(txtype, tokenCfg, func(event *mongodb.SwapEvent) bool {
+		return event.Chain == tokenCfg.Chain && filter(event)
+	})
+}
+
+func (m *memorySyncAPI) getSwapEvent(txtype mongodb.TxType, tokenCfg *params.TokenConfig, txhash string) (*mongodb.SwapEvent, error) {
+	table, err := memoryTable(txtype, tokenCfg)
+	if err != nil {
+		return nil, memoryDBError(err, "getSwapEvent", "selectCollection")
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	event, exist := m.swapEvents[table][strings.ToLower(txhash)]
+	if !exist {
+		return nil, memoryDBError(mgo.ErrNotFound, "getSwapEvent")
+	}
+	cpy := *event
+	return &cpy, nil
+}
+
+func (m *memorySyncAPI) addSwapEvent(txtype mongodb.TxType, tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	table, err := memoryTable(txtype, tokenCfg)
+	if err != nil {
+		return memoryDBError(err, "addSwapEvent", "selectCollection")
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	events, exist := m.swapEvents[table]
+	if !exist {
+		events = make(map[string]*mongodb.SwapEvent)
+		m.swapEvents[table] = events
+	}
+	if _, exist = events[data.TxHash]; exist {
+		return mongodb.ErrItemIsDup
+	}
+	cpy := *data
+	events[data.TxHash] = &cpy
+	return nil
+}
+
+func (m *memorySyncAPI) updateSwapEvent(txtype mongodb.TxType, tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	table, err := memoryTable(txtype, tokenCfg)
+	if err != nil {
+		return memoryDBError(err, "updateSwapEvent", "selectCollection")
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exist := m.swapEvents[table][data.TxHash]; !exist {
+		return memoryDBError(mgo.ErrNotFound, "updateSwapEvent")
+	}
+	cpy := *data
+	m.swapEvents[table][data.TxHash] = &cpy
+	return nil
+}
+
+func (m *memorySyncAPI) GetSyncInfo(chain string) (*mongodb.SyncInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	info, exist := m.syncInfos[chain]
+	if !exist {
+		return nil, memoryDBError(mgo.ErrNotFound, "GetSyncInfo")
+	}
+	cpy := *info
+	return &cpy, nil
+}
+
+func (m *memorySyncAPI) GetTokenSyncInfo(tokenCfg *params.TokenConfig) (*mongodb.TokenSyncInfo, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	info, exist := m.tokenSyncInfos[tokenCfg.Key()]
+	if !exist {
+		return nil, memoryDBError(mgo.ErrNotFound, "GetTokenSyncInfo")
+	}
+	cpy := *info
+	return &cpy, nil
+}
+
+func (m *memorySyncAPI) GetBlockGaps(chain string) ([]*mongodb.BlockGap, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var result []*mongodb.BlockGap
+	for _, gap := range m.blockGaps {
+		if gap.Chain == chain {
+			cpy := *gap
+			result = append(result, &cpy)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start < result[j].Start })
+	return result, nil
+}
+
+func (m *memorySyncAPI) GetTokenMeta(tokenCfg *params.TokenConfig) (*mongodb.TokenMeta, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	meta, exist := m.tokenMetas[tokenCfg.Key()]
+	if !exist {
+		return nil, memoryDBError(mgo.ErrNotFound, "GetTokenMeta")
+	}
+	cpy := *meta
+	return &cpy, nil
+}
+
+func (m *memorySyncAPI) GetTokenMetas(chain string) ([]*mongodb.TokenMeta, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var result []*mongodb.TokenMeta
+	for _, meta := range m.tokenMetas {
+		if meta.Chain == chain {
+			cpy := *meta
+			result = append(result, &cpy)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].PairID < result[j].PairID })
+	return result, nil
+}
+
+func (m *memorySyncAPI) getOrInitSyncInfo(chain string) *mongodb.SyncInfo {
+	info, exist := m.syncInfos[chain]
+	if !exist {
+		info = &mongodb.SyncInfo{Chain: chain}
+		m.syncInfos[chain] = info
+	}
+	return info
+}
+
+func (m *memorySyncAPI) SetStartHeight(chain string, startHeight int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.getOrInitSyncInfo(chain).StartHeight = startHeight
+	return nil
+}
+
+func (m *memorySyncAPI) UpdateSyncedHeight(chain string, syncedHeight int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.getOrInitSyncInfo(chain).SyncedHeight = syncedHeight
+	return nil
+}
+
+func (m *memorySyncAPI) UpdateConfirmedHeight(chain string, confirmedHeight int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.getOrInitSyncInfo(chain).ConfirmedHeight = confirmedHeight
+	return nil
+}
+
+func (m *memorySyncAPI) MarkDirtyHeight(chain string, height int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	info := m.getOrInitSyncInfo(chain)
+	if info.DirtyHeight == 0 || height < info.DirtyHeight {
+		info.DirtyHeight = height
+	}
+	return nil
+}
+
+func (m *memorySyncAPI) SetTokenBackfillRange(tokenCfg *params.TokenConfig, startHeight, endHeight int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.tokenSyncInfos[tokenCfg.Key()] = &mongodb.TokenSyncInfo{
+		Key:          tokenCfg.Key(),
+		Chain:        tokenCfg.Chain,
+		PairID:       tokenCfg.PairID,
+		StartHeight:  startHeight,
+		EndHeight:    endHeight,
+		SyncedHeight: startHeight,
+	}
+	return nil
+}
+
+func (m *memorySyncAPI) UpdateTokenSyncedHeight(tokenCfg *params.TokenConfig, syncedHeight int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	info, exist := m.tokenSyncInfos[tokenCfg.Key()]
+	if !exist {
+		return memoryDBError(mgo.ErrNotFound, "UpdateTokenSyncedHeight")
+	}
+	info.SyncedHeight = syncedHeight
+	return nil
+}
+
+func (m *memorySyncAPI) AddBlockGap(chain string, start, end int64, txIndex int, blockHash, reason string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	key := mongodb.BlockGapKey(chain, start)
+	m.blockGaps[key] = &mongodb.BlockGap{
+		Key:       key,
+		Chain:     chain,
+		Start:     start,
+		End:       end,
+		TxIndex:   txIndex,
+		BlockHash: blockHash,
+		Reason:    reason,
+		Timestamp: time.Now().Unix(),
+	}
+	return nil
+}
+
+func (m *memorySyncAPI) RemoveBlockGap(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.blockGaps, key)
+	return nil
+}
+
+func (m *memorySyncAPI) SetTokenMeta(meta *mongodb.TokenMeta) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	cpy := *meta
+	m.tokenMetas[meta.Key] = &cpy
+	return nil
+}
+
+func (m *memorySyncAPI) getByBlockRange(txtype mongodb.TxType, tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	events := m.filterChainSwapEvents(txtype, tokenCfg, func(event *mongodb.SwapEvent) bool {
+		return event.BlockNumber >= start && event.BlockNumber < end
+	})
+	return &memorySwapEventIter{events: events}, nil
+}
+
+func (m *memorySyncAPI) getByTimeRange(txtype mongodb.TxType, tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	events := m.filterChainSwapEvents(txtype, tokenCfg, func(event *mongodb.SwapEvent) bool {
+		return event.BlockTime >= start && event.BlockTime < end
+	})
+	return &memorySwapEventIter{events: events}, nil
+}
+
+func (m *memorySyncAPI) getByUserTimeRange(txtype mongodb.TxType, tokenCfg *params.TokenConfig, user string, start, end int64) (mongodb.SwapEventIter, error) {
+	user = strings.ToLower(user)
+	events := m.filterChainSwapEvents(txtype, tokenCfg, func(event *mongodb.SwapEvent) bool {
+		return event.User == user && event.BlockTime >= start && event.BlockTime < end
+	})
+	return &memorySwapEventIter{events: events}, nil
+}
+
+func (m *memorySyncAPI) GetDeposit(tokenCfg *params.TokenConfig, txhash string) (*mongodb.SwapEvent, error) {
+	return m.getSwapEvent(mongodb.TypeDeposit, tokenCfg, txhash)
+}
+
+func (m *memorySyncAPI) GetDepositsByBlockRange(tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByBlockRange(mongodb.TypeDeposit, tokenCfg, start, end)
+}
+
+func (m *memorySyncAPI) GetDepositsByTimeRange(tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByTimeRange(mongodb.TypeDeposit, tokenCfg, start, end)
+}
+
+func (m *memorySyncAPI) GetDepositByUserTimeRange(tokenCfg *params.TokenConfig, user string, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByUserTimeRange(mongodb.TypeDeposit, tokenCfg, user, start, end)
+}
+
+func (m *memorySyncAPI) GetMint(tokenCfg *params.TokenConfig, txhash string) (*mongodb.SwapEvent, error) {
+	return m.getSwapEvent(mongodb.TypeMint, tokenCfg, txhash)
+}
+
+func (m *memorySyncAPI) GetMintByBlockRange(tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByBlockRange(mongodb.TypeMint, tokenCfg, start, end)
+}
+
+func (m *memorySyncAPI) GetMintByTimeRange(tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByTimeRange(mongodb.TypeMint, tokenCfg, start, end)
+}
+
+func (m *memorySyncAPI) GetMintByUserTimeRange(tokenCfg *params.TokenConfig, user string, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByUserTimeRange(mongodb.TypeMint, tokenCfg, user, start, end)
+}
+
+func (m *memorySyncAPI) GetBurn(tokenCfg *params.TokenConfig, txhash string) (*mongodb.SwapEvent, error) {
+	return m.getSwapEvent(mongodb.TypeBurn, tokenCfg, txhash)
+}
+
+func (m *memorySyncAPI) GetBurnByBlockRange(tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByBlockRange(mongodb.TypeBurn, tokenCfg, start, end)
+}
+
+func (m *memorySyncAPI) GetBurnByTimeRange(tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByTimeRange(mongodb.TypeBurn, tokenCfg, start, end)
+}
+
+func (m *memorySyncAPI) GetBurnByUserTimeRange(tokenCfg *params.TokenConfig, user string, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByUserTimeRange(mongodb.TypeBurn, tokenCfg, user, start, end)
+}
+
+func (m *memorySyncAPI) GetRedeemed(tokenCfg *params.TokenConfig, txhash string) (*mongodb.SwapEvent, error) {
+	return m.getSwapEvent(mongodb.TypeRedeemed, tokenCfg, txhash)
+}
+
+func (m *memorySyncAPI) GetRedeemedByBlockRange(tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByBlockRange(mongodb.TypeRedeemed, tokenCfg, start, end)
+}
+
+func (m *memorySyncAPI) GetRedeemedByTimeRange(tokenCfg *params.TokenConfig, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByTimeRange(mongodb.TypeRedeemed, tokenCfg, start, end)
+}
+
+func (m *memorySyncAPI) GetRedeemedByUserTimeRange(tokenCfg *params.TokenConfig, user string, start, end int64) (mongodb.SwapEventIter, error) {
+	return m.getByUserTimeRange(mongodb.TypeRedeemed, tokenCfg, user, start, end)
+}
+
+func (m *memorySyncAPI) AddDeposit(tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	return m.addSwapEvent(mongodb.TypeDeposit, tokenCfg, data)
+}
+
+func (m *memorySyncAPI) AddMint(tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	return m.addSwapEvent(mongodb.TypeMint, tokenCfg, data)
+}
+
+func (m *memorySyncAPI) AddBurn(tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	return m.addSwapEvent(mongodb.TypeBurn, tokenCfg, data)
+}
+
+func (m *memorySyncAPI) AddRedeemed(tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	return m.addSwapEvent(mongodb.TypeRedeemed, tokenCfg, data)
+}
+
+func (m *memorySyncAPI) UpdateDeposit(tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	return m.updateSwapEvent(mongodb.TypeDeposit, tokenCfg, data)
+}
+
+func (m *memorySyncAPI) UpdateMint(tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	return m.updateSwapEvent(mongodb.TypeMint, tokenCfg, data)
+}
+
+func (m *memorySyncAPI) UpdateBurn(tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	return m.updateSwapEvent(mongodb.TypeBurn, tokenCfg, data)
+}
+
+func (m *memorySyncAPI) UpdateRedeemed(tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) error {
+	return m.updateSwapEvent(mongodb.TypeRedeemed, tokenCfg, data)
+}
+
+func (m *memorySyncAPI) GetUnconfirmedSwapEvents(txtype mongodb.TxType, tokenCfg *params.TokenConfig, end int64) (mongodb.SwapEventIter, error) {
+	events := m.filterChainSwapEvents(txtype, tokenCfg, func(event *mongodb.SwapEvent) bool {
+		return event.Unconfirmed && event.BlockNumber < end
+	})
+	return &memorySwapEventIter{events: events}, nil
+}
+
+func (m *memorySyncAPI) ConfirmSwapEvent(txtype mongodb.TxType, tokenCfg *params.TokenConfig, txhash string) error {
+	table, err := memoryTable(txtype, tokenCfg)
+	if err != nil {
+		return memoryDBError(err, "ConfirmSwapEvent", "selectCollection")
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	event, exist := m.swapEvents[table][strings.ToLower(txhash)]
+	if !exist || event.Chain != tokenCfg.Chain {
+		return memoryDBError(mgo.ErrNotFound, "ConfirmSwapEvent")
+	}
+	event.Unconfirmed = false
+	return nil
+}
+
+func (m *memorySyncAPI) GetUnmatchedMints(tokenCfg *params.TokenConfig) (mongodb.SwapEventIter, error) {
+	events := m.filterChainSwapEvents(mongodb.TypeMint, tokenCfg, func(event *mongodb.SwapEvent) bool {
+		return !event.Matched && event.RefTxHash != ""
+	})
+	return &memorySwapEventIter{events: events}, nil
+}
+
+func (m *memorySyncAPI) MatchMint(tokenCfg *params.TokenConfig, txhash string, bridgeFee float64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	event, exist := m.swapEvents[memoryTableName(mongodb.TypeMint, tokenCfg)][strings.ToLower(txhash)]
+	if !exist || event.Chain != tokenCfg.Chain {
+		return memoryDBError(mgo.ErrNotFound, "MatchMint")
+	}
+	event.Matched = true
+	event.BridgeFee = bridgeFee
+	return nil
+}
+
+func (m *memorySyncAPI) MatchDeposit(tokenCfg *params.TokenConfig, txhash string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	event, exist := m.swapEvents[memoryTableName(mongodb.TypeDeposit, tokenCfg)][strings.ToLower(txhash)]
+	if !exist {
+		return memoryDBError(mgo.ErrNotFound, "MatchDeposit")
+	}
+	event.Matched = true
+	return nil
+}
+
+func (m *memorySyncAPI) GetUnmatchedRedeems(tokenCfg *params.TokenConfig) (mongodb.SwapEventIter, error) {
+	events := m.filterChainSwapEvents(mongodb.TypeRedeemed, tokenCfg, func(event *mongodb.SwapEvent) bool {
+		return !event.Matched
+	})
+	return &memorySwapEventIter{events: events}, nil
+}
+
+func (m *memorySyncAPI) GetUnmatchedBurnsByBind(tokenCfg *params.TokenConfig, bind string, end int64) (mongodb.SwapEventIter, error) {
+	events := m.filterSwapEvents(mongodb.TypeBurn, tokenCfg, func(event *mongodb.SwapEvent) bool {
+		return !event.Matched && strings.EqualFold(event.Bind, bind) && event.BlockTime <= end
+	})
+	return &memorySwapEventIter{events: events}, nil
+}
+
+func (m *memorySyncAPI) GetSwapEventsByTxHash(txtype mongodb.TxType, tokenCfg *params.TokenConfig, txhash string) (mongodb.SwapEventIter, error) {
+	txhash = strings.ToLower(txhash)
+	events := m.filterSwapEvents(txtype, tokenCfg, func(event *mongodb.SwapEvent) bool {
+		return swapEventTxHash(event.TxHash) == txhash
+	})
+	return &memorySwapEventIter{events: events}, nil
+}
+
+func (m *memorySyncAPI) MatchBurn(tokenCfg *params.TokenConfig, txhash string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	event, exist := m.swapEvents[memoryTableName(mongodb.TypeBurn, tokenCfg)][strings.ToLower(txhash)]
+	if !exist || event.Matched {
+		return memoryDBError(mgo.ErrNotFound, "MatchBurn")
+	}
+	event.Matched = true
+	return nil
+}
+
+func (m *memorySyncAPI) MatchRedeemed(tokenCfg *params.TokenConfig, txhash, burnTxHash string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	event, exist := m.swapEvents[memoryTableName(mongodb.TypeRedeemed, tokenCfg)][strings.ToLower(txhash)]
+	if !exist || event.Chain != tokenCfg.Chain {
+		return memoryDBError(mgo.ErrNotFound, "MatchRedeemed")
+	}
+	event.Matched = true
+	event.RefTxHash = strings.ToLower(burnTxHash)
+	return nil
+}
+
+// GetAnomalies get all anomalies sorted by key
+func (m *memorySyncAPI) GetAnomalies() []*mongodb.Anomaly {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	result := make([]*mongodb.Anomaly, 0, len(m.anomalies))
+	for _, anomaly := range m.anomalies {
+		cpy := *anomaly
+		result = append(result, &cpy)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+func (m *memorySyncAPI) AddAnomaly(anomaly *mongodb.Anomaly) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, exist := m.anomalies[anomaly.Key]; exist {
+		return mongodb.ErrItemIsDup
+	}
+	cpy := *anomaly
+	m.anomalies[anomaly.Key] = &cpy
+	return nil
+}
+
+func (m *memorySyncAPI) GetUnresolvedAnomalies(pairID string) ([]*mongodb.Anomaly, error) {
+	var result []*mongodb.Anomaly
+	for _, anomaly := range m.GetAnomalies() {
+		if anomaly.PairID == pairID && !anomaly.Resolved {
+			result = append(result, anomaly)
+		}
+	}
+	return result, nil
+}
+
+func (m *memorySyncAPI) ResolveAnomaly(key string, resolveTime int64) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	anomaly, exist := m.anomalies[key]
+	if !exist {
+		return memoryDBError(mgo.ErrNotFound, "ResolveAnomaly")
+	}
+	anomaly.Resolved = true
+	anomaly.ResolveTime = resolveTime
+	return nil
+}
+
+// GetAddressTxs get all watched txs sorted by key
+func (m *memorySyncAPI) GetAddressTxs() []*mongodb.AddressTx {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	result := make([]*mongodb.AddressTx, 0, len(m.addressTxs))
+	for _, addressTx := range m.addressTxs {
+		cpy := *addressTx
+		result = append(result, &cpy)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+func (m *memorySyncAPI) SetAddressTx(addressTx *mongodb.AddressTx) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	cpy := *addressTx
+	m.addressTxs[addressTx.Key] = &cpy
+	return nil
+}
@@ -0,0 +1,134 @@
+package scanner
+
+import (
+	"fmt"
+
+	"github.com/anyswap/CrossChain-Bridge/cmd/utils"
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/urfave/cli/v2"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+var (
+	chainFlag = &cli.StringFlag{
+		Name:  "chain",
+		Usage: "chain name in config",
+	}
+
+	fromHeightFlag = &cli.Uint64Flag{
+		Name:  "from",
+		Usage: "from height (start inclusive)",
+	}
+
+	toHeightFlag = &cli.Uint64Flag{
+		Name:  "to",
+		Usage: "to height (end exclusive)",
+	}
+
+	pairFlag = &cli.StringSliceFlag{
+		Name:  "pair",
+		Usage: "pairIDs to rescan, default is all pairs of the chain",
+	}
+
+	jobsFlag = &cli.Uint64Flag{
+		Name:  "jobs",
+		Usage: "number of jobs, default is 'JobCount' of the chain",
+	}
+
+	dryRunFlag = &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "only report the differences, do not write database",
+	}
+
+	// RescanCommand rescan swaps in block range
+	RescanCommand = &cli.Command{
+		Action:    rescan,
+		Name:      "rescan",
+		Usage:     "rescan cross chain swaps in block range",
+		ArgsUsage: " ",
+		Description: `
+rescan cross chain swaps in block range [from, to) of the specified pairs,
+it does not change the synced height, so it can run with the 'start' process.
+`,
+		Flags: []cli.Flag{
+			utils.ConfigFileFlag,
+			chainFlag,
+			fromHeightFlag,
+			toHeightFlag,
+			pairFlag,
+			jobsFlag,
+			dryRunFlag,
+			archiveFlag,
+		},
+	}
+)
+
+func rescan(ctx *cli.Context) error {
+	utils.SetLogger(ctx)
+	cfg := params.LoadConfig(utils.GetConfigFilePath(ctx))
+
+	chainCfg, err := getEVMChainConfig(cfg, ctx.String(chainFlag.Name))
+	if err != nil {
+		return err
+	}
+	from := ctx.Uint64(fromHeightFlag.Name)
+	to := ctx.Uint64(toHeightFlag.Name)
+	if from >= to {
+		return fmt.Errorf("wrong rescan range [%v, %v)", from, to)
+	}
+	tokenCfgs, err := filterTokenConfigs(cfg.GetTokenConfigs(chainCfg.Name), ctx.StringSlice(pairFlag.Name))
+	if err != nil {
+		return err
+	}
+	if err = initBlockCache(cfg.BlockCache); err != nil {
+		return err
+	}
+
+	mongodb.MongoServerInit(ctx.Context, cfg, cfg.MongoDB.DBURLs, cfg.MongoDB.DBName, cfg.MongoDB.UserName, cfg.MongoDB.Password)
+	dbAPI = mongodb.NewSyncAPI()
+
+	scanner := newEthSwapScanner(ctx.Context, chainCfg)
+	if archiveFile := ctx.String(archiveFlag.Name); archiveFile != "" {
+		scanner.archiveFile = archiveFile
+	}
+	if jobs := ctx.Uint64(jobsFlag.Name); jobs > 0 {
+		scanner.jobCount = jobs
+	}
+	if err = scanner.initClient(); err != nil {
+		return err
+	}
+	rescanner := scanner.cloneForTokens(tokenCfgs, to)
+	rescanner.rescanStats = &rescanStats{}
+	rescanner.dryRun = ctx.Bool(dryRunFlag.Name)
+	rescanner.doScanRangeJob(from, to)
+
+	stats := rescanner.rescanStats
+	log.Info("rescan finished", "chain", chainCfg.Name, "from", from, "to", to, "dryRun", rescanner.dryRun,
+		"new", stats.newCount, "existing", stats.existingCount, "changed", stats.changedCount, "failed", stats.failedCount)
+	fmt.Printf("new: %v\nexisting: %v\nchanged: %v\nfailed: %v\n", stats.newCount, stats.existingCount, stats.changedCount, stats.failedCount)
+	return nil
+}
+
+func filterTokenConfigs(tokenCfgs []*params.TokenConfig, pairIDs []string) (result []*params.TokenConfig, err error) {
+	if len(pairIDs) == 0 {
+		result = tokenCfgs
+	}
+	for _, pairID := range pairIDs {
+		found := false
+		for _, tokenCfg := range tokenCfgs {
+			if tokenCfg.PairID == pairID {
+				result = append(result, tokenCfg)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("pair config not found: %v", pairID)
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no token config to scan")
+	}
+	return result, nil
+}
@@ -0,0 +1,181 @@
+package scanner
+
+import (
+	"strings"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/anyswap/CrossChain-Bridge/tokens"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// router events
+//
+//	LogAnySwapOut(address indexed token, address indexed from, address indexed to, uint amount, uint fromChainID, uint toChainID)
+//	LogAnySwapIn(bytes32 indexed txhash, address indexed token, address indexed to, uint amount, uint fromChainID, uint toChainID)
+//	LogAnySwapTradeTokensForTokens(address[] path, address indexed from, address indexed to, uint amountIn, uint amountOutMin, uint fromChainID, uint toChainID)
+//	LogAnySwapTradeTokensForNative(address[] path, address indexed from, address indexed to, uint amountIn, uint amountOutMin, uint fromChainID, uint toChainID)
+const routerEventsABI = `[
+{"anonymous":false,"type":"event","name":"LogAnySwapOut","inputs":[
+	{"indexed":true,"name":"token","type":"address"},
+	{"indexed":true,"name":"from","type":"address"},
+	{"indexed":true,"name":"to","type":"address"},
+	{"indexed":false,"name":"amount","type":"uint256"},
+	{"indexed":false,"name":"fromChainID","type":"uint256"},
+	{"indexed":false,"name":"toChainID","type":"uint256"}]},
+{"anonymous":false,"type":"event","name":"LogAnySwapIn","inputs":[
+	{"indexed":true,"name":"txhash","type":"bytes32"},
+	{"indexed":true,"name":"token","type":"address"},
+	{"indexed":true,"name":"to","type":"address"},
+	{"indexed":false,"name":"amount","type":"uint256"},
+	{"indexed":false,"name":"fromChainID","type":"uint256"},
+	{"indexed":false,"name":"toChainID","type":"uint256"}]},
+{"anonymous":false,"type":"event","name":"LogAnySwapTradeTokensForTokens","inputs":[
+	{"indexed":false,"name":"path","type":"address[]"},
+	{"indexed":true,"name":"from","type":"address"},
+	{"indexed":true,"name":"to","type":"address"},
+	{"indexed":false,"name":"amountIn","type":"uint256"},
+	{"indexed":false,"name":"amountOutMin","type":"uint256"},
+	{"indexed":false,"name":"fromChainID","type":"uint256"},
+	{"indexed":false,"name":"toChainID","type":"uint256"}]},
+{"anonymous":false,"type":"event","name":"LogAnySwapTradeTokensForNative","inputs":[
+	{"indexed":false,"name":"path","type":"address[]"},
+	{"indexed":true,"name":"from","type":"address"},
+	{"indexed":true,"name":"to","type":"address"},
+	{"indexed":false,"name":"amountIn","type":"uint256"},
+	{"indexed":false,"name":"amountOutMin","type":"uint256"},
+	{"indexed":false,"name":"fromChainID","type":"uint256"},
+	{"indexed":false,"name":"toChainID","type":"uint256"}]}
+]`
+
+var routerABI = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(routerEventsABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+var (
+	// 2. Burn (or 0. Deposit of underlying) log
+	anySwapOutLogTopic             = routerABI.Events["LogAnySwapOut"].ID
+	anySwapTradeTokensForTokensLog = routerABI.Events["LogAnySwapTradeTokensForTokens"].ID
+	anySwapTradeTokensForNativeLog = routerABI.Events["LogAnySwapTradeTokensForNative"].ID
+	// 1. Mint (or 3. Redeemed of underlying) log
+	anySwapInLogTopic = routerABI.Events["LogAnySwapIn"].ID
+)
+
+// verify tx of router token, swap out is Burn (Deposit of underlying), swap in is Mint (Redeemed of underlying).
+// every router log of the token is a swap, which is always keyed by tx hash and log index,
+// so that its key does not depend on the other router logs of the tx.
+// the ref tx hash of LogAnySwapIn has no log index, it is resolved by getRefSwapEvent when matching.
+func (scanner *ethSwapScanner) verifyRouterTransaction(tx *types.Transaction, receipt *types.Receipt, header *types.Header, tokenCfg *params.TokenConfig) (swaps []*txSwap, err error) {
+	if receipt == nil {
+		txTo := tx.To().Hex()
+		if !strings.EqualFold(txTo, tokenCfg.RouterContract) && !strings.EqualFold(txTo, tokenCfg.CallByContract) {
+			return nil, nil
+		}
+		if receipt, err = scanner.getTxReceipt(tx, header); err != nil {
+			return nil, err
+		}
+	}
+	return parseRouterTxLogs(receipt.Logs, tokenCfg, tx, header), nil
+}
+
+func routerSwapType(isSwapout bool, tokenCfg *params.TokenConfig) SwapTxType {
+	switch {
+	case isSwapout && tokenCfg.IsUnderlying:
+		return TypeDeposit
+	case isSwapout:
+		return TypeBurn
+	case tokenCfg.IsUnderlying:
+		return TypeRedeemed
+	default:
+		return TypeMint
+	}
+}
+
+// the undecodable router logs are skipped with warning, they do not discard the other swaps of tx
+func parseRouterTxLogs(logs []*types.Log, tokenCfg *params.TokenConfig, tx *types.Transaction, header *types.Header) (swaps []*txSwap) {
+	for _, rlog := range logs {
+		if rlog.Removed {
+			continue
+		}
+		if !strings.EqualFold(rlog.Address.Hex(), tokenCfg.RouterContract) {
+			continue
+		}
+		swapData := newSwapEvent(tx, header)
+		isSwapout, err := parseRouterTxLog(rlog, tokenCfg, swapData)
+		if err == tokens.ErrSwapoutLogNotFound {
+			continue
+		}
+		if err != nil {
+			log.Warn("skip undecodable router log", "pairID", tokenCfg.PairID, "txHash", tx.Hash().Hex(), "logIndex", rlog.Index, "err", err)
+			continue
+		}
+		logIndex := rlog.Index
+		swapData.Index = &logIndex
+		swaps = append(swaps, &txSwap{txType: routerSwapType(isSwapout, tokenCfg), swapData: swapData})
+	}
+	return swaps
+}
+
+func parseRouterTxLog(rlog *types.Log, tokenCfg *params.TokenConfig, swapData *SwapEvent) (isSwapout bool, err error) {
+	token := common.HexToAddress(tokenCfg.TokenAddress)
+	if len(rlog.Topics) < 3 {
+		return false, tokens.ErrSwapoutLogNotFound
+	}
+	switch rlog.Topics[0] {
+	case anySwapOutLogTopic:
+		if len(rlog.Topics) != 4 || common.BytesToAddress(rlog.Topics[1][:]) != token {
+			break
+		}
+		swapData.User = common.BytesToAddress(rlog.Topics[2][:])
+		swapData.Bind = common.BytesToAddress(rlog.Topics[3][:]).Hex()
+		return true, parseRouterLogData(rlog, "LogAnySwapOut", "amount", swapData)
+	case anySwapTradeTokensForTokensLog, anySwapTradeTokensForNativeLog:
+		if len(rlog.Topics) != 3 {
+			break
+		}
+		event, _ := routerABI.EventByID(rlog.Topics[0])
+		args, err := event.Inputs.Unpack(rlog.Data)
+		if err != nil || len(args) == 0 {
+			return false, tokens.ErrTxWithWrongLogData
+		}
+		path, ok := args[0].([]common.Address)
+		if !ok || len(path) == 0 || path[0] != token {
+			break
+		}
+		swapData.User = common.BytesToAddress(rlog.Topics[1][:])
+		swapData.Bind = common.BytesToAddress(rlog.Topics[2][:]).Hex()
+		return true, parseRouterLogData(rlog, event.Name, "amountIn", swapData)
+	case anySwapInLogTopic:
+		if len(rlog.Topics) != 4 || common.BytesToAddress(rlog.Topics[2][:]) != token {
+			break
+		}
+		swapData.RefTxHash = rlog.Topics[1]
+		swapData.User = common.BytesToAddress(rlog.Topics[3][:])
+		return false, parseRouterLogData(rlog, "LogAnySwapIn", "amount", swapData)
+	}
+	return false, tokens.ErrSwapoutLogNotFound
+}
+
+func parseRouterLogData(rlog *types.Log, eventName, amountArg string, swapData *SwapEvent) (err error) {
+	args := make(map[string]interface{})
+	if err = routerABI.UnpackIntoMap(args, eventName, rlog.Data); err != nil {
+		return tokens.ErrTxWithWrongLogData
+	}
+	if swapData.Amount, err = abiArgBigInt(args, amountArg); err != nil {
+		return err
+	}
+	if swapData.FromChainID, err = abiArgBigInt(args, "fromChainID"); err != nil {
+		return err
+	}
+	if swapData.ToChainID, err = abiArgBigInt(args, "toChainID"); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+const testRouterAddress = "0x6b7a87899490ece95443e979ca9485cbe7e71522"
+
+func testRouterSwapOutLog(index uint, amount int64) *types.Log {
+	data, err := routerABI.Events["LogAnySwapOut"].Inputs.NonIndexed().Pack(big.NewInt(amount), big.NewInt(250), big.NewInt(56))
+	if err != nil {
+		panic(err)
+	}
+	return &types.Log{
+		Address: common.HexToAddress(testRouterAddress),
+		Topics: []common.Hash{
+			anySwapOutLogTopic,
+			testTopic(testTokenAddress),
+			testTopic(testUserAddress),
+			testTopic(testUserAddress),
+		},
+		Data:  data,
+		Index: index,
+	}
+}
+
+func TestParseRouterTxLogs(t *testing.T) {
+	tokenCfg := &params.TokenConfig{
+		PairID:         "anyDAI",
+		TokenType:      params.TokenTypeRouter,
+		RouterContract: testRouterAddress,
+		TokenAddress:   testTokenAddress,
+	}
+	tx := types.NewTransaction(0, common.HexToAddress(testRouterAddress), big.NewInt(0), 100000, big.NewInt(1), nil)
+	header := &types.Header{Number: big.NewInt(100), Time: 1600000000}
+
+	undecodable := testRouterSwapOutLog(5, 1)
+	undecodable.Data = undecodable.Data[:32]
+	otherToken := testRouterSwapOutLog(6, 1)
+	otherToken.Topics[1] = testTopic(testDepositAddress)
+
+	tests := []struct {
+		name    string
+		logs    []*types.Log
+		keys    []string
+		amounts []int64
+	}{
+		{
+			name:    "single swapout",
+			logs:    []*types.Log{testRouterSwapOutLog(3, 100)},
+			keys:    []string{":3"},
+			amounts: []int64{100},
+		},
+		{
+			name:    "several swapouts",
+			logs:    []*types.Log{testRouterSwapOutLog(3, 100), otherToken, testRouterSwapOutLog(7, 200)},
+			keys:    []string{":3", ":7"},
+			amounts: []int64{100, 200},
+		},
+		{
+			name:    "undecodable log is skipped",
+			logs:    []*types.Log{undecodable, testRouterSwapOutLog(7, 200)},
+			keys:    []string{":7"},
+			amounts: []int64{200},
+		},
+		{
+			name: "no swap of token",
+			logs: []*types.Log{otherToken},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			swaps := parseRouterTxLogs(tt.logs, tokenCfg, tx, header)
+			if len(swaps) != len(tt.amounts) {
+				t.Fatalf("swaps count mismatch, have %v want %v", len(swaps), len(tt.amounts))
+			}
+			for i, swap := range swaps {
+				if swap.txType != TypeBurn {
+					t.Errorf("swap %v type mismatch, have %v want %v", i, swap.txType, TypeBurn)
+				}
+				if swap.swapData.Amount.Int64() != tt.amounts[i] {
+					t.Errorf("swap %v amount mismatch, have %v want %v", i, swap.swapData.Amount, tt.amounts[i])
+				}
+				key := convertToMgoSwapEvent(swap.swapData, 18).TxHash
+				if want := tx.Hash().Hex() + tt.keys[i]; !strings.EqualFold(key, want) {
+					t.Errorf("swap %v key mismatch, have %v want %v", i, key, want)
+				}
+			}
+		})
+	}
+}
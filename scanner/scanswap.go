@@ -6,8 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/anyswap/CrossChain-Bridge/cmd/utils"
@@ -16,11 +20,14 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	//ethereum "github.com/fsn-dev/fsn-go-sdk/efsn"
-	"github.com/gaozhengxin/bridgeAccounting/params"
 	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
 	//"github.com/gaozhengxin/bridgeAccounting/tools"
 	"github.com/gaozhengxin/bridgeAccounting/accounting"
+	"github.com/gaozhengxin/bridgeAccounting/alert"
+	"github.com/gaozhengxin/bridgeAccounting/metrics"
 	"github.com/urfave/cli/v2"
 )
 
@@ -42,7 +49,13 @@ var (
 		Value: 300,
 	}
 
-	// StartCommand scan swaps on eth like blockchain, and do accounting
+	shutdownTimeoutFlag = &cli.Uint64Flag{
+		Name:  "shutdownTimeout",
+		Usage: "timeout of waiting in-flight blocks when shutdown in seconds",
+		Value: 30,
+	}
+
+	// StartCommand scan swaps on eth and btc like blockchains, and do accounting
 	StartCommand = &cli.Command{
 		Action:    start,
 		Name:      "start",
@@ -53,12 +66,13 @@ scan cross chain swaps
 `,
 		Flags: []cli.Flag{
 			utils.ConfigFileFlag,
+			shutdownTimeoutFlag,
 		},
 	}
 
 	// 0. Deposit and 3. Redeemed
-	transferFuncHash       = common.FromHex("0xa9059cbb")
-	transferFromFuncHash   = common.FromHex("0x23b872dd")
+	transferFuncHash     = common.FromHex("0xa9059cbb")
+	transferFromFuncHash = common.FromHex("0x23b872dd")
 
 	// 1. Mint
 	swapinFuncHash = common.FromHex("0xec126c77")
@@ -68,7 +82,7 @@ scan cross chain swaps
 	stringSwapoutFuncHash  = common.FromHex("0xad54056d") // for BTC like `string` type address
 
 	// 0. Deposit and 3. Redeemed log, but also seen in 1. Mint
-	transferLogTopic       = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
+	transferLogTopic = common.HexToHash("0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef")
 
 	// 1. Mint log
 	swapinLogTopic = common.HexToHash("0x05d0634fe981be85c22e2942a880821b70095d84e152c3ea3c17a4e4250d9d61")
@@ -78,13 +92,20 @@ scan cross chain swaps
 	stringSwapoutLogTopic  = common.HexToHash("0x9c92ad817e5474d30a4378deface765150479363a897b0590fbb12ae9d89396b")
 )
 
+var (
+	errSwapinLogNotFound   = errors.New("swapin log not found or removed")
+	errProcessBlockTimeout = errors.New("process block timeout")
+	errGetTxReceipt        = errors.New("get tx receipt failed")
+)
+
 const (
 	swapExistKeywords   = "mgoError: Item is duplicate"
 	httpTimeoutKeywords = "Client.Timeout exceeded while awaiting headers"
 )
 
 type ethSwapScanner struct {
-	gateway     string
+	chain       string
+	gateways    []string
 	scanReceipt bool
 
 	startHeightArgument int64
@@ -93,16 +114,38 @@ type ethSwapScanner struct {
 	stableHeight uint64
 	jobCount     uint64
 
+	finalityMode    string
+	finalityChecker string
+	anomalyDelay    time.Duration
+	watchAddresses  bool
+
 	processBlockTimeout time.Duration
-	processBlockTimers  []*time.Timer
 
-	client *ethclient.Client
-	ctx    context.Context
+	clients     []*ethclient.Client
+	rpcClients  []*rpc.Client // the underlying rpc clients of clients
+	clientIndex uint32
+	archiveFile string
+	offline     blockSource     // scan offline from archive or simulated chain if not nil
+	ctx         context.Context // cancel the rpc calls
+	cancel      context.CancelFunc
+	quit        chan struct{}   // stop fetching new blocks
+	inflight    *sync.WaitGroup // running jobs waited when stop
+
+	tokens []*params.TokenConfig // only scan these tokens if not nil
+
+	// compare with existing swap events when rescan
+	rescanStats *rescanStats
+	dryRun      bool
 
 	rpcInterval   time.Duration
 	rpcRetryCount int
 
-	chainId *big.Int
+	chainId         *big.Int
+	latestHeight    *uint64 // latest block number got from gateway
+	finalizedHeight *uint64 // latest final block number, refreshed by the confirm loop
+
+	cachedBlocks *cachedSacnnedBlocks
+	tokenMetas   *tokenMetaRegistry // shared with the cloned scanners
 }
 
 var (
@@ -123,78 +166,224 @@ type SyncAPI interface {
 func start(ctx *cli.Context) error {
 	utils.SetLogger(ctx)
 	cfg := params.LoadConfig(utils.GetConfigFilePath(ctx))
-	go params.WatchAndReloadScanConfig()
-
-	srcScanner := &ethSwapScanner{
-		ctx:           context.Background(),
-		rpcInterval:   1 * time.Second,
-		rpcRetryCount: 3,
-	}
-	srcScanner.gateway = cfg.SrcGateway
-	srcScanner.scanReceipt = cfg.SrcScanReceipt
-	srcScanner.startHeightArgument = cfg.SrcStartHeightArgument
-	srcScanner.endHeight = uint64(cfg.SrcEndHeight)
-	srcScanner.stableHeight = uint64(cfg.SrcStableHeight)
-	srcScanner.jobCount = uint64(cfg.SrcJobCount)
-	srcScanner.processBlockTimeout = time.Duration(cfg.SrcProcessBlockTimeout) * time.Second
-
-	log.Info("get src argument success",
-		"gateway", srcScanner.gateway,
-		"scanReceipt", srcScanner.scanReceipt,
-		"start", srcScanner.startHeightArgument,
-		"end", srcScanner.endHeight,
-		"stable", srcScanner.stableHeight,
-		"jobs", srcScanner.jobCount,
-		"timeout", srcScanner.processBlockTimeout,
-	)
+	shutdownTimeout := time.Duration(ctx.Uint64(shutdownTimeoutFlag.Name)) * time.Second
+	if err := initBlockCache(cfg.BlockCache); err != nil {
+		log.Fatal("init block cache failed", "err", err)
+	}
+
+	rootCtx, rootCancel := context.WithCancel(ctx.Context)
+	defer rootCancel()
+	go params.WatchAndReloadScanConfig(rootCtx)
+
+	mongodb.MongoServerInit(rootCtx, cfg, cfg.MongoDB.DBURLs, cfg.MongoDB.DBName, cfg.MongoDB.UserName, cfg.MongoDB.Password)
+	dbAPI = mongodb.NewSyncAPI()
+	alert.StartAlerting(rootCtx)
+	if cfg.MetricsListen != "" {
+		metrics.StartServer(rootCtx, cfg.MetricsListen)
+	}
+
+	for _, chainCfg := range cfg.Chains {
+		if err := startChainScanner(rootCtx, chainCfg, false); err != nil {
+			log.Fatal("start chain scanner failed", "chain", chainCfg.Name, "err", err)
+		}
+	}
+	params.RegisterReloadCallback(func(oldConfig, newConfig *params.ScanConfig) {
+		onConfigReload(rootCtx, oldConfig, newConfig)
+	})
+	go accounting.StartAccounting(rootCtx)
+
+	signalCh := make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signalCh
+	log.Info("receive signal, shutdown", "signal", sig, "timeout", shutdownTimeout)
+
+	stopAllChainScanners(shutdownTimeout)
+	rootCancel()
+	mongodb.MongoServerClose()
+	log.Info("shutdown finished")
+	return nil
+}
 
-	dstScanner := &ethSwapScanner{
-		ctx:           context.Background(),
-		rpcInterval:   1 * time.Second,
-		rpcRetryCount: 3,
-	}
-	dstScanner.gateway = cfg.DstGateway
-	dstScanner.scanReceipt = cfg.DstScanReceipt
-	dstScanner.startHeightArgument = cfg.DstStartHeightArgument
-	dstScanner.endHeight = uint64(cfg.DstEndHeight)
-	dstScanner.stableHeight = uint64(cfg.DstStableHeight)
-	dstScanner.jobCount = uint64(cfg.DstJobCount)
-	dstScanner.processBlockTimeout = time.Duration(cfg.DstProcessBlockTimeout) * time.Second
-
-	log.Info("get dst argument success",
-		"gateway", dstScanner.gateway,
-		"scanReceipt", dstScanner.scanReceipt,
-		"start", dstScanner.startHeightArgument,
-		"end", dstScanner.endHeight,
-		"stable", dstScanner.stableHeight,
-		"jobs", dstScanner.jobCount,
-		"timeout", dstScanner.processBlockTimeout,
+func newEthSwapScanner(parentCtx context.Context, chainCfg *params.ChainConfig) *ethSwapScanner {
+	ctx, cancel := context.WithCancel(parentCtx)
+	scanner := &ethSwapScanner{
+		ctx:             ctx,
+		cancel:          cancel,
+		quit:            make(chan struct{}),
+		inflight:        new(sync.WaitGroup),
+		latestHeight:    new(uint64),
+		finalizedHeight: new(uint64),
+		rpcInterval:     1 * time.Second,
+		rpcRetryCount:   3,
+		cachedBlocks:    newCachedScannedBlocks(100),
+		tokenMetas:      newTokenMetaRegistry(),
+	}
+	scanner.chain = chainCfg.Name
+	scanner.chainId = chainCfg.GetChainID()
+	scanner.gateways = chainCfg.Gateways
+	scanner.archiveFile = chainCfg.ArchiveFile
+	scanner.scanReceipt = chainCfg.ScanReceipt
+	scanner.startHeightArgument = chainCfg.StartHeightArgument
+	scanner.endHeight = uint64(chainCfg.EndHeight)
+	scanner.stableHeight = uint64(chainCfg.StableHeight)
+	scanner.finalityMode = chainCfg.GetFinalityMode()
+	scanner.finalityChecker = chainCfg.FinalityChecker
+	scanner.anomalyDelay = chainCfg.GetAnomalyDelay()
+	scanner.watchAddresses = chainCfg.WatchAddresses
+	scanner.jobCount = uint64(chainCfg.JobCount)
+	scanner.processBlockTimeout = time.Duration(chainCfg.ProcessBlockTimeout) * time.Second
+
+	log.Info("get chain argument success",
+		"chain", scanner.chain,
+		"chainID", scanner.chainId,
+		"gateways", scanner.gateways,
+		"scanReceipt", scanner.scanReceipt,
+		"start", scanner.startHeightArgument,
+		"end", scanner.endHeight,
+		"stable", scanner.stableHeight,
+		"finality", scanner.finalityMode,
+		"watch", scanner.watchAddresses,
+		"jobs", scanner.jobCount,
+		"timeout", scanner.processBlockTimeout,
 	)
+	return scanner
+}
 
-	srcScanner.initClient()
-	dstScanner.initClient()
-	go srcScanner.run()
-	go dstScanner.run()
-	go accounting.StartAccounting()
-	select {}
+func (scanner *ethSwapScanner) initClient() error {
+	if scanner.archiveFile != "" {
+		return scanner.initArchive()
+	}
+	for _, gateway := range scanner.gateways {
+		rpcCli, err := rpc.DialContext(scanner.ctx, gateway)
+		if err != nil {
+			log.Warn("ethclient.Dail failed", "chain", scanner.chain, "gateway", gateway, "err", err)
+			continue
+		}
+		ethcli := ethclient.NewClient(rpcCli)
+		chainID, err := ethcli.ChainID(scanner.ctx)
+		if err != nil {
+			log.Warn("get chain ID failed", "chain", scanner.chain, "gateway", gateway, "err", err)
+			continue
+		}
+		if chainID.Cmp(scanner.chainId) != 0 {
+			return fmt.Errorf("chain ID mismatch, gateway %v have %v want %v", gateway, chainID, scanner.chainId)
+		}
+		log.Info("ethclient.Dail gateway success", "chain", scanner.chain, "gateway", gateway)
+		scanner.clients = append(scanner.clients, ethcli)
+		scanner.rpcClients = append(scanner.rpcClients, rpcCli)
+	}
+	if len(scanner.clients) == 0 {
+		return fmt.Errorf("no available gateway in %v", scanner.gateways)
+	}
 	return nil
 }
 
-func (scanner *ethSwapScanner) initClient() {
-	ethcli, err := ethclient.Dial(scanner.gateway)
+// scan from the synced height instead of the start height of config
+func (scanner *ethSwapScanner) resumeFrom(height int64) {
+	scanner.startHeightArgument = height
+}
+
+func (scanner *ethSwapScanner) client() *ethclient.Client {
+	index := atomic.LoadUint32(&scanner.clientIndex)
+	return scanner.clients[int(index)%len(scanner.clients)]
+}
+
+func (scanner *ethSwapScanner) rpcClient() *rpc.Client {
+	index := atomic.LoadUint32(&scanner.clientIndex)
+	return scanner.rpcClients[int(index)%len(scanner.rpcClients)]
+}
+
+func (scanner *ethSwapScanner) initArchive() error {
+	archive, err := openBlockArchive(scanner.archiveFile)
 	if err != nil {
-		log.Fatal("ethclient.Dail failed", "gateway", scanner.gateway, "err", err)
+		return err
+	}
+	if archive.meta.ChainID.Cmp(scanner.chainId) != 0 {
+		_ = archive.close()
+		return fmt.Errorf("chain ID mismatch, archive %v have %v want %v", scanner.archiveFile, archive.meta.ChainID, scanner.chainId)
+	}
+	scanner.offline = archive
+	if scanner.endHeight == 0 {
+		scanner.endHeight = archive.meta.To
 	}
-	log.Info("ethclient.Dail gateway success", "gateway", scanner.gateway)
-	scanner.client = ethcli
+	return nil
 }
 
-func (scanner *ethSwapScanner) run() {
-	scanner.processBlockTimers = make([]*time.Timer, scanner.jobCount+1)
-	for i := 0; i < len(scanner.processBlockTimers); i++ {
-		scanner.processBlockTimers[i] = time.NewTimer(scanner.processBlockTimeout)
+// get block source, the offline source if exist, otherwise the current gateway
+func (scanner *ethSwapScanner) source() blockSource {
+	if scanner.offline != nil {
+		return scanner.offline
+	}
+	return scanner.client()
+}
+
+// switch to the next gateway after rpc failure
+func (scanner *ethSwapScanner) switchClient() {
+	if len(scanner.clients) > 1 {
+		atomic.AddUint32(&scanner.clientIndex, 1)
+	}
+}
+
+func (scanner *ethSwapScanner) isStopped() bool {
+	select {
+	case <-scanner.quit:
+		return true
+	default:
+		return false
 	}
+}
+
+// run job in goroutine, the job is waited when stop scanner
+func (scanner *ethSwapScanner) goJob(job func()) {
+	scanner.inflight.Add(1)
+	go func() {
+		defer scanner.inflight.Done()
+		job()
+	}()
+}
+
+// stop fetching new blocks and wait the in-flight blocks finished,
+// then cancel the rpc calls. return false if timeout.
+func (scanner *ethSwapScanner) stop(timeout time.Duration) bool {
+	log.Info("stop scanner", "chain", scanner.chain, "timeout", timeout)
+	close(scanner.quit)
+	defer scanner.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		scanner.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Info("stop scanner finished", "chain", scanner.chain)
+		return true
+	case <-time.After(timeout):
+		log.Warn("stop scanner timeout", "chain", scanner.chain, "timeout", timeout)
+		return false
+	}
+}
+
+// get token configs of this chain, or the specified ones if exist
+func (scanner *ethSwapScanner) getTokenConfigs() []*params.TokenConfig {
+	if scanner.tokens != nil {
+		return scanner.tokens
+	}
+	return params.GetScanConfig().GetTokenConfigs(scanner.chain)
+}
 
+// clone scanner which only scans the specified tokens in range [start, end)
+func (scanner *ethSwapScanner) cloneForTokens(tokenCfgs []*params.TokenConfig, end uint64) *ethSwapScanner {
+	cloned := *scanner
+	cloned.tokens = tokenCfgs
+	cloned.endHeight = end
+	cloned.cachedBlocks = newCachedScannedBlocks(100)
+	return &cloned
+}
+
+func (scanner *ethSwapScanner) run() {
+	scanner.initTokenMetas()
+	var initial *blockScheduler
 	wend := scanner.endHeight
 	if wend == 0 {
 		wend = scanner.loopGetLatestBlockNumber()
@@ -206,181 +395,367 @@ func (scanner *ethSwapScanner) run() {
 		} else if scanner.startHeightArgument < 0 {
 			start = wend - uint64(-scanner.startHeightArgument)
 		}
-		scanner.doScanRangeJob(start, wend)
+		if err := dbAPI.SetStartHeight(scanner.chain, int64(start)); err != nil {
+			log.Warn("set start height failed", "chain", scanner.chain, "start", start, "err", err)
+		}
+		scanner.startTokenBackfills(start)
+		scanner.checkStartGap(start)
+		if start < wend {
+			initial = scanner.doScanRangeJob(start, wend)
+		}
+	} else {
+		// the blocks missed while stopped are not scanned from the latest height
+		scanner.startTokenBackfills(wend)
+		scanner.checkStartGap(wend)
 	}
 	if scanner.endHeight == 0 {
-		scanner.scanLoop(wend)
+		scanner.goJob(scanner.auditGaps)
+		scanner.goJob(scanner.confirmLoop)
+		scanner.scanLoop(wend, initial)
+	} else {
+		scanner.settleSwapEvents()
 	}
 }
 
-func (scanner *ethSwapScanner) doScanRangeJob(start, end uint64) {
-	log.Info("start scan range job", "start", start, "end", end, "jobs", scanner.jobCount)
+// scan blocks in range [start, end) by the block scheduler,
+// wait finished if the scanner has end height.
+// the unfinished heights are recorded as block gaps if the scanner is stopped.
+func (scanner *ethSwapScanner) doScanRangeJob(start, end uint64) *blockScheduler {
 	if scanner.jobCount == 0 {
 		log.Fatal("zero count jobs specified")
 	}
 	if start >= end {
 		log.Fatalf("wrong scan range [%v, %v)", start, end)
 	}
-	jobs := scanner.jobCount
-	count := end - start
-	step := count / jobs
-	if step == 0 {
-		jobs = 1
-		step = count
-	}
-	wg := new(sync.WaitGroup)
-	for i := uint64(0); i < jobs; i++ {
-		from := start + i*step
-		to := start + (i+1)*step
-		if i+1 == jobs {
-			to = end
-		}
-		wg.Add(1)
-		go scanner.scanRange(i+1, from, to, wg)
-	}
+	scheduler := newBlockScheduler(scanner, start, end)
+	scheduler.gapsOnStop = !scanner.dryRun
 	if scanner.endHeight != 0 {
-		wg.Wait()
+		scheduler.run()
+	} else {
+		scanner.goJob(scheduler.run)
 	}
+	return scheduler
 }
 
-func (scanner *ethSwapScanner) scanRange(job, from, to uint64, wg *sync.WaitGroup) {
-	defer wg.Done()
-	log.Info(fmt.Sprintf("[%v] scan range", job), "from", from, "to", to)
-
-	for h := from; h < to; h++ {
-		scanner.scanBlock(job, h, false)
+// the heights of the initial range job may be still pending,
+// the synced height does not exceed its watermark until it is finished
+func boundSyncedHeight(height uint64, initial *blockScheduler) uint64 {
+	if initial == nil {
+		return height
 	}
-
-	log.Info(fmt.Sprintf("[%v] scan range finish", job), "from", from, "to", to)
+	watermark := initial.getWatermark()
+	if watermark >= initial.end || watermark > height {
+		return height
+	}
+	if watermark == 0 {
+		return 0
+	}
+	return watermark - 1
 }
 
-func (scanner *ethSwapScanner) scanLoop(from uint64) {
+func (scanner *ethSwapScanner) scanLoop(from uint64, initial *blockScheduler) {
 	stable := scanner.stableHeight
-	log.Info("start scan loop job", "from", from, "stable", stable)
+	log.Info("start scan loop job", "chain", scanner.chain, "from", from, "stable", stable)
+	var synced uint64
+	worker := newBlockWorker(0, scanner.processBlockTimeout)
+	defer worker.timer.Stop()
 	for {
 		latest := scanner.loopGetLatestBlockNumber()
+		if scanner.isStopped() {
+			return
+		}
+		alert.OnScanTick(scanner.chain, latest, synced)
 		for h := from; h <= latest; h++ {
-			scanner.scanBlock(0, h, true)
+			if scanner.isStopped() {
+				// flush the watermark of the finished blocks
+				if h > from && h-1 > synced {
+					scanner.updateSyncedHeight(boundSyncedHeight(h-1, initial))
+				}
+				return
+			}
+			scanner.scanBlock(worker, h, true)
 		}
+		synced = boundSyncedHeight(latest, initial)
+		scanner.updateSyncedHeight(synced)
 		if from+stable < latest {
 			from = latest - stable
 		}
-		time.Sleep(1 * time.Second)
+		select {
+		case <-scanner.quit:
+			return
+		case <-time.After(1 * time.Second):
+		}
+	}
+}
+
+func (scanner *ethSwapScanner) updateSyncedHeight(height uint64) {
+	if err := dbAPI.UpdateSyncedHeight(scanner.chain, int64(height)); err != nil {
+		log.Warn("update synced height failed", "chain", scanner.chain, "height", height, "err", err)
 	}
 }
 
 func (scanner *ethSwapScanner) loopGetLatestBlockNumber() uint64 {
-	for { // retry until success
-		header, err := scanner.client.HeaderByNumber(scanner.ctx, nil)
+	for !scanner.isStopped() { // retry until success
+		header, err := scanner.source().HeaderByNumber(scanner.ctx, nil)
 		if err == nil {
-			log.Info("get latest block number success", "height", header.Number)
+			log.Info("get latest block number success", "chain", scanner.chain, "height", header.Number)
+			atomic.StoreUint64(scanner.latestHeight, header.Number.Uint64())
 			return header.Number.Uint64()
 		}
-		log.Warn("get latest block number failed", "err", err)
+		log.Warn("get latest block number failed", "chain", scanner.chain, "err", err)
+		scanner.switchClient()
 		time.Sleep(scanner.rpcInterval)
 	}
+	return 0
 }
 
-func (scanner *ethSwapScanner) loopGetTxReceipt(txHash common.Hash) (receipt *types.Receipt, err error) {
+// is block of height not affected by reorg
+func (scanner *ethSwapScanner) isStableHeight(height uint64) bool {
+	latest := atomic.LoadUint64(scanner.latestHeight)
+	if latest == 0 {
+		latest = scanner.loopGetLatestBlockNumber()
+	}
+	return height+scanner.stableHeight <= latest
+}
+
+// get receipt from block cache first if block hash is not empty
+func (scanner *ethSwapScanner) loopGetTxReceipt(txHash, blockHash common.Hash) (receipt *types.Receipt, err error) {
+	useCache := blkCache != nil && scanner.offline == nil && blockHash != (common.Hash{})
+	if useCache {
+		if receipt = blkCache.getReceipt(scanner.chainId, blockHash, txHash); receipt != nil {
+			return receipt, nil
+		}
+	}
 	for i := 0; i < 5; i++ { // with retry
-		receipt, err = scanner.client.TransactionReceipt(scanner.ctx, txHash)
+		receipt, err = scanner.source().TransactionReceipt(scanner.ctx, txHash)
 		if err == nil {
+			if useCache && receipt.BlockHash == blockHash {
+				blkCache.putReceipt(scanner.chainId, receipt)
+			}
 			return receipt, err
 		}
+		scanner.switchClient()
 		time.Sleep(scanner.rpcInterval)
 	}
 	return nil, err
 }
 
+// get receipt of tx in block for decoding its logs, the error aborts the scan of tx,
+// so that the block is retried and recorded as block gap finally
+func (scanner *ethSwapScanner) getTxReceipt(tx *types.Transaction, header *types.Header) (*types.Receipt, error) {
+	receipt, err := scanner.loopGetTxReceipt(tx.Hash(), header.Hash())
+	if err == nil && receipt == nil {
+		err = errors.New("receipt not found")
+	}
+	if err != nil {
+		log.Warn("get tx receipt error", "txHash", tx.Hash().Hex(), "err", err)
+		return nil, fmt.Errorf("%w: %v", errGetTxReceipt, err)
+	}
+	return receipt, nil
+}
+
+// get block from block cache first, and cache it if it is stable
 func (scanner *ethSwapScanner) loopGetBlock(height uint64) (block *types.Block, err error) {
+	useCache := blkCache != nil && scanner.offline == nil
+	if useCache {
+		if block = blkCache.getBlock(scanner.chainId, height); block != nil {
+			return block, nil
+		}
+	}
 	blockNumber := new(big.Int).SetUint64(height)
 	for i := 0; i < 5; i++ { // with retry
-		block, err = scanner.client.BlockByNumber(scanner.ctx, blockNumber)
+		block, err = scanner.source().BlockByNumber(scanner.ctx, blockNumber)
 		if err == nil {
+			if useCache && scanner.isStableHeight(height) {
+				blkCache.putBlock(scanner.chainId, block)
+			}
 			return block, nil
 		}
-		log.Warn("get block failed", "height", height, "err", err)
+		log.Warn("get block failed", "chain", scanner.chain, "height", height, "err", err)
+		scanner.switchClient()
 		time.Sleep(scanner.rpcInterval)
 	}
 	return nil, err
 }
 
-func (scanner *ethSwapScanner) scanBlock(job, height uint64, cache bool) {
+// scan block and record block gap if failed
+func (scanner *ethSwapScanner) scanBlock(worker *blockWorker, height uint64, cache bool) {
+	next, blockHash, err := scanner.scanBlockFrom(worker, height, 0, "", cache)
+	if err != nil {
+		scanner.recordGap(height, height+1, next, blockHash, err.Error())
+	}
+}
+
+// scan transactions of block from the tx index, return the next tx index to resume and the block hash if failed.
+// the tx index is of the partially processed block of hash 'resumeHash', the block is scanned from the first tx if it is reorged.
+// the block is marked as scanned only if all of its txs are processed.
+func (scanner *ethSwapScanner) scanBlockFrom(worker *blockWorker, height uint64, txIndex int, resumeHash string, cache bool) (next int, blockHash string, err error) {
+	job := worker.id
 	block, err := scanner.loopGetBlock(height)
 	if err != nil {
-		return
+		return txIndex, resumeHash, fmt.Errorf("get block failed: %w", err)
 	}
-	blockHash := block.Hash().Hex()
-	if cache && cachedBlocks.isScanned(blockHash) {
-		return
+	blockHash = block.Hash().Hex()
+	if txIndex > 0 && !strings.EqualFold(resumeHash, blockHash) {
+		log.Warn(fmt.Sprintf("[%v] block %v is reorged, scan from the first tx", job, height), "chain", scanner.chain, "hash", blockHash, "resumeHash", resumeHash, "txIndex", txIndex)
+		txIndex = 0
 	}
-	log.Info(fmt.Sprintf("[%v] scan block %v", job, height), "hash", blockHash, "txs", len(block.Transactions()))
-
-	scanner.processBlockTimers[job].Reset(scanner.processBlockTimeout)
-SCANTXS:
-	for i, tx := range block.Transactions() {
+	if cache {
+		if scanner.cachedBlocks.isScanned(blockHash) {
+			return 0, blockHash, nil
+		}
+		if next, exist := scanner.cachedBlocks.getProgress(blockHash); exist && next > txIndex {
+			txIndex = next
+		}
+	}
+	txs := block.Transactions()
+	log.Info(fmt.Sprintf("[%v] scan block %v", job, height), "chain", scanner.chain, "hash", blockHash, "txs", len(txs), "from", txIndex)
+
+	header := block.Header()
+	// the token configs are not changed while the block is scanned
+	mpcAddresses := scanner.getMPCAddresses()
+	resetTimer(worker.timer, scanner.processBlockTimeout)
+	for i := txIndex; i < len(txs); i++ {
 		select {
-		case <-scanner.processBlockTimers[job].C:
-			log.Warn(fmt.Sprintf("[%v] scan block %v timeout", job, height), "hash", blockHash, "txs", len(block.Transactions()))
-			break SCANTXS
+		case <-worker.timer.C:
+			log.Warn(fmt.Sprintf("[%v] scan block %v timeout", job, height), "chain", scanner.chain, "hash", blockHash, "txs", len(txs), "processed", i)
+			if cache {
+				scanner.cachedBlocks.setProgress(blockHash, i)
+			}
+			return i, blockHash, errProcessBlockTimeout
 		default:
-			log.Debug(fmt.Sprintf("[%v] scan tx in block %v index %v", job, height, i), "tx", tx.Hash().Hex())
-			scanner.scanTransaction(tx)
+			log.Debug(fmt.Sprintf("[%v] scan tx in block %v index %v", job, height, i), "tx", txs[i].Hash().Hex())
+			if err = scanner.scanTransaction(header, txs[i], mpcAddresses); err != nil {
+				if cache {
+					scanner.cachedBlocks.setProgress(blockHash, i)
+				}
+				return i, blockHash, err
+			}
+		}
+	}
+	if txIndex > 0 {
+		// the partially processed block is finished
+		if err = dbAPI.RemoveBlockGap(mongodb.BlockGapKey(scanner.chain, int64(height))); err != nil {
+			log.Warn("remove block gap failed", "chain", scanner.chain, "height", height, "err", err)
 		}
 	}
 	if cache {
-		cachedBlocks.addBlock(blockHash)
+		scanner.cachedBlocks.addBlock(blockHash)
 	}
+	return 0, blockHash, nil
 }
 
-func (scanner *ethSwapScanner) scanTransaction(tx *types.Transaction) {
-	if tx.To() == nil {
-		return
+// reset timer with fresh timeout, drain the expired value if exist
+func resetTimer(timer *time.Timer, timeout time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(timeout)
+}
+
+// scan transaction and record its swap events, the mpc addresses are of the tokens of scanner.
+// return error if the swap event can not be recorded and the tx should be rescanned
+func (scanner *ethSwapScanner) scanTransaction(header *types.Header, tx *types.Transaction, mpcAddresses map[common.Address][]*params.TokenConfig) error {
+	if tx.To() == nil && !scanner.watchAddresses {
+		return nil
 	}
 	txHash := tx.Hash().Hex()
 	var receipt *types.Receipt
 	if scanner.scanReceipt {
-		r, err := scanner.loopGetTxReceipt(tx.Hash())
+		r, err := scanner.getTxReceipt(tx, header)
 		if err != nil {
-			log.Warn("get tx receipt error", "txHash", txHash, "err", err)
-			return
+			return err
 		}
 		receipt = r
 	}
+	if scanner.watchAddresses {
+		if err := scanner.watchTransaction(header, tx, receipt, mpcAddresses); err != nil {
+			log.Warn("watch tx failed", "txHash", txHash, "err", err)
+			return err
+		}
+		if tx.To() == nil {
+			return nil
+		}
+	}
 
-	for _, tokenCfg := range params.GetScanConfig().Tokens {
-		swapTxType, swapEvent, verifyErr := scanner.verifyTransaction(tx, receipt, tokenCfg)
+	redeemed := make(map[*params.TokenConfig]bool)
+	for _, tokenCfg := range scanner.getTokenConfigs() {
+		swaps, verifyErr := scanner.verifyTransactionSwaps(tx, receipt, header, tokenCfg)
+		if errors.Is(verifyErr, errGetTxReceipt) {
+			// rescan the tx later, instead of missing its swap
+			return verifyErr
+		}
 		if verifyErr != nil {
 			log.Debug("verify tx failed", "txHash", txHash, "err", verifyErr)
+			scanner.printVerifyError(txHash, verifyErr)
+			continue
+		}
+		if len(swaps) == 0 {
+			continue
 		}
 
-		mgoSwapEvent := convertToMgoSwapEvent(swapEvent, scanner.cachedDecimal(tokenCfg))
-
-		var syncError error
-		switch swapTxType {
-		case TypeDeposit:
-			syncError = dbAPI.AddDeposit(tokenCfg, mgoSwapEvent)
-		case TypeMint:
-			syncError = dbAPI.AddMint(tokenCfg, mgoSwapEvent)
-		case TypeBurn:
-			syncError = dbAPI.AddBurn(tokenCfg, mgoSwapEvent)
-		case TypeRedeemed:
-			syncError = dbAPI.AddRedeemed(tokenCfg, mgoSwapEvent)
-		default:
-			if verifyErr != nil {
-				scanner.printVerifyError(txHash, verifyErr)
+		meta, err := scanner.getTokenMeta(tokenCfg)
+		if err != nil {
+			log.Warn("swap event is not recorded", "pairID", tokenCfg.PairID, "swapTxType", swaps[0].txType, "txHash", txHash, "err", err)
+			return err
+		}
+		if receipt == nil {
+			if receipt, err = scanner.getBlockTxReceipt(tx, header); err != nil {
+				log.Warn("swap event is not recorded", "pairID", tokenCfg.PairID, "swapTxType", swaps[0].txType, "txHash", txHash, "err", err)
+				return err
 			}
 		}
-		if syncError != nil {
-			log.Warn("Add swap event error", "swapTxType", swapTxType, "syncError", syncError)
+		// the tx fee is paid once, it is recorded with the first swap
+		setTxFee(swaps[0].swapData, tx, receipt, header)
+		for _, swap := range swaps {
+			mgoSwapEvent := scanner.makeMgoSwapEvent(swap.txType, tokenCfg, swap.swapData, meta.Decimals)
+			scanner.recordSwapEvent(swap.txType, tokenCfg, mgoSwapEvent)
+			if swap.txType == TypeRedeemed {
+				redeemed[tokenCfg] = true
+			}
 		}
 	}
+	return scanner.detectUnclassifiedTransfers(header, tx, receipt, mpcAddresses, redeemed)
+}
+
+// convert swap event to the stored one without writing, which is unconfirmed if its block is not final,
+// the mint is matched with its deposit if it is found,
+// and the bind address of burn is checked in the format of its destination chain
+func (scanner *ethSwapScanner) makeMgoSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, swapEvent *SwapEvent, decimals int) *mongodb.SwapEvent {
+	mgoSwapEvent := convertToMgoSwapEvent(swapEvent, decimals)
+	mgoSwapEvent.Chain = tokenCfg.Chain
+	mgoSwapEvent.Unconfirmed = !scanner.isFinalizedHeight(swapEvent.BlockNumber.Uint64())
+	switch swapTxType {
+	case TypeMint:
+		matchDeposit(tokenCfg, mgoSwapEvent)
+	case TypeBurn:
+		checkBindAddress(tokenCfg, mgoSwapEvent)
+	}
+	return mgoSwapEvent
+}
+
+// the burn to invalid bind address can not be redeemed, it is recorded and marked.
+// the bind addresses of router swapouts are on various chains, they are not checked.
+func checkBindAddress(tokenCfg *params.TokenConfig, burn *mongodb.SwapEvent) {
+	if tokenCfg.IsRouterToken() {
+		return
+	}
+	bind, err := tokenCfg.NormalizeBindAddress(burn.Bind)
+	if err != nil {
+		log.Warn("burn with invalid bind address", "pairID", tokenCfg.PairID, "txHash", burn.TxHash, "bind", burn.Bind, "format", tokenCfg.GetBindFormat(), "err", err)
+		burn.InvalidBind = true
+		return
+	}
+	burn.Bind = bind
 }
 
 type SwapTxType int8
 
 const (
-	TypeDeposit  SwapTxType = iota
+	TypeDeposit SwapTxType = iota
 	TypeMint
 	TypeBurn
 	TypeRedeemed
@@ -389,93 +764,131 @@ const (
 const TypeNull SwapTxType = -1
 
 type SwapEvent struct {
-	TxHash common.Hash
-	BlockTime int64
+	TxHash      common.Hash
+	BlockTime   int64
 	BlockNumber *big.Int
-	Amount *big.Int
-	User common.Address
+	Amount      *big.Int
+	User        common.Address
+	Bind        string      // Burn only, the bind address on the other chain
+	RefTxHash   common.Hash // Mint only, the deposit tx hash on src chain
+	FromChainID *big.Int    // router only
+	ToChainID   *big.Int    // router only
+	GasUsed     uint64      // from receipt
+	GasPrice    *big.Int    // effective gas price, nil if receipt is not available
+	MPCAddress  string      // Deposit and Redeemed only, the deposit address received or the address sent from
+	Index       *uint       // router only, set if the tx has several swaps of the token, which are keyed by tx hash and log index
 }
 
-func (scanner *ethSwapScanner) verifyTransaction(tx *types.Transaction, receipt *types.Receipt, tokenCfg *params.TokenConfig) (txType SwapTxType, swapData *SwapEvent, verifyErr error) {
+// swap of transaction, a router tx may have several swaps of one token
+type txSwap struct {
+	txType   SwapTxType
+	swapData *SwapEvent
+}
+
+// verify tx with token config, return all the swaps of the token in tx
+func (scanner *ethSwapScanner) verifyTransactionSwaps(tx *types.Transaction, receipt *types.Receipt, header *types.Header, tokenCfg *params.TokenConfig) (swaps []*txSwap, verifyErr error) {
+	if tokenCfg.IsRouterToken() {
+		return scanner.verifyRouterTransaction(tx, receipt, header, tokenCfg)
+	}
+	txType, swapData, verifyErr := scanner.verifyTransaction(tx, receipt, header, tokenCfg)
+	if verifyErr != nil || txType == TypeNull {
+		return nil, verifyErr
+	}
+	return []*txSwap{{txType: txType, swapData: swapData}}, nil
+}
+
+func newSwapEvent(tx *types.Transaction, header *types.Header) *SwapEvent {
+	return &SwapEvent{
+		TxHash:      tx.Hash(),
+		BlockTime:   int64(header.Time),
+		BlockNumber: header.Number,
+	}
+}
+
+func (scanner *ethSwapScanner) verifyTransaction(tx *types.Transaction, receipt *types.Receipt, header *types.Header, tokenCfg *params.TokenConfig) (txType SwapTxType, swapData *SwapEvent, verifyErr error) {
 	txTo := tx.To().Hex()
-	txmsg, err := tx.AsMessage(types.NewEIP155Signer(scanner.chainId), nil)
+	txFrom, err := types.Sender(types.LatestSignerForChainID(scanner.chainId), tx)
 	if err != nil {
-		return TypeNull, nil, verifyErr
+		return TypeNull, nil, err
+	}
+	if tokenCfg.HasABIMappings() {
+		return scanner.verifyABITransaction(tx, txFrom, receipt, header, tokenCfg)
 	}
-	txFrom := txmsg.From()
 	cmpTxTo := tokenCfg.TokenAddress
-	depositAddress := tokenCfg.DepositAddress
+	height := header.Number.Uint64()
 
 	if tokenCfg.CallByContract != "" {
 		cmpTxTo = tokenCfg.CallByContract
 		if receipt == nil {
-			txHash := tx.Hash()
-			r, err := scanner.loopGetTxReceipt(txHash)
+			r, err := scanner.getTxReceipt(tx, header)
 			if err != nil {
-				log.Warn("get tx receipt error", "txHash", txHash.Hex(), "err", err)
-				return TypeNull, nil, nil
+				return TypeNull, nil, err
 			}
 			receipt = r
 		}
 	}
 
+	mpcAddresses := tokenCfg.GetMPCAddresses()
 	switch {
 	case tokenCfg.IsSrcToken:
 		// Src chain, Deposit or Redeemed
 		if tokenCfg.IsNativeToken() {
-			matched := strings.EqualFold(txTo, depositAddress)
+			if params.ContainsAddress(mpcAddresses, txFrom.Hex()) && params.ContainsAddress(mpcAddresses, txTo) {
+				// sweep between mpc addresses, for example when they are rotated
+				return TypeNull, nil, nil
+			}
+			matched := tokenCfg.IsDepositAddress(txTo, height)
 			if matched {
 				// deposit native
-				swapData = &SwapEvent{
-					TxHash: tx.Hash(),
-					BlockTime: scanner.getBlockTimestamp(receipt.BlockNumber),
-					BlockNumber: receipt.BlockNumber,
-					Amount: tx.Value(),
-					User: txFrom,
-				}
+				swapData = newSwapEvent(tx, header)
+				swapData.Amount = tx.Value()
+				swapData.User = txFrom
+				swapData.MPCAddress = txTo
 				return TypeDeposit, swapData, nil
-			} else if strings.EqualFold(txFrom.Hex(), depositAddress) {
+			} else if tokenCfg.IsRedeemSender(txFrom.Hex(), height) {
 				// redeemed native
-				swapData = &SwapEvent{
-					TxHash: tx.Hash(),
-					BlockTime: scanner.getBlockTimestamp(receipt.BlockNumber),
-					BlockNumber: receipt.BlockNumber,
-					Amount: tx.Value(),
-					User: *tx.To(),
-				}
+				swapData = newSwapEvent(tx, header)
+				swapData.Amount = tx.Value()
+				swapData.User = *tx.To()
+				swapData.MPCAddress = txFrom.Hex()
 				return TypeRedeemed, swapData, nil
 			}
 			return TypeNull, nil, nil
 		} else if strings.EqualFold(txTo, cmpTxTo) {
-			if strings.EqualFold(txFrom.Hex(), depositAddress) == false {
-				swapData, verifyErr = scanner.verifyErc20SwapinTx(tx, receipt, tokenCfg)
+			if !tokenCfg.IsRedeemSender(txFrom.Hex(), height) {
+				swapData, verifyErr = scanner.verifyErc20SwapinTx(tx, txFrom, receipt, header, tokenCfg)
 				if verifyErr == tokens.ErrTxWithWrongReceiver {
 					return TypeNull, nil, verifyErr
 				}
+				if verifyErr == nil && params.ContainsAddress(mpcAddresses, txFrom.Hex()) {
+					// sweep from the retired mpc address
+					return TypeNull, nil, nil
+				}
 				// deposit erc20
 				return TypeDeposit, swapData, verifyErr
-			} else {
-				swapData, verifyErr = scanner.verifyErc20RedeemTx(tx, receipt, tokenCfg)
-				// erc20 redeemed
-				return TypeRedeemed, swapData, verifyErr
 			}
+			swapData, verifyErr = scanner.verifyErc20RedeemTx(tx, txFrom, receipt, header, tokenCfg)
+			if verifyErr == nil && params.ContainsAddress(mpcAddresses, swapData.User.Hex()) {
+				// sweep between mpc addresses
+				return TypeNull, nil, nil
+			}
+			// erc20 redeemed
+			return TypeRedeemed, swapData, verifyErr
 		}
 	default:
 		// Dst chain, Mint or Burn
 		if strings.EqualFold(txTo, cmpTxTo) {
-			if strings.EqualFold(txFrom.Hex(), depositAddress) {
+			if tokenCfg.IsDepositAddress(txFrom.Hex(), height) {
 				// Mint
-				swapData, verifyErr = scanner.verifyMintTx(tx, receipt, tokenCfg)
+				swapData, verifyErr = scanner.verifyMintTx(tx, receipt, header, tokenCfg)
 				return TypeMint, swapData, verifyErr
-			} else {
-				// Burn
-				swapData, verifyErr = scanner.verifySwapoutTx(tx, receipt, tokenCfg)
-				if verifyErr == tokens.ErrTxWithWrongReceiver {
-					return TypeNull, nil, verifyErr
-				}
-				return TypeBurn, swapData, verifyErr
 			}
-			return TypeNull, nil, verifyErr
+			// Burn
+			swapData, verifyErr = scanner.verifySwapoutTx(tx, txFrom, receipt, header, tokenCfg)
+			if verifyErr == tokens.ErrTxWithWrongReceiver {
+				return TypeNull, nil, verifyErr
+			}
+			return TypeBurn, swapData, verifyErr
 		}
 	}
 	return TypeNull, nil, verifyErr
@@ -493,98 +906,96 @@ func (scanner *ethSwapScanner) printVerifyError(txHash string, verifyErr error)
 }
 
 // verify erc20 deposit
-func (scanner *ethSwapScanner) verifyErc20SwapinTx(tx *types.Transaction, receipt *types.Receipt, tokenCfg *params.TokenConfig) (swapData *SwapEvent, err error) {
-	swapData = &SwapEvent{
-		TxHash: tx.Hash(),
-		BlockTime: scanner.getBlockTimestamp(receipt.BlockNumber),
-		BlockNumber: receipt.BlockNumber,
-		Amount: nil,
-		User: common.Address{},
-	}
+func (scanner *ethSwapScanner) verifyErc20SwapinTx(tx *types.Transaction, txFrom common.Address, receipt *types.Receipt, header *types.Header, tokenCfg *params.TokenConfig) (swapData *SwapEvent, err error) {
+	swapData = newSwapEvent(tx, header)
+	swapData.User = txFrom
+	depositAddresses := tokenCfg.GetDepositAddresses(header.Number.Uint64())
 	if receipt == nil {
-		err = scanner.parseErc20SwapinTxInput(tx.Data(), tokenCfg.DepositAddress, swapData)
+		err = parseErc20SwapinTxInput(tx.Data(), depositAddresses, swapData)
 	} else {
-		err = scanner.parseErc20SwapinTxLogs(receipt.Logs, tokenCfg, swapData)
+		err = parseErc20SwapinTxLogs(receipt.Logs, tokenCfg.TokenAddress, depositAddresses, swapData)
 	}
 	return swapData, err
 }
 
-// verify erc20 redeemed
-func (scanner *ethSwapScanner) verifyErc20RedeemTx(tx *types.Transaction, receipt *types.Receipt, tokenCfg *params.TokenConfig) (swapData *SwapEvent, err error) {
-	swapData = &SwapEvent{
-		TxHash: tx.Hash(),
-		BlockTime: scanner.getBlockTimestamp(receipt.BlockNumber),
-		BlockNumber: receipt.BlockNumber,
-		Amount: nil,
-		User: common.Address{},
-	}
+// verify erc20 redeemed, which is sent from the redeem or deposit address
+func (scanner *ethSwapScanner) verifyErc20RedeemTx(tx *types.Transaction, txFrom common.Address, receipt *types.Receipt, header *types.Header, tokenCfg *params.TokenConfig) (swapData *SwapEvent, err error) {
+	swapData = newSwapEvent(tx, header)
+	swapData.MPCAddress = txFrom.Hex()
 	if receipt == nil {
-		err = scanner.parseErc20RedeemTxInput(tx.Data(), tokenCfg.DepositAddress, swapData)
+		err = parseErc20RedeemTxInput(tx.Data(), swapData)
 	} else {
-		err = scanner.parseErc20RedeemTxLogs(receipt.Logs, tokenCfg, swapData)
+		err = parseErc20RedeemTxLogs(receipt.Logs, tokenCfg.TokenAddress, txFrom.Hex(), swapData)
 	}
 	return swapData, err
 }
 
 // verify burn
-func (scanner *ethSwapScanner) verifySwapoutTx(tx *types.Transaction, receipt *types.Receipt, tokenCfg *params.TokenConfig) (swapData *SwapEvent, err error) {
-	swapData = &SwapEvent{
-		TxHash: tx.Hash(),
-		BlockTime: scanner.getBlockTimestamp(receipt.BlockNumber),
-		BlockNumber: receipt.BlockNumber,
-		Amount: nil,
-		User: common.Address{},
-	}
+func (scanner *ethSwapScanner) verifySwapoutTx(tx *types.Transaction, txFrom common.Address, receipt *types.Receipt, header *types.Header, tokenCfg *params.TokenConfig) (swapData *SwapEvent, err error) {
+	swapData = newSwapEvent(tx, header)
+	swapData.User = txFrom
 	if receipt == nil {
-		err = scanner.parseSwapoutTxInput(tx.Data(), swapData)
+		err = parseSwapoutTxInput(tx.Data(), swapData)
 	} else {
-		err = scanner.parseSwapoutTxLogs(receipt.Logs, tokenCfg, swapData)
+		err = parseSwapoutTxLogs(receipt.Logs, tokenCfg, swapData)
 	}
 	return swapData, err
 }
 
 // verify mint
-func (scanner *ethSwapScanner) verifyMintTx(tx *types.Transaction, receipt *types.Receipt, tokenCfg *params.TokenConfig) (swapData *SwapEvent, err error) {
-	swapData = &SwapEvent{
-		TxHash: tx.Hash(),
-		BlockTime: scanner.getBlockTimestamp(receipt.BlockNumber),
-		BlockNumber: receipt.BlockNumber,
-		Amount: nil,
-		User: common.Address{},
-	}
+func (scanner *ethSwapScanner) verifyMintTx(tx *types.Transaction, receipt *types.Receipt, header *types.Header, tokenCfg *params.TokenConfig) (swapData *SwapEvent, err error) {
+	swapData = newSwapEvent(tx, header)
 	if receipt == nil {
-		err = scanner.parseMintTxInput(tx.Data(), swapData)
+		err = parseMintTxInput(tx.Data(), swapData)
 	} else {
-		err = scanner.parseMintTxLogs(receipt.Logs, tokenCfg, swapData)
+		err = parseMintTxLogs(receipt.Logs, tokenCfg, swapData)
 	}
 	return swapData, err
 }
 
-func (scanner *ethSwapScanner) parseErc20SwapinTxInput(input []byte, depositAddress string, swapData *SwapEvent) error {
+// transfer(address to, uint256 amount)
+// transferFrom(address from, address to, uint256 amount)
+func parseErc20TransferInput(input []byte, swapData *SwapEvent) (receiver string, err error) {
 	if len(input) < 4 {
-		return tokens.ErrTxWithWrongInput
+		return "", tokens.ErrTxWithWrongInput
 	}
-	var receiver string
 	funcHash := input[:4]
+	encData := input[4:]
 	switch {
 	case bytes.Equal(funcHash, transferFuncHash):
-		receiver = common.BytesToAddress(GetData(input, 4, 32)).Hex()
+		if len(encData) != 64 {
+			return "", tokens.ErrTxIncompatible
+		}
+		receiver = common.BytesToAddress(GetData(encData, 0, 32)).Hex()
+		swapData.Amount = GetBigInt(encData, 32, 32)
 	case bytes.Equal(funcHash, transferFromFuncHash):
-		receiver = common.BytesToAddress(GetData(input, 36, 32)).Hex()
+		if len(encData) != 96 {
+			return "", tokens.ErrTxIncompatible
+		}
+		swapData.User = common.BytesToAddress(GetData(encData, 0, 32))
+		receiver = common.BytesToAddress(GetData(encData, 32, 32)).Hex()
+		swapData.Amount = GetBigInt(encData, 64, 32)
 	default:
-		return tokens.ErrTxFuncHashMismatch
+		return "", tokens.ErrTxFuncHashMismatch
 	}
-	if !strings.EqualFold(receiver, depositAddress) {
+	return receiver, nil
+}
+
+func parseErc20SwapinTxInput(input []byte, depositAddresses []string, swapData *SwapEvent) error {
+	receiver, err := parseErc20TransferInput(input, swapData)
+	if err != nil {
+		return err
+	}
+	if !params.ContainsAddress(depositAddresses, receiver) {
 		return tokens.ErrTxWithWrongReceiver
 	}
+	swapData.MPCAddress = receiver
 	return nil
 }
 
-func (scanner *ethSwapScanner) parseErc20SwapinTxLogs(logs []*types.Log, tokenCfg *params.TokenConfig, swapData *SwapEvent) (err error) {
-	targetContract := tokenCfg.TokenAddress
-	depositAddress := tokenCfg.DepositAddress
-	cmpLogTopic := transferLogTopic
-
+// Transfer(address indexed from, address indexed to, uint256 value)
+func parseErc20SwapinTxLogs(logs []*types.Log, targetContract string, depositAddresses []string, swapData *SwapEvent) (err error) {
+	transferLogExist := false
 	for _, rlog := range logs {
 		if rlog.Removed {
 			continue
@@ -595,38 +1006,35 @@ func (scanner *ethSwapScanner) parseErc20SwapinTxLogs(logs []*types.Log, tokenCf
 		if len(rlog.Topics) != 3 || rlog.Data == nil {
 			continue
 		}
-		if rlog.Topics[0] == cmpLogTopic {
-			receiver := common.BytesToAddress(rlog.Topics[2][:]).Hex()
-			if strings.EqualFold(receiver, depositAddress) {
-				return nil
-			}
-			return tokens.ErrTxWithWrongReceiver
+		if rlog.Topics[0] != transferLogTopic {
+			continue
 		}
+		transferLogExist = true
+		receiver := common.BytesToAddress(rlog.Topics[2][:]).Hex()
+		if !params.ContainsAddress(depositAddresses, receiver) {
+			continue
+		}
+		swapData.MPCAddress = receiver
+		swapData.User = common.BytesToAddress(rlog.Topics[1][:])
+		swapData.Amount = GetBigInt(rlog.Data, 0, 32)
+		return nil
+	}
+	if transferLogExist {
+		return tokens.ErrTxWithWrongReceiver
 	}
 	return tokens.ErrDepositLogNotFound
 }
 
-func (scanner *ethSwapScanner) parseErc20RedeemTxInput(input []byte, depositAddress string, swapData *SwapEvent) error {
-	if len(input) < 4 {
-		return tokens.ErrTxWithWrongInput
-	}
-	var receiver string
-	funcHash := input[:4]
-	switch {
-	case bytes.Equal(funcHash, transferFuncHash):
-		receiver = common.BytesToAddress(GetData(input, 4, 32)).Hex()
-	case bytes.Equal(funcHash, transferFromFuncHash):
-		receiver = common.BytesToAddress(GetData(input, 36, 32)).Hex()
-	default:
-		return tokens.ErrTxFuncHashMismatch
+func parseErc20RedeemTxInput(input []byte, swapData *SwapEvent) error {
+	receiver, err := parseErc20TransferInput(input, swapData)
+	if err != nil {
+		return err
 	}
 	swapData.User = common.HexToAddress(receiver)
 	return nil
 }
 
-func (scanner *ethSwapScanner) parseErc20RedeemTxLogs(logs []*types.Log, tokenCfg *params.TokenConfig, swapData *SwapEvent) (err error) {
-	targetContract := tokenCfg.TokenAddress
-
+func parseErc20RedeemTxLogs(logs []*types.Log, targetContract, redeemAddress string, swapData *SwapEvent) (err error) {
 	for _, rlog := range logs {
 		if rlog.Removed {
 			continue
@@ -637,26 +1045,46 @@ func (scanner *ethSwapScanner) parseErc20RedeemTxLogs(logs []*types.Log, tokenCf
 		if len(rlog.Topics) != 3 || rlog.Data == nil {
 			continue
 		}
-		if rlog.Topics[0] == transferLogTopic {
-			receiver := common.BytesToAddress(rlog.Topics[2][:]).Hex()
-			swapData.User = common.HexToAddress(receiver)
+		if rlog.Topics[0] != transferLogTopic {
+			continue
 		}
+		sender := common.BytesToAddress(rlog.Topics[1][:]).Hex()
+		if !strings.EqualFold(sender, redeemAddress) {
+			continue
+		}
+		swapData.User = common.BytesToAddress(rlog.Topics[2][:])
+		swapData.Amount = GetBigInt(rlog.Data, 0, 32)
+		return nil
 	}
 	return tokens.ErrDepositLogNotFound
 }
 
-func (scanner *ethSwapScanner) parseSwapoutTxInput(input []byte, swapData *SwapEvent) error {
+// Swapout(uint256 amount, address bindaddr)
+// Swapout(uint256 amount, string bindaddr)
+func parseSwapoutTxInput(input []byte, swapData *SwapEvent) (err error) {
 	if len(input) < 4 {
 		return tokens.ErrTxWithWrongInput
 	}
 	funcHash := input[:4]
-	if bytes.Equal(funcHash, addressSwapoutFuncHash) || bytes.Equal(funcHash, stringSwapoutFuncHash) {
+	encData := input[4:]
+	switch {
+	case bytes.Equal(funcHash, addressSwapoutFuncHash):
+		if len(encData) != 64 {
+			return tokens.ErrTxIncompatible
+		}
+		swapData.Amount = GetBigInt(encData, 0, 32)
+		swapData.Bind = common.BytesToAddress(GetData(encData, 32, 32)).Hex()
 		return nil
+	case bytes.Equal(funcHash, stringSwapoutFuncHash):
+		swapData.Bind, swapData.Amount, err = parseStringSwapoutEncodedData(encData, tokens.ErrTxWithWrongInput)
+		return err
 	}
 	return tokens.ErrTxFuncHashMismatch
 }
 
-func (scanner *ethSwapScanner) parseSwapoutTxLogs(logs []*types.Log, tokenCfg *params.TokenConfig, swapData *SwapEvent) (err error) {
+// LogSwapout(address indexed account, address indexed bindaddr, uint256 amount)
+// LogSwapout(address indexed account, string bindaddr, uint256 amount)
+func parseSwapoutTxLogs(logs []*types.Log, tokenCfg *params.TokenConfig, swapData *SwapEvent) (err error) {
 	targetContract := tokenCfg.TokenAddress
 
 	for _, rlog := range logs {
@@ -666,28 +1094,66 @@ func (scanner *ethSwapScanner) parseSwapoutTxLogs(logs []*types.Log, tokenCfg *p
 		if !strings.EqualFold(rlog.Address.Hex(), targetContract) {
 			continue
 		}
-		if len(rlog.Topics) != 2 || rlog.Data == nil {
+		if len(rlog.Topics) < 2 || rlog.Data == nil {
 			continue
 		}
-		if rlog.Topics[0] == addressSwapoutLogTopic || rlog.Topics[0] == stringSwapoutLogTopic {
+		switch {
+		case rlog.Topics[0] == addressSwapoutLogTopic && len(rlog.Topics) == 3:
+			swapData.User = common.BytesToAddress(rlog.Topics[1][:])
+			swapData.Bind = common.BytesToAddress(rlog.Topics[2][:]).Hex()
+			swapData.Amount = GetBigInt(rlog.Data, 0, 32)
 			return nil
+		case rlog.Topics[0] == stringSwapoutLogTopic && len(rlog.Topics) == 2:
+			swapData.User = common.BytesToAddress(rlog.Topics[1][:])
+			swapData.Bind, swapData.Amount, err = parseStringSwapoutEncodedData(rlog.Data, tokens.ErrTxWithWrongLogData)
+			return err
 		}
 	}
 	return tokens.ErrSwapoutLogNotFound
 }
 
-func (scanner *ethSwapScanner) parseMintTxInput(input []byte, swapData *SwapEvent) error {
+// abi encoded (uint256 amount, string bindaddr)
+func parseStringSwapoutEncodedData(encData []byte, wrongDataErr error) (bind string, amount *big.Int, err error) {
+	encDataLength := uint64(len(encData))
+	if encDataLength < 96 || encDataLength%32 != 0 {
+		return "", nil, wrongDataErr
+	}
+	amount = GetBigInt(encData, 0, 32)
+	// compare with the remaining length, the sum of offset and length may wrap around
+	offset, overflow := GetUint64(encData, 32, 32)
+	if overflow || offset > encDataLength-32 {
+		return "", nil, wrongDataErr
+	}
+	remain := encDataLength - offset - 32
+	length, overflow := GetUint64(encData, offset, 32)
+	if overflow || length > remain || remain-length >= 32 {
+		return "", nil, wrongDataErr
+	}
+	bind = string(GetData(encData, offset+32, length))
+	return bind, amount, nil
+}
+
+// Swapin(bytes32 txhash, address account, uint256 amount)
+func parseMintTxInput(input []byte, swapData *SwapEvent) error {
 	if len(input) < 4 {
 		return tokens.ErrTxWithWrongInput
 	}
 	funcHash := input[:4]
-	if bytes.Equal(funcHash, swapinFuncHash) {
-		return nil
+	if !bytes.Equal(funcHash, swapinFuncHash) {
+		return tokens.ErrTxFuncHashMismatch
 	}
-	return tokens.ErrTxFuncHashMismatch
+	encData := input[4:]
+	if len(encData) != 96 {
+		return tokens.ErrTxIncompatible
+	}
+	swapData.RefTxHash = common.BytesToHash(GetData(encData, 0, 32))
+	swapData.User = common.BytesToAddress(GetData(encData, 32, 32))
+	swapData.Amount = GetBigInt(encData, 64, 32)
+	return nil
 }
 
-func (scanner *ethSwapScanner) parseMintTxLogs(logs []*types.Log, tokenCfg *params.TokenConfig, swapData *SwapEvent) (err error) {
+// LogSwapin(bytes32 indexed txhash, address indexed account, uint256 amount)
+func parseMintTxLogs(logs []*types.Log, tokenCfg *params.TokenConfig, swapData *SwapEvent) (err error) {
 	targetContract := tokenCfg.TokenAddress
 
 	for _, rlog := range logs {
@@ -697,33 +1163,53 @@ func (scanner *ethSwapScanner) parseMintTxLogs(logs []*types.Log, tokenCfg *para
 		if !strings.EqualFold(rlog.Address.Hex(), targetContract) {
 			continue
 		}
-		if len(rlog.Topics) != 2 || rlog.Data == nil {
+		if len(rlog.Topics) != 3 || rlog.Data == nil {
 			continue
 		}
 		if rlog.Topics[0] == swapinLogTopic {
+			swapData.RefTxHash = rlog.Topics[1]
+			swapData.User = common.BytesToAddress(rlog.Topics[2][:])
+			swapData.Amount = GetBigInt(rlog.Data, 0, 32)
 			return nil
 		}
 	}
-	return tokens.ErrSwapoutLogNotFound
+	return errSwapinLogNotFound
 }
 
 type cachedSacnnedBlocks struct {
 	capacity  int
 	nextIndex int
 	hashes    []string
+	progress  map[string]int // next tx index of partially processed blocks
 }
 
-var cachedBlocks = &cachedSacnnedBlocks{
-	capacity:  100,
-	nextIndex: 0,
-	hashes:    make([]string, 100),
+func newCachedScannedBlocks(capacity int) *cachedSacnnedBlocks {
+	return &cachedSacnnedBlocks{
+		capacity:  capacity,
+		nextIndex: 0,
+		hashes:    make([]string, capacity),
+		progress:  make(map[string]int),
+	}
 }
 
 func (cache *cachedSacnnedBlocks) addBlock(blockHash string) {
+	delete(cache.progress, blockHash)
 	cache.hashes[cache.nextIndex] = blockHash
 	cache.nextIndex = (cache.nextIndex + 1) % cache.capacity
 }
 
+func (cache *cachedSacnnedBlocks) setProgress(blockHash string, txIndex int) {
+	if len(cache.progress) >= cache.capacity {
+		cache.progress = make(map[string]int)
+	}
+	cache.progress[blockHash] = txIndex
+}
+
+func (cache *cachedSacnnedBlocks) getProgress(blockHash string) (txIndex int, exist bool) {
+	txIndex, exist = cache.progress[blockHash]
+	return txIndex, exist
+}
+
 func (cache *cachedSacnnedBlocks) isScanned(blockHash string) bool {
 	for _, b := range cache.hashes {
 		if b == blockHash {
@@ -733,14 +1219,6 @@ func (cache *cachedSacnnedBlocks) isScanned(blockHash string) bool {
 	return false
 }
 
-func (scanner *ethSwapScanner) getBlockTimestamp(blockNumber *big.Int) int64 {
-	header, err := scanner.client.HeaderByNumber(context.Background(), blockNumber)
-	if err != nil {
-		return 0
-	}
-	return int64(header.Time)
-}
-
 func GetData(data []byte, start uint64, size uint64) []byte {
 	length := uint64(len(data))
 	if start > length {
@@ -751,4 +1229,14 @@ func GetData(data []byte, start uint64, size uint64) []byte {
 		end = length
 	}
 	return common.RightPadBytes(data[start:end], int(size))
-}
\ No newline at end of file
+}
+
+func GetBigInt(data []byte, start, size uint64) *big.Int {
+	return new(big.Int).SetBytes(GetData(data, start, size))
+}
+
+// GetUint64 get uint64 from data, return overflow if it exceeds uint64
+func GetUint64(data []byte, start, size uint64) (uint64, bool) {
+	val := GetBigInt(data, start, size)
+	return val.Uint64(), !val.IsUint64()
+}
@@ -0,0 +1,478 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/anyswap/CrossChain-Bridge/tokens"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+const (
+	testTokenAddress   = "0x13b2f6928d7204328b0e8e4bcd0379aa06ea21fa"
+	testDepositAddress = "0xc564ee9f21ed8a2d8e7e76c085740d5e4c5fafbe"
+	testUserAddress    = "0x7f3f0b2ad3f5e5c1f2e2a1e3f6b0c4d88e5b9a01"
+	testRefTxHash      = "0x9d2b2f0c8e6a1a0c4b4bfc5b3a6f1b0e3d5e2c7a8f9b1c2d3e4f5a6b7c8d9e0f"
+
+	// Swapout(uint256 amount, string bindaddr) of 2.5e18 to bc1qgxl2xyqskyjuxtzrx8hz6ehe6ngv3qgmwqwhzj
+	testStringSwapoutData = "00000000000000000000000000000000000000000000000022b1c8c1227a0000" +
+		"0000000000000000000000000000000000000000000000000000000000000040" +
+		"000000000000000000000000000000000000000000000000000000000000002a" +
+		"6263317167786c32787971736b796a7578747a727838687a36656865366e6776" +
+		"3371676d777177687a6a00000000000000000000000000000000000000000000"
+	testStringSwapoutBind = "bc1qgxl2xyqskyjuxtzrx8hz6ehe6ngv3qgmwqwhzj"
+)
+
+var testAmount, _ = new(big.Int).SetString("2500000000000000000", 10)
+
+func testWord(hexStr string) string {
+	return common.Bytes2Hex(common.LeftPadBytes(common.FromHex(hexStr), 32))
+}
+
+func testAmountWord() string {
+	return common.Bytes2Hex(common.LeftPadBytes(testAmount.Bytes(), 32))
+}
+
+func testTopic(hexStr string) common.Hash {
+	return common.BytesToHash(common.FromHex(hexStr))
+}
+
+func testTokenConfig() *params.TokenConfig {
+	return &params.TokenConfig{
+		PairID:         "btc",
+		TokenAddress:   testTokenAddress,
+		DepositAddress: testDepositAddress,
+	}
+}
+
+func checkSwapData(t *testing.T, swapData *SwapEvent, user, bind string) {
+	t.Helper()
+	if swapData.Amount == nil || swapData.Amount.Cmp(testAmount) != 0 {
+		t.Errorf("amount mismatch, have %v want %v", swapData.Amount, testAmount)
+	}
+	if user != "" && !strings.EqualFold(swapData.User.Hex(), user) {
+		t.Errorf("user mismatch, have %v want %v", swapData.User.Hex(), user)
+	}
+	if bind != "" && !strings.EqualFold(swapData.Bind, bind) {
+		t.Errorf("bind mismatch, have %v want %v", swapData.Bind, bind)
+	}
+}
+
+func TestParseErc20TransferInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		receiver string
+		user     string
+		err      error
+	}{
+		{
+			name:     "transfer",
+			input:    "a9059cbb" + testWord(testDepositAddress) + testAmountWord(),
+			receiver: testDepositAddress,
+		},
+		{
+			name:     "transferFrom",
+			input:    "23b872dd" + testWord(testUserAddress) + testWord(testDepositAddress) + testAmountWord(),
+			receiver: testDepositAddress,
+			user:     testUserAddress,
+		},
+		{
+			name:  "short input",
+			input: "a9059c",
+			err:   tokens.ErrTxWithWrongInput,
+		},
+		{
+			name:  "transfer with wrong length",
+			input: "a9059cbb" + testWord(testDepositAddress),
+			err:   tokens.ErrTxIncompatible,
+		},
+		{
+			name:  "transferFrom with wrong length",
+			input: "23b872dd" + testWord(testUserAddress) + testWord(testDepositAddress),
+			err:   tokens.ErrTxIncompatible,
+		},
+		{
+			name:  "approve",
+			input: "095ea7b3" + testWord(testDepositAddress) + testAmountWord(),
+			err:   tokens.ErrTxFuncHashMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			swapData := &SwapEvent{}
+			receiver, err := parseErc20TransferInput(common.FromHex(tt.input), swapData)
+			if err != tt.err {
+				t.Fatalf("error mismatch, have %v want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if !strings.EqualFold(receiver, tt.receiver) {
+				t.Errorf("receiver mismatch, have %v want %v", receiver, tt.receiver)
+			}
+			checkSwapData(t, swapData, tt.user, "")
+		})
+	}
+}
+
+func TestParseStringSwapoutEncodedData(t *testing.T) {
+	wrongDataErr := tokens.ErrTxWithWrongLogData
+	tests := []struct {
+		name string
+		data string
+		bind string
+		err  error
+	}{
+		{
+			name: "valid",
+			data: testStringSwapoutData,
+			bind: testStringSwapoutBind,
+		},
+		{
+			name: "too short",
+			data: testStringSwapoutData[:128],
+			err:  wrongDataErr,
+		},
+		{
+			name: "not aligned",
+			data: testStringSwapoutData + "00",
+			err:  wrongDataErr,
+		},
+		{
+			name: "offset out of range",
+			data: testAmountWord() + testWord("0x0400") + testStringSwapoutData[128:],
+			err:  wrongDataErr,
+		},
+		{
+			name: "offset overflow",
+			data: testAmountWord() + strings.Repeat("ff", 32) + testStringSwapoutData[128:],
+			err:  wrongDataErr,
+		},
+		{
+			name: "length out of range",
+			data: testStringSwapoutData[:128] + testWord("0x80") + testStringSwapoutData[192:],
+			err:  wrongDataErr,
+		},
+		{
+			name: "offset wrap around",
+			data: testAmountWord() + testWord("0xfffffffffffffff0") + testStringSwapoutData[128:],
+			err:  wrongDataErr,
+		},
+		{
+			name: "length wrap around",
+			data: testStringSwapoutData[:128] + testWord("0xffffffffffffffff"),
+			err:  wrongDataErr,
+		},
+		{
+			name: "trailing data",
+			data: testStringSwapoutData + testWord("0x00"),
+			err:  wrongDataErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bind, amount, err := parseStringSwapoutEncodedData(common.FromHex(tt.data), wrongDataErr)
+			if err != tt.err {
+				t.Fatalf("error mismatch, have %v want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if bind != tt.bind {
+				t.Errorf("bind mismatch, have %v want %v", bind, tt.bind)
+			}
+			if amount.Cmp(testAmount) != 0 {
+				t.Errorf("amount mismatch, have %v want %v", amount, testAmount)
+			}
+		})
+	}
+}
+
+func TestParseMintTxLogs(t *testing.T) {
+	swapinLog := func() *types.Log {
+		return &types.Log{
+			Address: common.HexToAddress(testTokenAddress),
+			Topics: []common.Hash{
+				swapinLogTopic,
+				common.HexToHash(testRefTxHash),
+				testTopic(testUserAddress),
+			},
+			Data: common.FromHex(testAmountWord()),
+		}
+	}
+	tests := []struct {
+		name string
+		logs func() []*types.Log
+		err  error
+	}{
+		{
+			name: "swapin",
+			logs: func() []*types.Log { return []*types.Log{swapinLog()} },
+		},
+		{
+			name: "swapin after transfer",
+			logs: func() []*types.Log {
+				transfer := &types.Log{
+					Address: common.HexToAddress(testTokenAddress),
+					Topics:  []common.Hash{transferLogTopic, {}, testTopic(testUserAddress)},
+					Data:    common.FromHex(testAmountWord()),
+				}
+				return []*types.Log{transfer, swapinLog()}
+			},
+		},
+		{
+			name: "removed",
+			logs: func() []*types.Log {
+				rlog := swapinLog()
+				rlog.Removed = true
+				return []*types.Log{rlog}
+			},
+			err: errSwapinLogNotFound,
+		},
+		{
+			name: "other contract",
+			logs: func() []*types.Log {
+				rlog := swapinLog()
+				rlog.Address = common.HexToAddress(testUserAddress)
+				return []*types.Log{rlog}
+			},
+			err: errSwapinLogNotFound,
+		},
+		{
+			name: "wrong topics",
+			logs: func() []*types.Log {
+				rlog := swapinLog()
+				rlog.Topics = rlog.Topics[:2]
+				return []*types.Log{rlog}
+			},
+			err: errSwapinLogNotFound,
+		},
+		{
+			name: "no logs",
+			logs: func() []*types.Log { return nil },
+			err:  errSwapinLogNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			swapData := &SwapEvent{}
+			err := parseMintTxLogs(tt.logs(), testTokenConfig(), swapData)
+			if err != tt.err {
+				t.Fatalf("error mismatch, have %v want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			checkSwapData(t, swapData, testUserAddress, "")
+			if swapData.RefTxHash != common.HexToHash(testRefTxHash) {
+				t.Errorf("ref tx hash mismatch, have %v want %v", swapData.RefTxHash.Hex(), testRefTxHash)
+			}
+		})
+	}
+}
+
+func TestParseSwapoutTxLogs(t *testing.T) {
+	bindAddress := "0x2e5b1f2c7e1b4b3a0f4c6d9e8a7b6c5d4e3f2a1b"
+	tests := []struct {
+		name string
+		log  *types.Log
+		bind string
+		err  error
+	}{
+		{
+			name: "address swapout",
+			log: &types.Log{
+				Address: common.HexToAddress(testTokenAddress),
+				Topics:  []common.Hash{addressSwapoutLogTopic, testTopic(testUserAddress), testTopic(bindAddress)},
+				Data:    common.FromHex(testAmountWord()),
+			},
+			bind: bindAddress,
+		},
+		{
+			name: "string swapout",
+			log: &types.Log{
+				Address: common.HexToAddress(testTokenAddress),
+				Topics:  []common.Hash{stringSwapoutLogTopic, testTopic(testUserAddress)},
+				Data:    common.FromHex(testStringSwapoutData),
+			},
+			bind: testStringSwapoutBind,
+		},
+		{
+			name: "string swapout with wrong data",
+			log: &types.Log{
+				Address: common.HexToAddress(testTokenAddress),
+				Topics:  []common.Hash{stringSwapoutLogTopic, testTopic(testUserAddress)},
+				Data:    common.FromHex(testStringSwapoutData[:128]),
+			},
+			err: tokens.ErrTxWithWrongLogData,
+		},
+		{
+			name: "address swapout with wrong topics",
+			log: &types.Log{
+				Address: common.HexToAddress(testTokenAddress),
+				Topics:  []common.Hash{addressSwapoutLogTopic, testTopic(testUserAddress)},
+				Data:    common.FromHex(testAmountWord()),
+			},
+			err: tokens.ErrSwapoutLogNotFound,
+		},
+		{
+			name: "other contract",
+			log: &types.Log{
+				Address: common.HexToAddress(testDepositAddress),
+				Topics:  []common.Hash{addressSwapoutLogTopic, testTopic(testUserAddress), testTopic(bindAddress)},
+				Data:    common.FromHex(testAmountWord()),
+			},
+			err: tokens.ErrSwapoutLogNotFound,
+		},
+		{
+			name: "removed",
+			log: &types.Log{
+				Address: common.HexToAddress(testTokenAddress),
+				Topics:  []common.Hash{addressSwapoutLogTopic, testTopic(testUserAddress), testTopic(bindAddress)},
+				Data:    common.FromHex(testAmountWord()),
+				Removed: true,
+			},
+			err: tokens.ErrSwapoutLogNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			swapData := &SwapEvent{}
+			err := parseSwapoutTxLogs([]*types.Log{tt.log}, testTokenConfig(), swapData)
+			if err != tt.err {
+				t.Fatalf("error mismatch, have %v want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			checkSwapData(t, swapData, testUserAddress, tt.bind)
+		})
+	}
+}
+
+func TestParseTransferTxLogs(t *testing.T) {
+	transferLog := func(from, to string) *types.Log {
+		return &types.Log{
+			Address: common.HexToAddress(testTokenAddress),
+			Topics:  []common.Hash{transferLogTopic, testTopic(from), testTopic(to)},
+			Data:    common.FromHex(testAmountWord()),
+		}
+	}
+	otherAddress := "0x0000000000000000000000000000000000000001"
+	depositAddresses := []string{testDepositAddress}
+
+	swapinTests := []struct {
+		name string
+		logs []*types.Log
+		err  error
+	}{
+		{
+			name: "transfer to deposit address",
+			logs: []*types.Log{transferLog(testUserAddress, testDepositAddress)},
+		},
+		{
+			name: "second transfer to deposit address",
+			logs: []*types.Log{
+				transferLog(testUserAddress, otherAddress),
+				transferLog(testUserAddress, testDepositAddress),
+			},
+		},
+		{
+			name: "transfer to other address",
+			logs: []*types.Log{transferLog(testUserAddress, otherAddress)},
+			err:  tokens.ErrTxWithWrongReceiver,
+		},
+		{
+			name: "no transfer",
+			logs: nil,
+			err:  tokens.ErrDepositLogNotFound,
+		},
+	}
+	for _, tt := range swapinTests {
+		t.Run("swapin "+tt.name, func(t *testing.T) {
+			swapData := &SwapEvent{}
+			err := parseErc20SwapinTxLogs(tt.logs, testTokenAddress, depositAddresses, swapData)
+			if err != tt.err {
+				t.Fatalf("error mismatch, have %v want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			checkSwapData(t, swapData, testUserAddress, "")
+			if !strings.EqualFold(swapData.MPCAddress, testDepositAddress) {
+				t.Errorf("mpc address mismatch, have %v want %v", swapData.MPCAddress, testDepositAddress)
+			}
+		})
+	}
+
+	redeemTests := []struct {
+		name string
+		logs []*types.Log
+		err  error
+	}{
+		{
+			name: "transfer from redeem address",
+			logs: []*types.Log{transferLog(testDepositAddress, testUserAddress)},
+		},
+		{
+			name: "transfer from other address",
+			logs: []*types.Log{transferLog(otherAddress, testUserAddress)},
+			err:  tokens.ErrDepositLogNotFound,
+		},
+	}
+	for _, tt := range redeemTests {
+		t.Run("redeem "+tt.name, func(t *testing.T) {
+			swapData := &SwapEvent{}
+			err := parseErc20RedeemTxLogs(tt.logs, testTokenAddress, testDepositAddress, swapData)
+			if err != tt.err {
+				t.Fatalf("error mismatch, have %v want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			checkSwapData(t, swapData, testUserAddress, "")
+		})
+	}
+}
+
+// block source whose receipts are not available
+type noReceiptSource struct {
+	blockSource
+}
+
+func (noReceiptSource) TransactionReceipt(context.Context, common.Hash) (*types.Receipt, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestReceiptErrorIsPropagated(t *testing.T) {
+	scanner := &ethSwapScanner{chain: "test", offline: noReceiptSource{}, scanReceipt: true}
+	header := &types.Header{Number: big.NewInt(100), Time: 1600000000}
+
+	t.Run("scan receipt", func(t *testing.T) {
+		tx := types.NewTransaction(0, common.HexToAddress(testTokenAddress), big.NewInt(0), 100000, big.NewInt(1), nil)
+		if err := scanner.scanTransaction(header, tx, nil); !errors.Is(err, errGetTxReceipt) {
+			t.Errorf("error mismatch, have %v want %v", err, errGetTxReceipt)
+		}
+	})
+	t.Run("router", func(t *testing.T) {
+		tokenCfg := &params.TokenConfig{TokenType: params.TokenTypeRouter, RouterContract: testRouterAddress, TokenAddress: testTokenAddress}
+		tx := types.NewTransaction(0, common.HexToAddress(testRouterAddress), big.NewInt(0), 100000, big.NewInt(1), nil)
+		if _, err := scanner.verifyRouterTransaction(tx, nil, header, tokenCfg); !errors.Is(err, errGetTxReceipt) {
+			t.Errorf("error mismatch, have %v want %v", err, errGetTxReceipt)
+		}
+	})
+	t.Run("abi", func(t *testing.T) {
+		tokenCfg := testABITokenConfig(t)
+		tx := types.NewTransaction(0, common.HexToAddress(testTokenAddress), big.NewInt(0), 100000, big.NewInt(1), nil)
+		if _, _, err := scanner.verifyABITransaction(tx, common.HexToAddress(testDepositAddress), nil, header, tokenCfg); !errors.Is(err, errGetTxReceipt) {
+			t.Errorf("error mismatch, have %v want %v", err, errGetTxReceipt)
+		}
+	})
+}
@@ -0,0 +1,208 @@
+package scanner
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+)
+
+// max retry times of a failed block before it is recorded as block gap
+const maxBlockRetries = 3
+
+// blockWorker scan blocks with its own process block timer
+type blockWorker struct {
+	id    uint64
+	timer *time.Timer
+}
+
+func newBlockWorker(id uint64, timeout time.Duration) *blockWorker {
+	return &blockWorker{
+		id:    id,
+		timer: time.NewTimer(timeout),
+	}
+}
+
+// scanTask scan block from the tx index, if it is still the block of hash
+type scanTask struct {
+	height    uint64
+	txIndex   int
+	blockHash string
+	retries   int
+}
+
+// blockScheduler dispatch heights in range [start, end) to a pool of workers.
+// workers pull the next height from the queue, the failed heights are retried
+// in the retry queue, and the completed heights are tracked in order,
+// so that all heights below the watermark are completed.
+type blockScheduler struct {
+	scanner *ethSwapScanner
+	start   uint64
+	end     uint64
+
+	lock      sync.Mutex
+	next      uint64 // next height to dispatch
+	retries   []*scanTask
+	completed map[uint64]struct{} // completed heights above the watermark
+	watermark uint64              // all heights below it are completed
+
+	// called with the new watermark when it advances, in order
+	onProgress func(watermark uint64)
+
+	// record the unfinished heights as block gaps if the scanner is stopped
+	gapsOnStop bool
+}
+
+func newBlockScheduler(scanner *ethSwapScanner, start, end uint64) *blockScheduler {
+	return &blockScheduler{
+		scanner:   scanner,
+		start:     start,
+		end:       end,
+		next:      start,
+		completed: make(map[uint64]struct{}),
+		watermark: start,
+	}
+}
+
+// run workers and wait them finished or the scanner stopped
+func (s *blockScheduler) run() {
+	scanner := s.scanner
+	jobs := scanner.jobCount
+	if count := s.end - s.start; count < jobs {
+		jobs = count
+	}
+	log.Info("start scan range job", "chain", scanner.chain, "start", s.start, "end", s.end, "jobs", jobs)
+	wg := new(sync.WaitGroup)
+	for i := uint64(1); i <= jobs; i++ {
+		id := i
+		wg.Add(1)
+		scanner.goJob(func() {
+			defer wg.Done()
+			s.runWorker(id)
+		})
+	}
+	wg.Wait()
+	log.Info("scan range job finished", "chain", scanner.chain, "start", s.start, "end", s.end, "watermark", s.getWatermark())
+	if s.gapsOnStop && scanner.isStopped() {
+		s.recordUnfinished()
+	}
+}
+
+func (s *blockScheduler) runWorker(id uint64) {
+	scanner := s.scanner
+	worker := newBlockWorker(id, scanner.processBlockTimeout)
+	defer worker.timer.Stop()
+
+	for {
+		if scanner.isStopped() {
+			log.Info(fmt.Sprintf("[%v] scan range stopped", id), "chain", scanner.chain, "watermark", s.getWatermark())
+			return
+		}
+		task := s.nextTask()
+		if task == nil {
+			return
+		}
+		if task.retries > 0 {
+			time.Sleep(scanner.rpcInterval)
+		}
+		next, blockHash, err := scanner.scanBlockFrom(worker, task.height, task.txIndex, task.blockHash, false)
+		switch {
+		case err == nil:
+			s.complete(task.height)
+		case task.retries < maxBlockRetries:
+			log.Warn(fmt.Sprintf("[%v] retry scan block %v", id, task.height), "chain", scanner.chain, "txIndex", next, "retries", task.retries+1, "err", err)
+			s.retry(&scanTask{height: task.height, txIndex: next, blockHash: blockHash, retries: task.retries + 1})
+		default:
+			// heal later by the gap auditor, the dry run leaves no gap
+			if scanner.dryRun {
+				log.Warn(fmt.Sprintf("[%v] skip failed block %v in dry run", id, task.height), "chain", scanner.chain, "txIndex", next, "err", err)
+			} else {
+				scanner.recordGap(task.height, task.height+1, next, blockHash, err.Error())
+			}
+			s.complete(task.height)
+		}
+	}
+}
+
+// retry tasks first, return nil if there is no more task
+func (s *blockScheduler) nextTask() *scanTask {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.retries) > 0 {
+		task := s.retries[0]
+		s.retries = s.retries[1:]
+		return task
+	}
+	if s.next < s.end {
+		task := &scanTask{height: s.next}
+		s.next++
+		return task
+	}
+	return nil
+}
+
+func (s *blockScheduler) retry(task *scanTask) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.retries = append(s.retries, task)
+}
+
+func (s *blockScheduler) complete(height uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.completed[height] = struct{}{}
+	advanced := false
+	for {
+		if _, exist := s.completed[s.watermark]; !exist {
+			break
+		}
+		delete(s.completed, s.watermark)
+		s.watermark++
+		advanced = true
+	}
+	if advanced && s.onProgress != nil {
+		s.onProgress(s.watermark)
+	}
+}
+
+func (s *blockScheduler) getWatermark() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.watermark
+}
+
+// record the heights in [watermark, end) which are not completed as block gaps,
+// the partially processed blocks of the retry queue are resumed from their tx index
+func (s *blockScheduler) recordUnfinished() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	scanner := s.scanner
+	retries := make(map[uint64]*scanTask, len(s.retries))
+	for _, task := range s.retries {
+		retries[task.height] = task
+	}
+	const reason = "unfinished when stopped"
+	gapStart, inGap := uint64(0), false
+	flush := func(end uint64) {
+		if inGap {
+			scanner.recordGap(gapStart, end, 0, "", reason)
+			inGap = false
+		}
+	}
+	for h := s.watermark; h < s.end; h++ {
+		if _, exist := s.completed[h]; exist {
+			flush(h)
+			continue
+		}
+		if task, exist := retries[h]; exist && task.txIndex > 0 {
+			flush(h)
+			scanner.recordGap(h, h+1, task.txIndex, task.blockHash, reason)
+			continue
+		}
+		if !inGap {
+			gapStart, inGap = h, true
+		}
+	}
+	flush(s.end)
+}
@@ -0,0 +1,135 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+)
+
+func TestBoundSyncedHeight(t *testing.T) {
+	scheduler := newBlockScheduler(&ethSwapScanner{}, 100, 200)
+	tests := []struct {
+		name      string
+		completed []uint64
+		height    uint64
+		synced    uint64
+	}{
+		{name: "nothing completed", height: 250, synced: 99},
+		{name: "partially completed", completed: []uint64{100, 101, 102, 150}, height: 250, synced: 102},
+		{name: "below watermark", height: 101, synced: 101},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, h := range tt.completed {
+				scheduler.complete(h)
+			}
+			if synced := boundSyncedHeight(tt.height, scheduler); synced != tt.synced {
+				t.Errorf("synced height mismatch, have %v want %v", synced, tt.synced)
+			}
+		})
+	}
+	for h := uint64(100); h < 200; h++ {
+		scheduler.complete(h)
+	}
+	if synced := boundSyncedHeight(250, scheduler); synced != 250 {
+		t.Errorf("synced height of finished range mismatch, have %v want %v", synced, 250)
+	}
+	if synced := boundSyncedHeight(250, nil); synced != 250 {
+		t.Errorf("synced height without range job mismatch, have %v want %v", synced, 250)
+	}
+}
+
+func TestRecordUnfinishedHeights(t *testing.T) {
+	db := newMemorySyncAPI()
+	defer func(api mongodb.SyncAPI) { dbAPI = api }(dbAPI)
+	dbAPI = db
+
+	scanner := &ethSwapScanner{chain: "test"}
+	scheduler := newBlockScheduler(scanner, 10, 20)
+	scheduler.gapsOnStop = true
+	for _, h := range []uint64{10, 11, 14, 15} {
+		scheduler.complete(h)
+	}
+	scheduler.next = 17
+	scheduler.retries = []*scanTask{
+		{height: 13, txIndex: 5, blockHash: "0xabc", retries: 1},
+		{height: 16, retries: 1},
+	}
+	scheduler.recordUnfinished()
+
+	gaps, err := db.GetBlockGaps(scanner.chain)
+	if err != nil {
+		t.Fatalf("get block gaps failed: %v", err)
+	}
+	want := []mongodb.BlockGap{
+		{Start: 12, End: 13},
+		{Start: 13, End: 14, TxIndex: 5, BlockHash: "0xabc"},
+		{Start: 16, End: 20},
+	}
+	if len(gaps) != len(want) {
+		t.Fatalf("gaps count mismatch, have %v want %v", len(gaps), len(want))
+	}
+	for i, gap := range gaps {
+		if gap.Start != want[i].Start || gap.End != want[i].End || gap.TxIndex != want[i].TxIndex || gap.BlockHash != want[i].BlockHash {
+			t.Errorf("gap %v mismatch, have %+v want %+v", i, gap, want[i])
+		}
+	}
+}
+
+func TestCheckStartGap(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  uint64
+		dryRun bool
+		gaps   []mongodb.BlockGap
+	}{
+		{name: "resume after synced", start: 101},
+		{name: "missed blocks", start: 150, gaps: []mongodb.BlockGap{{Start: 101, End: 150}}},
+		{name: "missed blocks in dry run", start: 150, dryRun: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newMemorySyncAPI()
+			defer func(api mongodb.SyncAPI) { dbAPI = api }(dbAPI)
+			dbAPI = db
+			if err := db.UpdateSyncedHeight("test", 100); err != nil {
+				t.Fatal(err)
+			}
+
+			scanner := &ethSwapScanner{chain: "test", dryRun: tt.dryRun}
+			scanner.checkStartGap(tt.start)
+			gaps, err := db.GetBlockGaps(scanner.chain)
+			if err != nil {
+				t.Fatalf("get block gaps failed: %v", err)
+			}
+			if len(gaps) != len(tt.gaps) {
+				t.Fatalf("gaps count mismatch, have %v want %v", len(gaps), len(tt.gaps))
+			}
+			for i, gap := range gaps {
+				if gap.Start != tt.gaps[i].Start || gap.End != tt.gaps[i].End {
+					t.Errorf("gap %v mismatch, have %+v want %+v", i, gap, tt.gaps[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckBTCStartGap(t *testing.T) {
+	db := newMemorySyncAPI()
+	defer func(api mongodb.SyncAPI) { dbAPI = api }(dbAPI)
+	dbAPI = db
+	if err := db.UpdateSyncedHeight("btc", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := &btcSwapScanner{chain: "btc"}
+	scanner.checkStartGap(101)
+	scanner.checkStartGap(150)
+	gaps, err := db.GetBlockGaps(scanner.chain)
+	if err != nil {
+		t.Fatalf("get block gaps failed: %v", err)
+	}
+	if len(gaps) != 1 || gaps[0].Start != 101 || gaps[0].End != 150 {
+		t.Errorf("gaps mismatch, have %v want [101, 150)", gaps)
+	}
+}
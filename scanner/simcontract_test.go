@@ -0,0 +1,315 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// evmAssembler minimal evm assembler with jump labels,
+// it is used to build the token contracts of the simulated chain.
+type evmAssembler struct {
+	code   []byte
+	labels map[string]int
+	fixups map[int]string // position of PUSH2 operand -> label
+}
+
+func newEVMAssembler() *evmAssembler {
+	return &evmAssembler{
+		labels: make(map[string]int),
+		fixups: make(map[int]string),
+	}
+}
+
+func (a *evmAssembler) op(ops ...vm.OpCode) *evmAssembler {
+	for _, op := range ops {
+		a.code = append(a.code, byte(op))
+	}
+	return a
+}
+
+// push the data with the shortest PUSH opcode
+func (a *evmAssembler) push(data []byte) *evmAssembler {
+	if len(data) == 0 || len(data) > 32 {
+		panic(fmt.Sprintf("wrong push data length %v", len(data)))
+	}
+	a.code = append(a.code, byte(vm.PUSH1)+byte(len(data)-1))
+	a.code = append(a.code, data...)
+	return a
+}
+
+func (a *evmAssembler) pushUint(value uint64) *evmAssembler {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, value)
+	for len(data) > 1 && data[0] == 0 {
+		data = data[1:]
+	}
+	return a.push(data)
+}
+
+// push the position of label, which is resolved when assemble
+func (a *evmAssembler) pushLabel(label string) *evmAssembler {
+	a.code = append(a.code, byte(vm.PUSH2))
+	a.fixups[len(a.code)] = label
+	a.code = append(a.code, 0, 0)
+	return a
+}
+
+func (a *evmAssembler) label(label string) *evmAssembler {
+	a.labels[label] = len(a.code)
+	return a.op(vm.JUMPDEST)
+}
+
+func (a *evmAssembler) assemble() []byte {
+	code := common.CopyBytes(a.code)
+	for pos, label := range a.fixups {
+		dest, exist := a.labels[label]
+		if !exist {
+			panic(fmt.Sprintf("undefined label %v", label))
+		}
+		binary.BigEndian.PutUint16(code[pos:], uint16(dest))
+	}
+	return code
+}
+
+// symbol and name of the mock token
+const (
+	mockTokenSymbol = "MOCK"
+	mockTokenName   = "Mock Token"
+)
+
+// deploy code which runs the constructor, then copies the runtime code appended after it and returns it
+func evmDeployCode(runtime []byte, constructor ...func(a *evmAssembler)) []byte {
+	init := newEVMAssembler()
+	for _, build := range constructor {
+		build(init)
+	}
+	init.pushLabel("length").op(vm.DUP1).pushLabel("runtime").pushUint(0).op(vm.CODECOPY).
+		pushUint(0).op(vm.RETURN)
+	// the labels are used as constants resolved after the init code is built
+	init.labels["length"] = len(runtime)
+	init.labels["runtime"] = len(init.code)
+	return append(init.assemble(), runtime...)
+}
+
+// symbol and name of the erc20 token
+const (
+	erc20TokenSymbol = "SIM"
+	erc20TokenName   = "Simulated Token"
+)
+
+// storage slots of the erc20 token
+const (
+	erc20BalancesSlot    = 0 // mapping(address => uint256)
+	erc20AllowancesSlot  = 1 // mapping(address => mapping(address => uint256))
+	erc20TotalSupplySlot = 2
+)
+
+var (
+	erc20TotalSupply = new(big.Int).Mul(big.NewInt(1e18), big.NewInt(1e9))
+
+	// Approval(address,address,uint256)
+	approvalLogTopic = common.HexToHash("0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925")
+)
+
+// replace the mapping key on stack top with the storage slot of its value
+func (a *evmAssembler) mappingSlot(slot uint64) *evmAssembler {
+	a.pushUint(0).op(vm.MSTORE)
+	return a.pushUint(slot).pushUint(32).op(vm.MSTORE).pushUint(64).pushUint(0).op(vm.SHA3)
+}
+
+// replace the owner and spender on stack top with the storage slot of allowance
+func (a *evmAssembler) allowanceSlot() *evmAssembler {
+	a.mappingSlot(erc20AllowancesSlot).pushUint(32).op(vm.MSTORE)
+	return a.pushUint(0).op(vm.MSTORE).pushUint(64).pushUint(0).op(vm.SHA3)
+}
+
+func (a *evmAssembler) returnWord() *evmAssembler {
+	a.pushUint(0).op(vm.MSTORE)
+	return a.pushUint(32).pushUint(0).op(vm.RETURN)
+}
+
+// return abi encoded string, which is less than 32 bytes
+func (a *evmAssembler) returnString(str string) *evmAssembler {
+	a.pushUint(32).pushUint(0).op(vm.MSTORE)
+	a.pushUint(uint64(len(str))).pushUint(32).op(vm.MSTORE)
+	a.push(common.RightPadBytes([]byte(str), 32)).pushUint(64).op(vm.MSTORE)
+	return a.pushUint(96).pushUint(0).op(vm.RETURN)
+}
+
+// erc20TokenConstructor mints the total supply to the deployer
+func erc20TokenConstructor(a *evmAssembler) {
+	a.push(erc20TotalSupply.Bytes())
+	a.op(vm.DUP1).pushUint(erc20TotalSupplySlot).op(vm.SSTORE)
+	a.op(vm.DUP1, vm.CALLER).mappingSlot(erc20BalancesSlot).op(vm.SSTORE)
+	a.pushUint(0).op(vm.MSTORE)
+	a.op(vm.CALLER).pushUint(0).push(transferLogTopic.Bytes()).pushUint(32).pushUint(0).op(vm.LOG3)
+}
+
+// erc20TokenCode runtime code of erc20 token, which keeps balances and allowances,
+// and reverts the transfers exceed them.
+//
+//	transfer(address to, uint256 amount)
+//	transferFrom(address from, address to, uint256 amount)
+//	approve(address spender, uint256 amount)
+//	balanceOf(address owner), allowance(address owner, address spender), totalSupply()
+//	decimals() returns 18
+//	symbol() and name() return the erc20 token symbol and name
+func erc20TokenCode() []byte {
+	a := newEVMAssembler()
+	// selector
+	a.pushUint(0).op(vm.CALLDATALOAD).pushUint(0xe0).op(vm.SHR)
+	dispatch := []struct {
+		selector []byte
+		label    string
+	}{
+		{transferFuncHash, "transfer"},
+		{transferFromFuncHash, "transferFrom"},
+		{common.FromHex("0x095ea7b3"), "approve"},
+		{common.FromHex("0x70a08231"), "balanceOf"},
+		{common.FromHex("0xdd62ed3e"), "allowance"},
+		{common.FromHex("0x18160ddd"), "totalSupply"},
+		{common.FromHex("0x313ce567"), "decimals"},
+		{common.FromHex("0x95d89b41"), "symbol"},
+		{common.FromHex("0x06fdde03"), "name"},
+	}
+	for _, item := range dispatch {
+		a.op(vm.DUP1).push(item.selector).op(vm.EQ).pushLabel(item.label).op(vm.JUMPI)
+	}
+	a.label("revert")
+	a.pushUint(0).op(vm.DUP1, vm.REVERT)
+
+	// move amount with stack [from, to, amount] and emit Transfer(from, to, amount)
+	move := func() {
+		// balances[from] -= amount, revert if it is insufficient
+		a.op(vm.DUP1).mappingSlot(erc20BalancesSlot)
+		a.op(vm.DUP1, vm.SLOAD, vm.DUP5, vm.DUP2, vm.DUP2, vm.GT).pushLabel("revert").op(vm.JUMPI)
+		a.op(vm.SWAP1, vm.SUB, vm.SWAP1, vm.SSTORE)
+		// balances[to] += amount
+		a.op(vm.DUP2).mappingSlot(erc20BalancesSlot)
+		a.op(vm.DUP1, vm.SLOAD, vm.DUP5, vm.ADD, vm.SWAP1, vm.SSTORE)
+		a.op(vm.DUP3).pushUint(0).op(vm.MSTORE)
+		a.push(transferLogTopic.Bytes()).pushUint(32).pushUint(0).op(vm.LOG3, vm.POP)
+		a.pushUint(1).returnWord()
+	}
+
+	a.label("transfer")
+	a.pushUint(36).op(vm.CALLDATALOAD).pushUint(4).op(vm.CALLDATALOAD, vm.CALLER)
+	move()
+
+	a.label("transferFrom")
+	// allowances[from][caller] -= amount, revert if it is insufficient
+	a.op(vm.CALLER).pushUint(4).op(vm.CALLDATALOAD).allowanceSlot()
+	a.op(vm.DUP1, vm.SLOAD).pushUint(68).op(vm.CALLDATALOAD, vm.DUP2, vm.DUP2, vm.GT).pushLabel("revert").op(vm.JUMPI)
+	a.op(vm.SWAP1, vm.SUB, vm.SWAP1, vm.SSTORE)
+	a.pushUint(68).op(vm.CALLDATALOAD).pushUint(36).op(vm.CALLDATALOAD).pushUint(4).op(vm.CALLDATALOAD)
+	move()
+
+	a.label("approve")
+	a.pushUint(36).op(vm.CALLDATALOAD)
+	a.pushUint(4).op(vm.CALLDATALOAD, vm.CALLER).allowanceSlot().op(vm.SSTORE)
+	a.pushUint(36).op(vm.CALLDATALOAD).pushUint(0).op(vm.MSTORE)
+	a.pushUint(4).op(vm.CALLDATALOAD, vm.CALLER).push(approvalLogTopic.Bytes()).pushUint(32).pushUint(0).op(vm.LOG3)
+	a.pushUint(1).returnWord()
+
+	a.label("balanceOf")
+	a.pushUint(4).op(vm.CALLDATALOAD).mappingSlot(erc20BalancesSlot).op(vm.SLOAD).returnWord()
+
+	a.label("allowance")
+	a.pushUint(36).op(vm.CALLDATALOAD).pushUint(4).op(vm.CALLDATALOAD).allowanceSlot().op(vm.SLOAD).returnWord()
+
+	a.label("totalSupply")
+	a.pushUint(erc20TotalSupplySlot).op(vm.SLOAD).returnWord()
+
+	a.label("decimals")
+	a.pushUint(18).returnWord()
+
+	a.label("symbol")
+	a.returnString(erc20TokenSymbol)
+
+	a.label("name")
+	a.returnString(erc20TokenName)
+
+	return a.assemble()
+}
+
+// mockBridgeTokenCode runtime code of mock token which accepts calls like
+// the erc20 and swapin/swapout bridge tokens, and emits the same logs
+// without keeping balances.
+//
+//	transfer(address to, uint256 amount) emits Transfer(caller, to, amount)
+//	Swapin(bytes32 txhash, address account, uint256 amount) emits LogSwapin(txhash, account, amount)
+//	Swapout(uint256 amount, address bindaddr) emits LogSwapout(caller, bindaddr, amount)
+//	Swapout(uint256 amount, string bindaddr) emits LogSwapout(caller, bindaddr, amount)
+//	decimals() returns 18
+//	symbol() and name() return the mock token symbol and name
+func mockBridgeTokenCode() []byte {
+	a := newEVMAssembler()
+	// selector
+	a.pushUint(0).op(vm.CALLDATALOAD).pushUint(0xe0).op(vm.SHR)
+	dispatch := []struct {
+		selector []byte
+		label    string
+	}{
+		{transferFuncHash, "transfer"},
+		{swapinFuncHash, "swapin"},
+		{addressSwapoutFuncHash, "addressSwapout"},
+		{stringSwapoutFuncHash, "stringSwapout"},
+		{common.FromHex("0x313ce567"), "decimals"},
+		{common.FromHex("0x95d89b41"), "symbol"},
+		{common.FromHex("0x06fdde03"), "name"},
+	}
+	for _, item := range dispatch {
+		a.op(vm.DUP1).push(item.selector).op(vm.EQ).pushLabel(item.label).op(vm.JUMPI)
+	}
+	a.pushUint(0).op(vm.DUP1, vm.REVERT)
+
+	returnUint := func(value uint64) {
+		a.pushUint(value).returnWord()
+	}
+	// emit LOG3 with one word data at calldata offset,
+	// topics are pushed onto stack before in reverse order
+	log3 := func(topic common.Hash, dataOffset uint64) {
+		a.push(topic.Bytes())
+		a.pushUint(dataOffset).op(vm.CALLDATALOAD).pushUint(0).op(vm.MSTORE)
+		a.pushUint(32).pushUint(0).op(vm.LOG3)
+	}
+
+	a.label("transfer")
+	a.pushUint(4).op(vm.CALLDATALOAD).op(vm.CALLER)
+	log3(transferLogTopic, 36)
+	returnUint(1)
+
+	a.label("swapin")
+	a.pushUint(36).op(vm.CALLDATALOAD).pushUint(4).op(vm.CALLDATALOAD)
+	log3(swapinLogTopic, 68)
+	returnUint(1)
+
+	a.label("addressSwapout")
+	a.pushUint(36).op(vm.CALLDATALOAD).op(vm.CALLER)
+	log3(addressSwapoutLogTopic, 4)
+	returnUint(1)
+
+	// log data is the abi encoded arguments (uint256 amount, string bindaddr)
+	a.label("stringSwapout")
+	a.op(vm.CALLER)
+	a.pushUint(4).op(vm.CALLDATASIZE, vm.SUB)
+	a.op(vm.DUP1).pushUint(4).pushUint(0).op(vm.CALLDATACOPY)
+	a.push(stringSwapoutLogTopic.Bytes()).op(vm.SWAP1)
+	a.pushUint(0).op(vm.LOG2)
+	returnUint(1)
+
+	a.label("decimals")
+	returnUint(18)
+
+	a.label("symbol")
+	a.returnString(mockTokenSymbol)
+
+	a.label("name")
+	a.returnString(mockTokenName)
+
+	return a.assemble()
+}
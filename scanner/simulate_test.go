@@ -0,0 +1,1135 @@
+package scanner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/davecgh/go-spew/spew"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+const (
+	simChainName     = "simulated"
+	simGasLimit      = 500000
+	simBlockGasLimit = 30000000
+)
+
+var (
+	simChainID  = big.NewInt(1337) // chain ID of simulated backend
+	simGasPrice = big.NewInt(100e9)
+	simGasTip   = big.NewInt(1e9)
+	simBalance  = new(big.Int).Mul(big.NewInt(1e18), big.NewInt(1000000))
+
+	simTxTypes = []uint8{types.LegacyTxType, types.AccessListTxType, types.DynamicFeeTxType}
+)
+
+// simulated accounts, the keys are derived from the names deterministically
+const (
+	simDeployer  = "deployer"
+	simUser      = "user"
+	simOther     = "other"
+	simNativeMPC = "nativeMPC"
+	simErc20MPC  = "erc20MPC"
+	simBridgeMPC = "bridgeMPC"
+	simRedeemMPC = "redeemMPC"
+	simNewMPC    = "newMPC"
+)
+
+func simAccountKey(name string) *ecdsa.PrivateKey {
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("bridgeAccounting simulation " + name)))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// simulation run the scanner against a simulated chain, which has an erc20 token
+// on the src side and a mock swapin/swapout bridge token on the dst side,
+// and compare the stored swap events with the expected ones.
+type simulation struct {
+	backend     *backends.SimulatedBackend
+	signer      types.Signer
+	keys        map[string]*ecdsa.PrivateKey
+	scanReceipt bool
+	txCount     int
+
+	db      *memorySyncAPI
+	scanner *ethSwapScanner
+
+	nativeToken *params.TokenConfig
+	erc20Token  *params.TokenConfig
+	bridgeToken *params.TokenConfig
+
+	expected map[string]map[string]*mongodb.SwapEvent // token key and swap type -> tx hash -> swap event
+}
+
+func newSimulation(ctx context.Context, scanReceipt bool) (*simulation, error) {
+	sim := &simulation{
+		signer:      types.LatestSignerForChainID(simChainID),
+		keys:        make(map[string]*ecdsa.PrivateKey),
+		scanReceipt: scanReceipt,
+		db:          newMemorySyncAPI(),
+		expected:    make(map[string]map[string]*mongodb.SwapEvent),
+	}
+	alloc := make(core.GenesisAlloc)
+	for _, name := range []string{simDeployer, simUser, simOther, simNativeMPC, simErc20MPC, simBridgeMPC, simRedeemMPC, simNewMPC} {
+		key := simAccountKey(name)
+		sim.keys[name] = key
+		alloc[crypto.PubkeyToAddress(key.PublicKey)] = core.GenesisAccount{Balance: simBalance}
+	}
+	sim.backend = backends.NewSimulatedBackend(alloc, simBlockGasLimit)
+
+	erc20Tx, err := sim.sendTx(simDeployer, nil, nil, evmDeployCode(erc20TokenCode(), erc20TokenConstructor))
+	if err != nil {
+		return nil, err
+	}
+	bridgeTx, err := sim.sendTx(simDeployer, nil, nil, evmDeployCode(mockBridgeTokenCode()))
+	if err != nil {
+		return nil, err
+	}
+	sim.commit()
+	erc20Address, err := sim.deployedAddress(erc20Tx)
+	if err != nil {
+		return nil, err
+	}
+	bridgeAddress, err := sim.deployedAddress(bridgeTx)
+	if err != nil {
+		return nil, err
+	}
+	// the erc20 token is minted to the deployer
+	for _, name := range []string{simUser, simErc20MPC, simRedeemMPC} {
+		if _, err = sim.sendTx(simDeployer, &erc20Address, nil, transferCallData(sim.address(name), milliEther(1e8))); err != nil {
+			return nil, err
+		}
+	}
+	sim.commit()
+
+	sim.nativeToken = &params.TokenConfig{
+		Chain:          simChainName,
+		IsSrcToken:     true,
+		PairID:         "eth",
+		TokenAddress:   "native",
+		DepositAddress: sim.address(simNativeMPC).Hex(),
+	}
+	sim.erc20Token = &params.TokenConfig{
+		Chain:          simChainName,
+		IsSrcToken:     true,
+		PairID:         "usdt",
+		TokenAddress:   erc20Address.Hex(),
+		DepositAddress: sim.address(simErc20MPC).Hex(),
+	}
+	sim.bridgeToken = &params.TokenConfig{
+		Chain:          simChainName,
+		IsSrcToken:     false,
+		PairID:         "usdt",
+		TokenAddress:   bridgeAddress.Hex(),
+		DepositAddress: sim.address(simBridgeMPC).Hex(),
+	}
+
+	sim.scanner = newEthSwapScanner(ctx, &params.ChainConfig{
+		Name:                simChainName,
+		ChainID:             simChainID.String(),
+		ScanReceipt:         scanReceipt,
+		JobCount:            2,
+		ProcessBlockTimeout: 60,
+	})
+	sim.scanner.offline = sim.backend
+	sim.scanner.tokens = []*params.TokenConfig{sim.nativeToken, sim.erc20Token, sim.bridgeToken}
+	sim.scanner.rpcInterval = 10 * time.Millisecond
+	return sim, nil
+}
+
+func (sim *simulation) close() {
+	sim.scanner.cancel()
+	_ = sim.backend.Close()
+}
+
+func (sim *simulation) address(name string) common.Address {
+	return crypto.PubkeyToAddress(sim.keys[name].PublicKey)
+}
+
+// send tx of the next tx type in turn, so that every scenario covers the typed txs
+func (sim *simulation) sendTx(from string, to *common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	txType := simTxTypes[sim.txCount%len(simTxTypes)]
+	sim.txCount++
+	return sim.sendTypedTx(txType, from, to, value, data)
+}
+
+func (sim *simulation) sendTypedTx(txType uint8, from string, to *common.Address, value *big.Int, data []byte) (*types.Transaction, error) {
+	ctx := context.Background()
+	nonce, err := sim.backend.PendingNonceAt(ctx, sim.address(from))
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	var txdata types.TxData
+	switch txType {
+	case types.LegacyTxType:
+		txdata = &types.LegacyTx{Nonce: nonce, GasPrice: simGasPrice, Gas: simGasLimit, To: to, Value: value, Data: data}
+	case types.AccessListTxType:
+		txdata = &types.AccessListTx{ChainID: simChainID, Nonce: nonce, GasPrice: simGasPrice, Gas: simGasLimit, To: to, Value: value, Data: data}
+	case types.DynamicFeeTxType:
+		txdata = &types.DynamicFeeTx{ChainID: simChainID, Nonce: nonce, GasTipCap: simGasTip, GasFeeCap: simGasPrice, Gas: simGasLimit, To: to, Value: value, Data: data}
+	default:
+		return nil, fmt.Errorf("unknown tx type %v", txType)
+	}
+	tx, err := types.SignNewTx(sim.keys[from], sim.signer, txdata)
+	if err != nil {
+		return nil, err
+	}
+	return tx, sim.resendTx(tx)
+}
+
+func (sim *simulation) resendTx(tx *types.Transaction) error {
+	if err := sim.backend.SendTransaction(context.Background(), tx); err != nil {
+		return fmt.Errorf("send tx %v failed: %w", tx.Hash().Hex(), err)
+	}
+	return nil
+}
+
+// commit pending txs into new block, return the header of the new block
+func (sim *simulation) commit() *types.Header {
+	sim.backend.Commit()
+	header, _ := sim.backend.HeaderByNumber(context.Background(), nil)
+	return header
+}
+
+func (sim *simulation) latestHeight() uint64 {
+	header, _ := sim.backend.HeaderByNumber(context.Background(), nil)
+	return header.Number.Uint64()
+}
+
+func (sim *simulation) deployedAddress(tx *types.Transaction) (common.Address, error) {
+	receipt, err := sim.backend.TransactionReceipt(context.Background(), tx.Hash())
+	if err != nil {
+		return common.Address{}, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return common.Address{}, fmt.Errorf("deploy contract failed, tx %v", tx.Hash().Hex())
+	}
+	return receipt.ContractAddress, nil
+}
+
+// scan blocks in range [from, to), then settle the swap events as the scanner with end height does
+func (sim *simulation) scan(from, to uint64) {
+	scanner := sim.scanner.cloneForTokens(sim.scanner.tokens, to)
+	scanner.doScanRangeJob(from, to)
+	scanner.settleSwapEvents()
+}
+
+// scan blocks from height to the latest block
+func (sim *simulation) scanFrom(from uint64) {
+	sim.scan(from, sim.latestHeight()+1)
+}
+
+// simExpect expected swap event of tx
+type simExpect struct {
+	tx      *types.Transaction
+	header  *types.Header
+	user    common.Address
+	amount  *big.Int
+	famount float64
+	bind    string
+	refTx   common.Hash
+	mpc     common.Address // deposit and redeemed only, default is the 'DepositAddress' of token
+
+	invalidBind bool // burn only
+	unconfirmed bool
+	matched     bool
+	bridgeFee   float64 // mint only
+}
+
+func simExpectKey(swapTxType SwapTxType, tokenCfg *params.TokenConfig) string {
+	return fmt.Sprintf("%v/%v", tokenCfg.Key(), swapTxType)
+}
+
+// expect swap event of tx, replace the existing one of the same tx.
+// the gas fee is got from the receipt of tx in the canonical chain.
+func (sim *simulation) expect(swapTxType SwapTxType, tokenCfg *params.TokenConfig, e *simExpect) {
+	receipt, err := sim.backend.TransactionReceipt(context.Background(), e.tx.Hash())
+	if err != nil || receipt == nil {
+		panic(fmt.Sprintf("receipt of expected tx %v not found", e.tx.Hash().Hex()))
+	}
+	// min(fee cap, tip cap + base fee), which is the gas price of legacy tx
+	gasPrice := new(big.Int).Add(e.tx.GasTipCap(), e.header.BaseFee)
+	if gasPrice.Cmp(e.tx.GasFeeCap()) > 0 {
+		gasPrice = e.tx.GasFeeCap()
+	}
+	txFee := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(receipt.GasUsed))
+	event := &mongodb.SwapEvent{
+		TxHash:      strings.ToLower(e.tx.Hash().Hex()),
+		BlockTime:   int64(e.header.Time),
+		BlockNumber: e.header.Number.Int64(),
+		Amount:      e.amount.String(),
+		Decimals:    18,
+		FAmount:     e.famount,
+		User:        strings.ToLower(e.user.Hex()),
+		Bind:        e.bind,
+		InvalidBind: e.invalidBind,
+		Unconfirmed: e.unconfirmed,
+		GasUsed:     receipt.GasUsed,
+		GasPrice:    gasPrice.String(),
+		TxFee:       txFee.String(),
+		FTxFee:      toFloat(txFee, 18),
+		Matched:     e.matched,
+		BridgeFee:   e.bridgeFee,
+	}
+	if e.refTx != (common.Hash{}) {
+		event.RefTxHash = strings.ToLower(e.refTx.Hex())
+	}
+	if swapTxType == TypeDeposit || swapTxType == TypeRedeemed {
+		mpc := e.mpc
+		if mpc == (common.Address{}) {
+			mpc = common.HexToAddress(tokenCfg.DepositAddress)
+		}
+		event.MPCAddress = strings.ToLower(mpc.Hex())
+	}
+	sim.expectEvent(swapTxType, tokenCfg, event)
+}
+
+// expect the stored swap event, replace the existing one of the same tx
+func (sim *simulation) expectEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, event *mongodb.SwapEvent) {
+	event.Chain = tokenCfg.Chain
+	key := simExpectKey(swapTxType, tokenCfg)
+	events, exist := sim.expected[key]
+	if !exist {
+		events = make(map[string]*mongodb.SwapEvent)
+		sim.expected[key] = events
+	}
+	events[event.TxHash] = event
+}
+
+// verify the stored token metadata and swap events are exactly the expected ones
+func (sim *simulation) verify() error {
+	if err := sim.verifyTokenMetas(); err != nil {
+		return err
+	}
+	checks := []struct {
+		tokenCfg   *params.TokenConfig
+		swapTxType SwapTxType
+	}{
+		{sim.nativeToken, TypeDeposit},
+		{sim.nativeToken, TypeRedeemed},
+		{sim.erc20Token, TypeDeposit},
+		{sim.erc20Token, TypeRedeemed},
+		{sim.bridgeToken, TypeMint},
+		{sim.bridgeToken, TypeBurn},
+	}
+	for _, check := range checks {
+		if err := sim.verifySwapEvents(check.swapTxType, check.tokenCfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verify the stored swap events of token are exactly the expected ones
+func (sim *simulation) verifySwapEvents(swapTxType SwapTxType, tokenCfg *params.TokenConfig) error {
+	var want []*mongodb.SwapEvent
+	for _, event := range sim.expected[simExpectKey(swapTxType, tokenCfg)] {
+		want = append(want, event)
+	}
+	sort.Slice(want, func(i, j int) bool {
+		if want[i].BlockNumber != want[j].BlockNumber {
+			return want[i].BlockNumber < want[j].BlockNumber
+		}
+		return want[i].TxHash < want[j].TxHash
+	})
+	have := sim.db.GetSwapEvents(mongodb.TxType(swapTxType), tokenCfg)
+	if !reflect.DeepEqual(have, want) {
+		return fmt.Errorf("%v of %v mismatch\nhave: %v\nwant: %v",
+			swapTxType, tokenCfg.PairID, formatSwapEvents(have), formatSwapEvents(want))
+	}
+	return nil
+}
+
+func (sim *simulation) verifyTokenMetas() error {
+	for _, tokenCfg := range []*params.TokenConfig{sim.nativeToken, sim.erc20Token, sim.bridgeToken} {
+		meta, err := sim.db.GetTokenMeta(tokenCfg)
+		if err != nil {
+			return fmt.Errorf("token meta of %v not stored: %w", tokenCfg.TokenAddress, err)
+		}
+		symbol, name := mockTokenSymbol, mockTokenName
+		switch tokenCfg {
+		case sim.nativeToken:
+			symbol, name = "", ""
+		case sim.erc20Token:
+			symbol, name = erc20TokenSymbol, erc20TokenName
+		}
+		if meta.Decimals != 18 || meta.Symbol != symbol || meta.Name != name || meta.TokenAddress != tokenCfg.TokenAddress {
+			return fmt.Errorf("token meta of %v mismatch: %+v", tokenCfg.TokenAddress, *meta)
+		}
+	}
+	return nil
+}
+
+func formatSwapEvents(events []*mongodb.SwapEvent) string {
+	items := make([]string, 0, len(events))
+	for _, event := range events {
+		items = append(items, fmt.Sprintf("%+v", *event))
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// milliEther amount in unit of 0.001 ether
+func milliEther(amount int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(amount), big.NewInt(1e15))
+}
+
+func simCallData(funcHash []byte, args abi.Arguments, values ...interface{}) []byte {
+	data, err := args.Pack(values...)
+	if err != nil {
+		panic(err)
+	}
+	return append(common.CopyBytes(funcHash), data...)
+}
+
+func simArguments(typeNames ...string) abi.Arguments {
+	args := make(abi.Arguments, 0, len(typeNames))
+	for _, name := range typeNames {
+		typ, err := abi.NewType(name, "", nil)
+		if err != nil {
+			panic(err)
+		}
+		args = append(args, abi.Argument{Type: typ})
+	}
+	return args
+}
+
+func transferCallData(to common.Address, amount *big.Int) []byte {
+	return simCallData(transferFuncHash, simArguments("address", "uint256"), to, amount)
+}
+
+func swapinCallData(txhash common.Hash, account common.Address, amount *big.Int) []byte {
+	return simCallData(swapinFuncHash, simArguments("bytes32", "address", "uint256"), txhash, account, amount)
+}
+
+func addressSwapoutCallData(amount *big.Int, bindaddr common.Address) []byte {
+	return simCallData(addressSwapoutFuncHash, simArguments("uint256", "address"), amount, bindaddr)
+}
+
+func stringSwapoutCallData(amount *big.Int, bindaddr string) []byte {
+	return simCallData(stringSwapoutFuncHash, simArguments("uint256", "string"), amount, bindaddr)
+}
+
+// simScenario one scenario of simulation, the stored swap events are verified after it run
+type simScenario struct {
+	name string
+	run  func(sim *simulation) error
+}
+
+var simScenarios = []*simScenario{
+	{"native", simNativeScenario},
+	{"erc20", simErc20Scenario},
+	{"mint", simMintScenario},
+	{"burn", simBurnScenario},
+	{"typed-tx", simTypedTxScenario},
+	{"reorg", simReorgScenario},
+	{"unresolved-decimals", simUnresolvedDecimalsScenario},
+	{"finality", simFinalityScenario},
+	{"bridge-fee", simBridgeFeeScenario},
+	{"anomaly", simAnomalyScenario},
+	{"address-watch", simAddressWatchScenario},
+	{"mpc-addresses", simMPCAddressesScenario},
+	{"unclassified-transfer", simUnclassifiedTransferScenario},
+	{"btc-chain", simBTCChainScenario},
+}
+
+// deposit and redeem native coin, and transfers which are not swaps
+func simNativeScenario(sim *simulation) error {
+	from := sim.latestHeight() + 1
+	mpc := sim.address(simNativeMPC)
+	deposit, err := sim.sendTx(simUser, &mpc, milliEther(1500), nil)
+	if err != nil {
+		return err
+	}
+	other := sim.address(simOther)
+	if _, err = sim.sendTx(simUser, &other, milliEther(100), nil); err != nil {
+		return err
+	}
+	header := sim.commit()
+	sim.expect(TypeDeposit, sim.nativeToken, &simExpect{tx: deposit, header: header, user: sim.address(simUser), amount: milliEther(1500), famount: 1.5})
+
+	user := sim.address(simUser)
+	redeem, err := sim.sendTx(simNativeMPC, &user, milliEther(1250), nil)
+	if err != nil {
+		return err
+	}
+	header = sim.commit()
+	sim.expect(TypeRedeemed, sim.nativeToken, &simExpect{tx: redeem, header: header, user: user, amount: milliEther(1250), famount: 1.25})
+
+	sim.scanFrom(from)
+	return nil
+}
+
+// deposit and redeem erc20 token, and transfers to other receiver
+func simErc20Scenario(sim *simulation) error {
+	from := sim.latestHeight() + 1
+	token := common.HexToAddress(sim.erc20Token.TokenAddress)
+	user := sim.address(simUser)
+	deposit, err := sim.sendTx(simUser, &token, nil, transferCallData(sim.address(simErc20MPC), milliEther(2000)))
+	if err != nil {
+		return err
+	}
+	if _, err = sim.sendTx(simUser, &token, nil, transferCallData(sim.address(simOther), milliEther(10))); err != nil {
+		return err
+	}
+	header := sim.commit()
+	sim.expect(TypeDeposit, sim.erc20Token, &simExpect{tx: deposit, header: header, user: user, amount: milliEther(2000), famount: 2})
+
+	redeem, err := sim.sendTx(simErc20MPC, &token, nil, transferCallData(user, milliEther(1999)))
+	if err != nil {
+		return err
+	}
+	header = sim.commit()
+	sim.expect(TypeRedeemed, sim.erc20Token, &simExpect{tx: redeem, header: header, user: user, amount: milliEther(1999), famount: 1.999})
+
+	sim.scanFrom(from)
+	return nil
+}
+
+// mint bridge token by mpc, and swapin called by others which is not mint
+func simMintScenario(sim *simulation) error {
+	from := sim.latestHeight() + 1
+	token := common.HexToAddress(sim.bridgeToken.TokenAddress)
+	user := sim.address(simUser)
+	refTx := crypto.Keccak256Hash([]byte("deposit tx on src chain"))
+	mint, err := sim.sendTx(simBridgeMPC, &token, nil, swapinCallData(refTx, user, milliEther(3000)))
+	if err != nil {
+		return err
+	}
+	header := sim.commit()
+	sim.expect(TypeMint, sim.bridgeToken, &simExpect{tx: mint, header: header, user: user, amount: milliEther(3000), famount: 3, refTx: refTx})
+
+	sim.scanFrom(from)
+	return nil
+}
+
+// burn bridge token with eth like and btc like bind addresses,
+// which are checked and normalized in the bind format of token
+func simBurnScenario(sim *simulation) error {
+	from := sim.latestHeight() + 1
+	token := common.HexToAddress(sim.bridgeToken.TokenAddress)
+	user := sim.address(simUser)
+	bindAddr := sim.address(simOther)
+	burn, err := sim.sendTx(simUser, &token, nil, addressSwapoutCallData(milliEther(500), bindAddr))
+	if err != nil {
+		return err
+	}
+	lowerBurn, err := sim.sendTx(simUser, &token, nil, stringSwapoutCallData(milliEther(600), strings.ToLower(bindAddr.Hex())))
+	if err != nil {
+		return err
+	}
+	btcBind := "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"
+	btcBurn, err := sim.sendTx(simUser, &token, nil, stringSwapoutCallData(milliEther(750), btcBind))
+	if err != nil {
+		return err
+	}
+	header := sim.commit()
+	sim.expect(TypeBurn, sim.bridgeToken, &simExpect{tx: burn, header: header, user: user, amount: milliEther(500), famount: 0.5, bind: bindAddr.Hex()})
+	sim.expect(TypeBurn, sim.bridgeToken, &simExpect{tx: lowerBurn, header: header, user: user, amount: milliEther(600), famount: 0.6, bind: bindAddr.Hex()})
+	sim.expect(TypeBurn, sim.bridgeToken, &simExpect{tx: btcBurn, header: header, user: user, amount: milliEther(750), famount: 0.75, bind: btcBind, invalidBind: true})
+	sim.scanFrom(from)
+
+	sim.bridgeToken.BindFormat = params.BindFormatBTC
+	from = sim.latestHeight() + 1
+	binds := []struct {
+		bind       string
+		normalized string
+	}{
+		{strings.ToUpper(btcBind), btcBind},
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2", "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2"}, // P2PKH
+		{"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy", "3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy"}, // P2SH
+		{"tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx", ""},                           // testnet
+		{"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3", ""},                                   // wrong checksum
+		{bindAddr.Hex(), ""},
+	}
+	burns := make([]*types.Transaction, len(binds))
+	for i, item := range binds {
+		if burns[i], err = sim.sendTx(simUser, &token, nil, stringSwapoutCallData(milliEther(100), item.bind)); err != nil {
+			return err
+		}
+	}
+	header = sim.commit()
+	for i, item := range binds {
+		e := &simExpect{tx: burns[i], header: header, user: user, amount: milliEther(100), famount: 0.1, bind: item.normalized}
+		if item.normalized == "" {
+			e.bind, e.invalidBind = item.bind, true
+		}
+		sim.expect(TypeBurn, sim.bridgeToken, e)
+	}
+	sim.scanFrom(from)
+	return nil
+}
+
+// swaps of every tx type packed in one block
+func simTypedTxScenario(sim *simulation) error {
+	from := sim.latestHeight() + 1
+	erc20 := common.HexToAddress(sim.erc20Token.TokenAddress)
+	bridge := common.HexToAddress(sim.bridgeToken.TokenAddress)
+	mpc := sim.address(simNativeMPC)
+	user := sim.address(simUser)
+
+	type swapTx struct {
+		tx       *types.Transaction
+		tokenCfg *params.TokenConfig
+		swapType SwapTxType
+		amount   int64
+	}
+	var swaps []*swapTx
+	for i, txType := range simTxTypes {
+		amount := int64(100 * (i + 1))
+		native, err := sim.sendTypedTx(txType, simUser, &mpc, milliEther(amount), nil)
+		if err != nil {
+			return err
+		}
+		deposit, err := sim.sendTypedTx(txType, simUser, &erc20, nil, transferCallData(sim.address(simErc20MPC), milliEther(amount)))
+		if err != nil {
+			return err
+		}
+		burn, err := sim.sendTypedTx(txType, simUser, &bridge, nil, addressSwapoutCallData(milliEther(amount), user))
+		if err != nil {
+			return err
+		}
+		swaps = append(swaps,
+			&swapTx{native, sim.nativeToken, TypeDeposit, amount},
+			&swapTx{deposit, sim.erc20Token, TypeDeposit, amount},
+			&swapTx{burn, sim.bridgeToken, TypeBurn, amount},
+		)
+	}
+	header := sim.commit()
+	for _, swap := range swaps {
+		e := &simExpect{tx: swap.tx, header: header, user: user, amount: milliEther(swap.amount), famount: float64(swap.amount) / 1000}
+		if swap.swapType == TypeBurn {
+			e.bind = user.Hex()
+		}
+		sim.expect(swap.swapType, swap.tokenCfg, e)
+	}
+
+	sim.scanFrom(from)
+	return nil
+}
+
+// the deposit tx is packed into another block after reorg,
+// the stored swap event is updated when the new chain is scanned
+func simReorgScenario(sim *simulation) error {
+	from := sim.latestHeight() + 1
+	parent, err := sim.backend.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	token := common.HexToAddress(sim.erc20Token.TokenAddress)
+	user := sim.address(simUser)
+	deposit, err := sim.sendTx(simUser, &token, nil, transferCallData(sim.address(simErc20MPC), milliEther(4000)))
+	if err != nil {
+		return err
+	}
+	header := sim.commit()
+	sim.expect(TypeDeposit, sim.erc20Token, &simExpect{tx: deposit, header: header, user: user, amount: milliEther(4000), famount: 4})
+	sim.scanFrom(from)
+	if err = sim.verify(); err != nil {
+		return fmt.Errorf("before reorg: %w", err)
+	}
+
+	// fork from parent, the deposit is packed one block later in the longer side chain
+	if err = sim.backend.Fork(context.Background(), parent.Hash()); err != nil {
+		return err
+	}
+	other := sim.address(simOther)
+	if _, err = sim.sendTx(simOther, &other, milliEther(1), nil); err != nil {
+		return err
+	}
+	sim.commit()
+	if err = sim.resendTx(deposit); err != nil {
+		return err
+	}
+	header = sim.commit()
+	sim.commit()
+	if header.Hash() != sim.backend.Blockchain().GetCanonicalHash(header.Number.Uint64()) {
+		return fmt.Errorf("side chain is not canonical after reorg")
+	}
+	sim.expect(TypeDeposit, sim.erc20Token, &simExpect{tx: deposit, header: header, user: user, amount: milliEther(4000), famount: 4})
+
+	sim.scanFrom(from)
+	return nil
+}
+
+// deposit of token whose decimals can not be resolved is not recorded,
+// and the block is recorded as gap if the deposit is found
+func simUnresolvedDecimalsScenario(sim *simulation) error {
+	from := sim.latestHeight() + 1
+	// the token address has no code
+	token := sim.address(simOther)
+	unresolved := &params.TokenConfig{
+		Chain:          simChainName,
+		IsSrcToken:     true,
+		PairID:         "unresolved",
+		TokenAddress:   token.Hex(),
+		DepositAddress: sim.address(simErc20MPC).Hex(),
+	}
+	sim.scanner.tokens = append(sim.scanner.tokens, unresolved)
+	if _, err := sim.sendTx(simUser, &token, nil, transferCallData(sim.address(simErc20MPC), milliEther(1))); err != nil {
+		return err
+	}
+	header := sim.commit()
+	sim.scanFrom(from)
+
+	if events := sim.db.GetSwapEvents(mongodb.TypeDeposit, unresolved); len(events) != 0 {
+		return fmt.Errorf("swap events of unresolved token are recorded: %v", formatSwapEvents(events))
+	}
+	if _, err := sim.db.GetTokenMeta(unresolved); err == nil {
+		return fmt.Errorf("unresolved token meta is stored")
+	}
+	gaps, _ := sim.db.GetBlockGaps(simChainName)
+	// the deposit is only found by tx input, as there is no transfer log
+	if !sim.scanReceipt {
+		if len(gaps) != 1 || gaps[0].Start != header.Number.Int64() || !strings.Contains(gaps[0].Reason, errUnresolvedDecimals.Error()) {
+			return fmt.Errorf("block gap of unresolved token mismatch: %v", gaps)
+		}
+	} else if len(gaps) != 0 {
+		return fmt.Errorf("unexpected block gaps: %v", gaps)
+	}
+	return nil
+}
+
+// the deposit is unconfirmed until its block is deeper than the stable height,
+// and the confirmed height of chain is advanced to the final block
+func simFinalityScenario(sim *simulation) error {
+	const stable = 3
+	sim.scanner.stableHeight = stable
+	from := sim.latestHeight() + 1
+	mpc := sim.address(simNativeMPC)
+	user := sim.address(simUser)
+	deposit, err := sim.sendTx(simUser, &mpc, milliEther(600), nil)
+	if err != nil {
+		return err
+	}
+	header := sim.commit()
+	e := &simExpect{tx: deposit, header: header, user: user, amount: milliEther(600), famount: 0.6, unconfirmed: true}
+	sim.expect(TypeDeposit, sim.nativeToken, e)
+	sim.scanFrom(from)
+	if err = sim.verify(); err != nil {
+		return fmt.Errorf("before final: %w", err)
+	}
+
+	for i := 0; i < stable; i++ {
+		sim.commit()
+	}
+	e.unconfirmed = false
+	sim.expect(TypeDeposit, sim.nativeToken, e)
+	sim.scanFrom(header.Number.Uint64() + 1)
+
+	syncInfo, err := sim.db.GetSyncInfo(simChainName)
+	if err != nil {
+		return err
+	}
+	if syncInfo.ConfirmedHeight != header.Number.Int64() {
+		return fmt.Errorf("confirmed height mismatch, have %v want %v", syncInfo.ConfirmedHeight, header.Number)
+	}
+	return nil
+}
+
+// mints are matched with their deposits whether the deposits are recorded before or after them
+func simBridgeFeeScenario(sim *simulation) error {
+	erc20 := common.HexToAddress(sim.erc20Token.TokenAddress)
+	bridge := common.HexToAddress(sim.bridgeToken.TokenAddress)
+	user := sim.address(simUser)
+
+	deposit, err := sim.sendTx(simUser, &erc20, nil, transferCallData(sim.address(simErc20MPC), milliEther(2000)))
+	if err != nil {
+		return err
+	}
+	depositHeader := sim.commit()
+	mint, err := sim.sendTx(simBridgeMPC, &bridge, nil, swapinCallData(deposit.Hash(), user, milliEther(1990)))
+	if err != nil {
+		return err
+	}
+	mintHeader := sim.commit()
+
+	// the mint is scanned before its deposit
+	sim.scan(mintHeader.Number.Uint64(), mintHeader.Number.Uint64()+1)
+	if event, err := sim.db.GetMint(sim.bridgeToken, mint.Hash().Hex()); err != nil || event.Matched {
+		return fmt.Errorf("mint is matched before its deposit is recorded, err %v", err)
+	}
+	sim.scan(depositHeader.Number.Uint64(), depositHeader.Number.Uint64()+1)
+	sim.expect(TypeDeposit, sim.erc20Token, &simExpect{tx: deposit, header: depositHeader, user: user, amount: milliEther(2000), famount: 2, matched: true})
+	sim.expect(TypeMint, sim.bridgeToken, &simExpect{tx: mint, header: mintHeader, user: user, amount: milliEther(1990), famount: 1.99,
+		refTx: deposit.Hash(), matched: true, bridgeFee: 0.01})
+
+	// the mint is scanned after its deposit
+	from := sim.latestHeight() + 1
+	deposit, err = sim.sendTx(simUser, &erc20, nil, transferCallData(sim.address(simErc20MPC), milliEther(1000)))
+	if err != nil {
+		return err
+	}
+	depositHeader = sim.commit()
+	mint, err = sim.sendTx(simBridgeMPC, &bridge, nil, swapinCallData(deposit.Hash(), user, milliEther(999)))
+	if err != nil {
+		return err
+	}
+	mintHeader = sim.commit()
+	sim.expect(TypeDeposit, sim.erc20Token, &simExpect{tx: deposit, header: depositHeader, user: user, amount: milliEther(1000), famount: 1, matched: true})
+	sim.expect(TypeMint, sim.bridgeToken, &simExpect{tx: mint, header: mintHeader, user: user, amount: milliEther(999), famount: 0.999,
+		refTx: deposit.Hash(), matched: true, bridgeFee: 0.001})
+
+	sim.scanFrom(from)
+	return nil
+}
+
+// redeems are matched with burns to their receivers, and the redeem without burn
+// and the mints without deposit are anomalies until they are matched
+func simAnomalyScenario(sim *simulation) error {
+	erc20 := common.HexToAddress(sim.erc20Token.TokenAddress)
+	bridge := common.HexToAddress(sim.bridgeToken.TokenAddress)
+	user := sim.address(simUser)
+	other := sim.address(simOther)
+
+	from := sim.latestHeight() + 1
+	burn, err := sim.sendTx(simUser, &bridge, nil, addressSwapoutCallData(milliEther(800), other))
+	if err != nil {
+		return err
+	}
+	burnHeader := sim.commit()
+	redeem, err := sim.sendTx(simErc20MPC, &erc20, nil, transferCallData(other, milliEther(790)))
+	if err != nil {
+		return err
+	}
+	transfer, err := sim.sendTx(simErc20MPC, &erc20, nil, transferCallData(user, milliEther(300)))
+	if err != nil {
+		return err
+	}
+	redeemHeader := sim.commit()
+	fakeMint, err := sim.sendTx(simBridgeMPC, &bridge, nil, swapinCallData(crypto.Keccak256Hash([]byte("no such deposit")), user, milliEther(50)))
+	if err != nil {
+		return err
+	}
+	fakeMintHeader := sim.commit()
+	sim.expect(TypeBurn, sim.bridgeToken, &simExpect{tx: burn, header: burnHeader, user: user, amount: milliEther(800), famount: 0.8, bind: other.Hex(), matched: true})
+	sim.expect(TypeRedeemed, sim.erc20Token, &simExpect{tx: redeem, header: redeemHeader, user: other, amount: milliEther(790), famount: 0.79, refTx: burn.Hash(), matched: true})
+	sim.expect(TypeRedeemed, sim.erc20Token, &simExpect{tx: transfer, header: redeemHeader, user: user, amount: milliEther(300), famount: 0.3})
+	sim.expect(TypeMint, sim.bridgeToken, &simExpect{tx: fakeMint, header: fakeMintHeader, user: user, amount: milliEther(50), famount: 0.05, refTx: crypto.Keccak256Hash([]byte("no such deposit"))})
+	sim.scanFrom(from)
+
+	// the mint is an anomaly until its deposit is recorded
+	deposit, err := sim.sendTx(simUser, &erc20, nil, transferCallData(sim.address(simErc20MPC), milliEther(400)))
+	if err != nil {
+		return err
+	}
+	depositHeader := sim.commit()
+	mint, err := sim.sendTx(simBridgeMPC, &bridge, nil, swapinCallData(deposit.Hash(), user, milliEther(400)))
+	if err != nil {
+		return err
+	}
+	mintHeader := sim.commit()
+	sim.expect(TypeDeposit, sim.erc20Token, &simExpect{tx: deposit, header: depositHeader, user: user, amount: milliEther(400), famount: 0.4, matched: true})
+	sim.expect(TypeMint, sim.bridgeToken, &simExpect{tx: mint, header: mintHeader, user: user, amount: milliEther(400), famount: 0.4, refTx: deposit.Hash(), matched: true})
+	sim.scan(mintHeader.Number.Uint64(), mintHeader.Number.Uint64()+1)
+	if err = sim.verifyAnomalies(map[string]bool{
+		mongodb.AnomalyTransferWithoutBurn + ":" + strings.ToLower(transfer.Hash().Hex()): false,
+		mongodb.AnomalyMintWithoutDeposit + ":" + strings.ToLower(fakeMint.Hash().Hex()):  false,
+		mongodb.AnomalyMintWithoutDeposit + ":" + strings.ToLower(mint.Hash().Hex()):      false,
+	}); err != nil {
+		return fmt.Errorf("before deposit: %w", err)
+	}
+	sim.scan(depositHeader.Number.Uint64(), depositHeader.Number.Uint64()+1)
+	return sim.verifyAnomalies(map[string]bool{
+		mongodb.AnomalyTransferWithoutBurn + ":" + strings.ToLower(transfer.Hash().Hex()): false,
+		mongodb.AnomalyMintWithoutDeposit + ":" + strings.ToLower(fakeMint.Hash().Hex()):  false,
+		mongodb.AnomalyMintWithoutDeposit + ":" + strings.ToLower(mint.Hash().Hex()):      true,
+	})
+}
+
+// verify the detected anomalies are exactly the expected ones, key -> resolved
+func (sim *simulation) verifyAnomalies(want map[string]bool) error {
+	have := make(map[string]bool)
+	for _, anomaly := range sim.db.GetAnomalies() {
+		have[anomaly.Key] = anomaly.Resolved
+	}
+	if !reflect.DeepEqual(have, want) {
+		return fmt.Errorf("anomalies mismatch\nhave: %v\nwant: %v", have, want)
+	}
+	return nil
+}
+
+// every tx sent from or to the deposit address is recorded in address watch mode,
+// and the token transfers to it by others are recorded if receipts are scanned
+func simAddressWatchScenario(sim *simulation) error {
+	sim.scanner.watchAddresses = true
+	from := sim.latestHeight() + 1
+	erc20 := common.HexToAddress(sim.erc20Token.TokenAddress)
+	bridge := common.HexToAddress(sim.bridgeToken.TokenAddress)
+	mpc := sim.address(simErc20MPC)
+	user := sim.address(simUser)
+	other := sim.address(simOther)
+
+	out, err := sim.sendTx(simErc20MPC, &other, milliEther(200), nil)
+	if err != nil {
+		return err
+	}
+	in, err := sim.sendTx(simUser, &mpc, milliEther(100), nil)
+	if err != nil {
+		return err
+	}
+	approve, err := sim.sendTx(simErc20MPC, &erc20, nil, simCallData(common.FromHex("0x095ea7b3"), simArguments("address", "uint256"), other, milliEther(5)))
+	if err != nil {
+		return err
+	}
+	// the erc20 token reverts unknown methods, such as mint(address,uint256)
+	mint, err := sim.sendTx(simErc20MPC, &erc20, nil, simCallData(common.FromHex("0x40c10f19"), simArguments("address", "uint256"), other, milliEther(5)))
+	if err != nil {
+		return err
+	}
+	create, err := sim.sendTx(simErc20MPC, nil, nil, evmDeployCode(mockBridgeTokenCode()))
+	if err != nil {
+		return err
+	}
+	redeem, err := sim.sendTx(simErc20MPC, &erc20, nil, transferCallData(user, milliEther(700)))
+	if err != nil {
+		return err
+	}
+	transfer, err := sim.sendTx(simUser, &bridge, nil, transferCallData(mpc, milliEther(40)))
+	if err != nil {
+		return err
+	}
+	header := sim.commit()
+	sim.expect(TypeRedeemed, sim.erc20Token, &simExpect{tx: redeem, header: header, user: user, amount: milliEther(700), famount: 0.7})
+	sim.scanFrom(from)
+
+	lower := func(address common.Address) string { return strings.ToLower(address.Hex()) }
+	expectTx := func(tx *types.Transaction, txFrom common.Address, status string, transfers ...*mongodb.TokenTransfer) *mongodb.AddressTx {
+		txHash := strings.ToLower(tx.Hash().Hex())
+		addressTx := &mongodb.AddressTx{
+			Key:            simChainName + ":" + txHash + ":" + lower(mpc),
+			Chain:          simChainName,
+			Address:        lower(mpc),
+			TxHash:         txHash,
+			BlockNumber:    header.Number.Int64(),
+			BlockTime:      int64(header.Time),
+			From:           lower(txFrom),
+			Value:          tx.Value().String(),
+			Status:         status,
+			TokenTransfers: transfers,
+		}
+		if tx.To() != nil {
+			addressTx.To = lower(*tx.To())
+			if len(tx.Data()) >= 4 {
+				addressTx.Selector = hexutil.Encode(tx.Data()[:4])
+			}
+		}
+		return addressTx
+	}
+	want := []*mongodb.AddressTx{
+		expectTx(out, mpc, mongodb.AddressTxSuccess),
+		expectTx(in, user, mongodb.AddressTxSuccess),
+		expectTx(approve, mpc, mongodb.AddressTxSuccess),
+		expectTx(mint, mpc, mongodb.AddressTxFailed),
+		expectTx(create, mpc, mongodb.AddressTxSuccess),
+		expectTx(redeem, mpc, mongodb.AddressTxSuccess,
+			&mongodb.TokenTransfer{Token: lower(erc20), From: lower(mpc), To: lower(user), Amount: milliEther(700).String()}),
+	}
+	if sim.scanReceipt {
+		want = append(want, expectTx(transfer, user, mongodb.AddressTxSuccess,
+			&mongodb.TokenTransfer{Token: lower(bridge), From: lower(user), To: lower(mpc), Amount: milliEther(40).String()}))
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i].Key < want[j].Key })
+	have := sim.db.GetAddressTxs()
+	if !reflect.DeepEqual(have, want) {
+		return fmt.Errorf("address txs mismatch\nhave: %v\nwant: %v", spew.Sdump(have), spew.Sdump(want))
+	}
+	return nil
+}
+
+// redeem erc20 from the redeem address, and rotate the native deposit address,
+// the old one is still effective in the block where the new one is effective from,
+// and the funds are swept between the mpc addresses
+func simMPCAddressesScenario(sim *simulation) error {
+	rotateHeight := sim.latestHeight() + 2
+	sim.erc20Token.RedeemAddress = sim.address(simRedeemMPC).Hex()
+	sim.nativeToken.DepositAddresses = []*params.MPCAddress{
+		{Address: sim.nativeToken.DepositAddress, ToHeight: rotateHeight},
+		{Address: sim.address(simNewMPC).Hex(), FromHeight: rotateHeight},
+	}
+	sim.nativeToken.DepositAddress = ""
+
+	from := sim.latestHeight() + 1
+	erc20 := common.HexToAddress(sim.erc20Token.TokenAddress)
+	user := sim.address(simUser)
+	other := sim.address(simOther)
+	oldMPC := sim.address(simNativeMPC)
+	newMPC := sim.address(simNewMPC)
+	redeemMPC := sim.address(simRedeemMPC)
+
+	redeem, err := sim.sendTx(simRedeemMPC, &erc20, nil, transferCallData(user, milliEther(600)))
+	if err != nil {
+		return err
+	}
+	mpcRedeem, err := sim.sendTx(simErc20MPC, &erc20, nil, transferCallData(user, milliEther(100)))
+	if err != nil {
+		return err
+	}
+	// the redeem address is not for deposit
+	if _, err = sim.sendTx(simUser, &erc20, nil, transferCallData(redeemMPC, milliEther(50))); err != nil {
+		return err
+	}
+	oldDeposit, err := sim.sendTx(simUser, &oldMPC, milliEther(300), nil)
+	if err != nil {
+		return err
+	}
+	// the new deposit address is not effective yet
+	if _, err = sim.sendTx(simUser, &newMPC, milliEther(20), nil); err != nil {
+		return err
+	}
+	header := sim.commit()
+	sim.expect(TypeRedeemed, sim.erc20Token, &simExpect{tx: redeem, header: header, user: user, amount: milliEther(600), famount: 0.6, mpc: redeemMPC})
+	sim.expect(TypeRedeemed, sim.erc20Token, &simExpect{tx: mpcRedeem, header: header, user: user, amount: milliEther(100), famount: 0.1})
+	sim.expect(TypeDeposit, sim.nativeToken, &simExpect{tx: oldDeposit, header: header, user: user, amount: milliEther(300), famount: 0.3, mpc: oldMPC})
+
+	newDeposit, err := sim.sendTx(simUser, &newMPC, milliEther(400), nil)
+	if err != nil {
+		return err
+	}
+	lateDeposit, err := sim.sendTx(simUser, &oldMPC, milliEther(30), nil)
+	if err != nil {
+		return err
+	}
+	newRedeem, err := sim.sendTx(simNewMPC, &user, milliEther(10), nil)
+	if err != nil {
+		return err
+	}
+	header = sim.commit()
+	sim.expect(TypeDeposit, sim.nativeToken, &simExpect{tx: newDeposit, header: header, user: user, amount: milliEther(400), famount: 0.4, mpc: newMPC})
+	sim.expect(TypeDeposit, sim.nativeToken, &simExpect{tx: lateDeposit, header: header, user: user, amount: milliEther(30), famount: 0.03, mpc: oldMPC})
+	sim.expect(TypeRedeemed, sim.nativeToken, &simExpect{tx: newRedeem, header: header, user: user, amount: milliEther(10), famount: 0.01, mpc: newMPC})
+
+	// the old deposit address is retired
+	if _, err = sim.sendTx(simUser, &oldMPC, milliEther(40), nil); err != nil {
+		return err
+	}
+	if _, err = sim.sendTx(simNativeMPC, &other, milliEther(5), nil); err != nil {
+		return err
+	}
+	// the sweeps between mpc addresses are neither swaps nor anomalies
+	var sweeps []*types.Transaction
+	for _, sweep := range []struct {
+		from  string
+		to    *common.Address
+		value *big.Int
+		data  []byte
+	}{
+		{simNativeMPC, &newMPC, milliEther(100), nil},
+		{simErc20MPC, &erc20, nil, transferCallData(redeemMPC, milliEther(200))},
+		{simRedeemMPC, &erc20, nil, transferCallData(sim.address(simErc20MPC), milliEther(50))},
+	} {
+		tx, err := sim.sendTx(sweep.from, sweep.to, sweep.value, sweep.data)
+		if err != nil {
+			return err
+		}
+		sweeps = append(sweeps, tx)
+	}
+	sim.commit()
+
+	sim.scanFrom(from)
+	for _, anomaly := range sim.db.GetAnomalies() {
+		for _, sweep := range sweeps {
+			if swapEventTxHash(anomaly.TxHash) == strings.ToLower(sweep.Hash().Hex()) {
+				return fmt.Errorf("sweep %v is anomaly %v", anomaly.TxHash, anomaly.Kind)
+			}
+		}
+	}
+	return nil
+}
+
+// transfers from mpc address which are not redeems are anomalies,
+// the transfer by allowance in tx sent by others is found if receipts are scanned
+func simUnclassifiedTransferScenario(sim *simulation) error {
+	from := sim.latestHeight() + 1
+	erc20 := common.HexToAddress(sim.erc20Token.TokenAddress)
+	mpc := sim.address(simErc20MPC)
+	other := sim.address(simOther)
+
+	approve := simCallData(common.FromHex("0x095ea7b3"), simArguments("address", "uint256"), other, milliEther(50))
+	if _, err := sim.sendTx(simErc20MPC, &erc20, nil, approve); err != nil {
+		return err
+	}
+	sim.commit()
+	transferFrom := simCallData(transferFromFuncHash, simArguments("address", "address", "uint256"), mpc, other, milliEther(50))
+	pull, err := sim.sendTx(simOther, &erc20, nil, transferFrom)
+	if err != nil {
+		return err
+	}
+	// the deposit address of erc20 token is not for native
+	native, err := sim.sendTx(simErc20MPC, &other, milliEther(20), nil)
+	if err != nil {
+		return err
+	}
+	// the failed transfer is not anomaly, the erc20 token reverts unknown methods
+	if _, err = sim.sendTx(simErc20MPC, &erc20, milliEther(10), common.FromHex("0x40c10f19")); err != nil {
+		return err
+	}
+	sim.commit()
+	sim.scanFrom(from)
+
+	want := map[string]bool{
+		mongodb.AnomalyUnclassifiedTransfer + ":" + strings.ToLower(native.Hash().Hex()): false,
+	}
+	if sim.scanReceipt {
+		receipt, err := sim.backend.TransactionReceipt(context.Background(), pull.Hash())
+		if err != nil {
+			return err
+		}
+		want[mongodb.AnomalyUnclassifiedTransfer+":"+swapEventKey(strings.ToLower(pull.Hash().Hex()), receipt.Logs[0].Index)] = false
+	}
+	return sim.verifyAnomalies(want)
+}
+
+// run scenario on new simulated chain, return the verify error
+func runSimScenario(ctx context.Context, scenario *simScenario, scanReceipt bool) error {
+	sim, err := newSimulation(ctx, scanReceipt)
+	if err != nil {
+		return fmt.Errorf("init simulation failed: %w", err)
+	}
+	defer sim.close()
+
+	oldAPI := dbAPI
+	dbAPI = sim.db
+	defer func() { dbAPI = oldAPI }()
+
+	sim.scanner.initTokenMetas()
+	if err = scenario.run(sim); err != nil {
+		return err
+	}
+	log.Info("simulation scenario finished", "scenario", scenario.name, "scanReceipt", scanReceipt, "height", sim.latestHeight())
+	return sim.verify()
+}
+
+func TestSimulation(t *testing.T) {
+	for _, scenario := range simScenarios {
+		for _, scanReceipt := range []bool{false, true} {
+			scenario, scanReceipt := scenario, scanReceipt
+			t.Run(fmt.Sprintf("%v/scanReceipt=%v", scenario.name, scanReceipt), func(t *testing.T) {
+				if err := runSimScenario(context.Background(), scenario, scanReceipt); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
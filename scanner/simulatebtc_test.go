@@ -0,0 +1,344 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/btcsuite/btcutil/bech32"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+const simBTCChainName = "simulated-btc"
+
+// simBTCGateway stand-in of the electrs REST api, which serves the blocks of a simulated bitcoin like chain
+type simBTCGateway struct {
+	lock    sync.Mutex
+	blocks  []*simBTCBlock // the block of height i+1 at index i
+	failing uint64         // the block of this height is not served if not zero
+}
+
+type simBTCBlock struct {
+	block esploraBlock
+	txs   []*esploraTx
+}
+
+// add block of the next height, the first tx is coinbase
+func (g *simBTCGateway) addBlock(timestamp int64, txs ...*esploraTx) *esploraBlock {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	height := uint64(len(g.blocks) + 1)
+	coinbase := &esploraTx{
+		TxID: simBTCHash(fmt.Sprintf("coinbase %v", height)),
+		Vin:  []*esploraVin{{IsCoinbase: true}},
+		Vout: []*esploraVout{{ScriptPubKeyAddress: simBTCAddress("miner", true), Value: 625000000}},
+	}
+	b := &simBTCBlock{
+		block: esploraBlock{
+			ID:        simBTCHash(fmt.Sprintf("block %v", height)),
+			Height:    height,
+			Timestamp: timestamp,
+			TxCount:   len(txs) + 1,
+		},
+		txs: append([]*esploraTx{coinbase}, txs...),
+	}
+	g.blocks = append(g.blocks, b)
+	return &b.block
+}
+
+func (g *simBTCGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "blocks" && parts[1] == "tip" && parts[2] == "height":
+		fmt.Fprint(w, len(g.blocks))
+		return
+	case len(parts) == 2 && parts[0] == "block-height":
+		height, err := strconv.Atoi(parts[1])
+		if err == nil && height > 0 && height <= len(g.blocks) {
+			fmt.Fprint(w, g.blocks[height-1].block.ID)
+			return
+		}
+	case len(parts) == 2 && parts[0] == "block":
+		if b := g.getBlock(parts[1]); b != nil && b.block.Height != g.failing {
+			_ = json.NewEncoder(w).Encode(&b.block)
+			return
+		}
+	case len(parts) == 4 && parts[0] == "block" && parts[2] == "txs":
+		start, err := strconv.Atoi(parts[3])
+		if b := g.getBlock(parts[1]); b != nil && err == nil && start >= 0 && start < len(b.txs) {
+			end := start + esploraTxsPageSize
+			if end > len(b.txs) {
+				end = len(b.txs)
+			}
+			_ = json.NewEncoder(w).Encode(b.txs[start:end])
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+func (g *simBTCGateway) setFailing(height uint64) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.failing = height
+}
+
+func (g *simBTCGateway) getBlock(hash string) *simBTCBlock {
+	for _, b := range g.blocks {
+		if b.block.ID == hash {
+			return b
+		}
+	}
+	return nil
+}
+
+func simBTCHash(name string) string {
+	return crypto.Keccak256Hash([]byte("bridgeAccounting simulation btc " + name)).Hex()[2:]
+}
+
+// P2WPKH or P2PKH address of btc mainnet, the public key hash is derived from the name deterministically
+func simBTCAddress(name string, segwit bool) string {
+	pubKeyHash := crypto.Keccak256([]byte("bridgeAccounting simulation btc " + name))[:20]
+	if !segwit {
+		return base58.CheckEncode(pubKeyHash, 0x00)
+	}
+	data, err := bech32.ConvertBits(pubKeyHash, 8, 5, true)
+	if err != nil {
+		panic(err)
+	}
+	address, err := bech32.Encode("bc", append([]byte{0}, data...))
+	if err != nil {
+		panic(err)
+	}
+	return address
+}
+
+// tx spending the inputs to the outputs, the amounts are in satoshi
+func simBTCTx(name string, fee uint64, inputs []*esploraVout, outputs ...*esploraVout) *esploraTx {
+	tx := &esploraTx{TxID: simBTCHash(name), Vout: outputs, Fee: fee}
+	for _, input := range inputs {
+		tx.Vin = append(tx.Vin, &esploraVin{Prevout: input})
+	}
+	return tx
+}
+
+// deposit to and redeem from the mpc addresses on bitcoin like chain served by the stand-in electrs api,
+// the deposit is minted and the redeem is burned on the simulated chain. only the stable blocks are scanned,
+// the redeem is an anomaly until its burn is scanned on the simulated chain. the batched redeem is recorded
+// per output, its block fails to be served until the block gap is healed.
+func simBTCChainScenario(sim *simulation) error {
+	depositMPC := simBTCAddress(simNativeMPC, true) // P2WPKH
+	redeemMPC := simBTCAddress(simRedeemMPC, false) // P2PKH
+	btcUser := simBTCAddress(simUser, false)
+	btcOther := simBTCAddress(simOther, false)
+	btcBind := simBTCAddress(simOther, true)
+	btcBatchBind := simBTCAddress("batch", true)
+	user := sim.address(simUser)
+
+	btcToken := &params.TokenConfig{
+		Chain:          simBTCChainName,
+		IsSrcToken:     true,
+		PairID:         "btc",
+		TokenAddress:   "native",
+		DepositAddress: depositMPC,
+		RedeemAddress:  redeemMPC,
+	}
+	deployTx, err := sim.sendTx(simDeployer, nil, nil, evmDeployCode(mockBridgeTokenCode()))
+	if err != nil {
+		return err
+	}
+	sim.commit()
+	bridge, err := sim.deployedAddress(deployTx)
+	if err != nil {
+		return err
+	}
+	bridgeToken := &params.TokenConfig{
+		Chain:          simChainName,
+		IsSrcToken:     false,
+		PairID:         "btc",
+		TokenAddress:   bridge.Hex(),
+		DepositAddress: sim.address(simBridgeMPC).Hex(),
+		BindFormat:     params.BindFormatBTC,
+	}
+
+	deposit := simBTCTx("deposit", 1000, []*esploraVout{{btcUser, 2000000}},
+		&esploraVout{depositMPC, 1500000}, &esploraVout{btcUser, 499000})
+	redeem := simBTCTx("redeem", 2000, []*esploraVout{{redeemMPC, 5000000}},
+		&esploraVout{btcBind, 1000000}, &esploraVout{redeemMPC, 3998000})
+	sweep := simBTCTx("sweep", 1000, []*esploraVout{{depositMPC, 1500000}},
+		&esploraVout{redeemMPC, 1499000})
+	batch := simBTCTx("batch redeem", 3000, []*esploraVout{{redeemMPC, 3998000}},
+		&esploraVout{btcBatchBind, 500000}, &esploraVout{redeemMPC, 3195000}, &esploraVout{btcUser, 300000})
+	unstable := simBTCTx("unstable deposit", 1000, []*esploraVout{{btcUser, 499000}},
+		&esploraVout{depositMPC, 300000}, &esploraVout{btcUser, 198000})
+
+	// mint of the deposit and burn to the btc address on the simulated chain
+	from := sim.latestHeight() + 1
+	mint, err := sim.sendTx(simBridgeMPC, &bridge, nil, swapinCallData(common.HexToHash(deposit.TxID), user, milliEther(14)))
+	if err != nil {
+		return err
+	}
+	burn, err := sim.sendTx(simUser, &bridge, nil, stringSwapoutCallData(milliEther(12), strings.ToUpper(btcBind)))
+	if err != nil {
+		return err
+	}
+	batchBurns := make([]*types.Transaction, 0, 2)
+	for _, bind := range []string{btcBatchBind, btcUser} {
+		batchBurn, err := sim.sendTx(simUser, &bridge, nil, stringSwapoutCallData(milliEther(6), bind))
+		if err != nil {
+			return err
+		}
+		batchBurns = append(batchBurns, batchBurn)
+	}
+	header := sim.commit()
+
+	// the deposit is in the second page of block txs
+	gateway := &simBTCGateway{}
+	blockTime := int64(header.Time) + 600
+	txs := make([]*esploraTx, 0, esploraTxsPageSize+2)
+	for i := 0; i < esploraTxsPageSize+1; i++ {
+		txs = append(txs, simBTCTx(fmt.Sprintf("transfer %v", i), 500, []*esploraVout{{btcOther, 100000}}, &esploraVout{btcUser, 99500}))
+	}
+	depositBlock := gateway.addBlock(blockTime, append(txs, deposit)...)
+	redeemBlock := gateway.addBlock(blockTime+600, redeem, sweep)
+	batchBlock := gateway.addBlock(blockTime+1200, batch)
+	gateway.addBlock(blockTime+1800, unstable)
+	gateway.setFailing(batchBlock.Height)
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	scanner := newBTCSwapScanner(sim.scanner.ctx, &params.ChainConfig{
+		Name:         simBTCChainName,
+		ChainType:    params.ChainTypeBTC,
+		Gateways:     []string{server.URL},
+		StableHeight: 1,
+	})
+	scanner.tokens = []*params.TokenConfig{btcToken}
+	scanner.rpcInterval = 10 * time.Millisecond
+	if err = scanner.initClient(); err != nil {
+		return err
+	}
+	if next := scanner.scanStableBlocks(1, scanner.loopGetLatestBlockNumber()); next != 4 {
+		return fmt.Errorf("scanned to %v, want the stable height 3", next-1)
+	}
+	gaps, err := sim.db.GetBlockGaps(simBTCChainName)
+	if err != nil || len(gaps) != 1 || gaps[0].Start != int64(batchBlock.Height) || gaps[0].End != int64(batchBlock.Height)+1 {
+		return fmt.Errorf("gap of the failed block is not recorded: %v, err %v", gaps, err)
+	}
+	if _, err = sim.db.GetRedeemed(btcToken, swapEventKey("0x"+batch.TxID, 0)); err == nil {
+		return fmt.Errorf("batched redeem is recorded before the gap is healed")
+	}
+	gateway.setFailing(0)
+	scanner.healGaps()
+	if gaps, err = sim.db.GetBlockGaps(simBTCChainName); err != nil || len(gaps) != 0 {
+		return fmt.Errorf("gap of the failed block is not healed: %v, err %v", gaps, err)
+	}
+	scanner.settleSwapEvents()
+	if event, err := sim.db.GetRedeemed(btcToken, "0x"+redeem.TxID); err != nil || event.Matched {
+		return fmt.Errorf("redeem is matched before its burn is recorded, err %v", err)
+	}
+	syncInfo, err := sim.db.GetSyncInfo(simBTCChainName)
+	if err != nil || syncInfo.SyncedHeight != 3 || syncInfo.ConfirmedHeight != 3 {
+		return fmt.Errorf("sync info of btc chain mismatch: %+v, err %v", syncInfo, err)
+	}
+
+	evmScanner := sim.scanner.cloneForTokens([]*params.TokenConfig{bridgeToken}, sim.latestHeight()+1)
+	evmScanner.doScanRangeJob(from, sim.latestHeight()+1)
+	evmScanner.settleSwapEvents()
+	scanner.settleSwapEvents()
+
+	sim.expectEvent(TypeDeposit, btcToken, &mongodb.SwapEvent{
+		TxHash:      "0x" + deposit.TxID,
+		BlockTime:   depositBlock.Timestamp,
+		BlockNumber: int64(depositBlock.Height),
+		Amount:      "1500000",
+		Decimals:    btcDecimals,
+		FAmount:     0.015,
+		User:        btcUser,
+		TxFee:       "1000",
+		FTxFee:      0.00001,
+		Matched:     true,
+		MPCAddress:  depositMPC,
+	})
+	sim.expectEvent(TypeRedeemed, btcToken, &mongodb.SwapEvent{
+		TxHash:      "0x" + redeem.TxID,
+		BlockTime:   redeemBlock.Timestamp,
+		BlockNumber: int64(redeemBlock.Height),
+		Amount:      "1000000",
+		Decimals:    btcDecimals,
+		FAmount:     0.01,
+		User:        btcBind,
+		RefTxHash:   strings.ToLower(burn.Hash().Hex()),
+		TxFee:       "2000",
+		FTxFee:      0.00002,
+		Matched:     true,
+		MPCAddress:  redeemMPC,
+	})
+	sim.expectEvent(TypeRedeemed, btcToken, &mongodb.SwapEvent{
+		TxHash:      swapEventKey("0x"+batch.TxID, 0),
+		BlockTime:   batchBlock.Timestamp,
+		BlockNumber: int64(batchBlock.Height),
+		Amount:      "500000",
+		Decimals:    btcDecimals,
+		FAmount:     0.005,
+		User:        btcBatchBind,
+		RefTxHash:   strings.ToLower(batchBurns[0].Hash().Hex()),
+		TxFee:       "3000",
+		FTxFee:      0.00003,
+		Matched:     true,
+		MPCAddress:  redeemMPC,
+	})
+	sim.expectEvent(TypeRedeemed, btcToken, &mongodb.SwapEvent{
+		TxHash:      swapEventKey("0x"+batch.TxID, 2),
+		BlockTime:   batchBlock.Timestamp,
+		BlockNumber: int64(batchBlock.Height),
+		Amount:      "300000",
+		Decimals:    btcDecimals,
+		FAmount:     0.003,
+		User:        btcUser,
+		RefTxHash:   strings.ToLower(batchBurns[1].Hash().Hex()),
+		Matched:     true,
+		MPCAddress:  redeemMPC,
+	})
+	sim.expect(TypeMint, bridgeToken, &simExpect{tx: mint, header: header, user: user, amount: milliEther(14), famount: 0.014,
+		refTx: common.HexToHash(deposit.TxID), matched: true, bridgeFee: 0.001})
+	sim.expect(TypeBurn, bridgeToken, &simExpect{tx: burn, header: header, user: user, amount: milliEther(12), famount: 0.012,
+		bind: btcBind, matched: true})
+	for i, bind := range []string{btcBatchBind, btcUser} {
+		sim.expect(TypeBurn, bridgeToken, &simExpect{tx: batchBurns[i], header: header, user: user, amount: milliEther(6), famount: 0.006,
+			bind: bind, matched: true})
+	}
+	checks := []struct {
+		tokenCfg   *params.TokenConfig
+		swapTxType SwapTxType
+	}{
+		{btcToken, TypeDeposit},
+		{btcToken, TypeRedeemed},
+		{bridgeToken, TypeMint},
+		{bridgeToken, TypeBurn},
+	}
+	for _, check := range checks {
+		if err = sim.verifySwapEvents(check.swapTxType, check.tokenCfg); err != nil {
+			return err
+		}
+	}
+
+	return sim.verifyAnomalies(map[string]bool{
+		mongodb.AnomalyTransferWithoutBurn + ":0x" + redeem.TxID:                    true,
+		mongodb.AnomalyTransferWithoutBurn + ":" + swapEventKey("0x"+batch.TxID, 0): true,
+		mongodb.AnomalyTransferWithoutBurn + ":" + swapEventKey("0x"+batch.TxID, 2): true,
+	})
+}
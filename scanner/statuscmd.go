@@ -0,0 +1,87 @@
+package scanner
+
+import (
+	"fmt"
+
+	"github.com/anyswap/CrossChain-Bridge/cmd/utils"
+	"github.com/urfave/cli/v2"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+var (
+	// StatusCommand print scan status
+	StatusCommand = &cli.Command{
+		Action:    status,
+		Name:      "status",
+		Usage:     "print scan status of chains",
+		ArgsUsage: " ",
+		Description: `
+print synced and confirmed height, token metadata, token backfill progress and outstanding block gaps of chains,
+the chain is specified by '--chain', default is all chains in config.
+`,
+		Flags: []cli.Flag{
+			utils.ConfigFileFlag,
+			chainFlag,
+		},
+	}
+)
+
+func status(ctx *cli.Context) error {
+	utils.SetLogger(ctx)
+	cfg := params.LoadConfig(utils.GetConfigFilePath(ctx))
+
+	chainCfgs := cfg.Chains
+	if chain := ctx.String(chainFlag.Name); chain != "" {
+		chainCfg := cfg.GetChainConfig(chain)
+		if chainCfg == nil {
+			return fmt.Errorf("chain config not found: %v", chain)
+		}
+		chainCfgs = []*params.ChainConfig{chainCfg}
+	}
+
+	mongodb.MongoServerInit(ctx.Context, cfg, cfg.MongoDB.DBURLs, cfg.MongoDB.DBName, cfg.MongoDB.UserName, cfg.MongoDB.Password)
+	queryAPI := mongodb.NewQueryAPI()
+
+	for _, chainCfg := range chainCfgs {
+		fmt.Printf("chain: %v\n", chainCfg.Name)
+		if syncInfo, err := queryAPI.GetSyncInfo(chainCfg.Name); err == nil {
+			fmt.Printf("  startHeight: %v\n  syncedHeight: %v\n  confirmedHeight: %v (finality %v)\n", syncInfo.StartHeight, syncInfo.SyncedHeight, syncInfo.ConfirmedHeight, chainCfg.GetFinalityMode())
+		} else {
+			fmt.Printf("  syncInfo: %v\n", err)
+		}
+		if metas, err := queryAPI.GetTokenMetas(chainCfg.Name); err == nil {
+			for _, meta := range metas {
+				fmt.Printf("  token %v: %v decimals %v symbol '%v' name '%v'\n", meta.PairID, meta.TokenAddress, meta.Decimals, meta.Symbol, meta.Name)
+			}
+		} else {
+			fmt.Printf("  tokens: %v\n", err)
+		}
+		for _, tokenCfg := range cfg.GetTokenConfigs(chainCfg.Name) {
+			if !tokenCfg.NeedBackfill() {
+				continue
+			}
+			tokenSyncInfo, err := queryAPI.GetTokenSyncInfo(tokenCfg)
+			if err != nil {
+				fmt.Printf("  backfill %v: not started\n", tokenCfg.PairID)
+				continue
+			}
+			fmt.Printf("  backfill %v: range [%v, %v) synced %v\n", tokenCfg.PairID, tokenSyncInfo.StartHeight, tokenSyncInfo.EndHeight, tokenSyncInfo.SyncedHeight)
+		}
+		gaps, err := queryAPI.GetBlockGaps(chainCfg.Name)
+		if err != nil {
+			fmt.Printf("  gaps: %v\n", err)
+			continue
+		}
+		fmt.Printf("  gaps: %v\n", len(gaps))
+		for _, gap := range gaps {
+			if gap.TxIndex > 0 {
+				fmt.Printf("    [%v, %v) from tx index %v, %v\n", gap.Start, gap.End, gap.TxIndex, gap.Reason)
+				continue
+			}
+			fmt.Printf("    [%v, %v) %v\n", gap.Start, gap.End, gap.Reason)
+		}
+	}
+	return nil
+}
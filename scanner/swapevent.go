@@ -0,0 +1,188 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+
+	"github.com/gaozhengxin/bridgeAccounting/alert"
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+func (t SwapTxType) String() string {
+	switch t {
+	case TypeDeposit:
+		return "Deposit"
+	case TypeMint:
+		return "Mint"
+	case TypeBurn:
+		return "Burn"
+	case TypeRedeemed:
+		return "Redeemed"
+	default:
+		return "Null"
+	}
+}
+
+func getSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, txHash string) (*mongodb.SwapEvent, error) {
+	switch swapTxType {
+	case TypeDeposit:
+		return dbAPI.GetDeposit(tokenCfg, txHash)
+	case TypeMint:
+		return dbAPI.GetMint(tokenCfg, txHash)
+	case TypeBurn:
+		return dbAPI.GetBurn(tokenCfg, txHash)
+	case TypeRedeemed:
+		return dbAPI.GetRedeemed(tokenCfg, txHash)
+	default:
+		return nil, fmt.Errorf("invalid swap tx type: %v", swapTxType)
+	}
+}
+
+func addSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) (err error) {
+	switch swapTxType {
+	case TypeDeposit:
+		err = dbAPI.AddDeposit(tokenCfg, data)
+	case TypeMint:
+		err = dbAPI.AddMint(tokenCfg, data)
+	case TypeBurn:
+		err = dbAPI.AddBurn(tokenCfg, data)
+	case TypeRedeemed:
+		err = dbAPI.AddRedeemed(tokenCfg, data)
+	default:
+		return fmt.Errorf("invalid swap tx type: %v", swapTxType)
+	}
+	if err == nil {
+		markDirtyHeight(tokenCfg.Chain, data.BlockNumber)
+		if swapTxType == TypeMint {
+			markDepositMatched(tokenCfg, data)
+		}
+	}
+	return err
+}
+
+func updateSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) (err error) {
+	switch swapTxType {
+	case TypeDeposit:
+		err = dbAPI.UpdateDeposit(tokenCfg, data)
+	case TypeMint:
+		err = dbAPI.UpdateMint(tokenCfg, data)
+	case TypeBurn:
+		err = dbAPI.UpdateBurn(tokenCfg, data)
+	case TypeRedeemed:
+		err = dbAPI.UpdateRedeemed(tokenCfg, data)
+	default:
+		return fmt.Errorf("invalid swap tx type: %v", swapTxType)
+	}
+	if err == nil {
+		markDirtyHeight(tokenCfg.Chain, data.BlockNumber)
+		if swapTxType == TypeMint {
+			markDepositMatched(tokenCfg, data)
+		}
+	}
+	return err
+}
+
+// the summaries containing the height are made again, as the swap events in it are changed
+func markDirtyHeight(chain string, height int64) {
+	if err := dbAPI.MarkDirtyHeight(chain, height); err != nil {
+		log.Warn("mark dirty height failed", "chain", chain, "height", height, "err", err)
+	}
+}
+
+// rescanStats counts of rescanned swap events
+type rescanStats struct {
+	newCount      uint64
+	existingCount uint64
+	changedCount  uint64
+	failedCount   uint64
+}
+
+func (scanner *ethSwapScanner) recordSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) {
+	if scanner.rescanStats != nil {
+		scanner.recordRescannedSwapEvent(swapTxType, tokenCfg, data)
+		return
+	}
+	err := addSwapEvent(swapTxType, tokenCfg, data)
+	switch {
+	case err == nil:
+		alert.OnSwapEvent(tokenCfg.PairID, swapTxType.String(), data)
+	case strings.Contains(err.Error(), swapExistKeywords):
+		scanner.updateReorgedSwapEvent(swapTxType, tokenCfg, data)
+	default:
+		log.Warn("Add swap event error", "swapTxType", swapTxType, "syncError", err)
+	}
+}
+
+// the swap tx may be packed into another block after reorg,
+// update the existing swap event if its block is changed
+func (scanner *ethSwapScanner) updateReorgedSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) {
+	existing, err := getSwapEvent(swapTxType, tokenCfg, data.TxHash)
+	if err != nil {
+		return
+	}
+	keepDerivedFields(existing, data)
+	if *existing == *data {
+		return
+	}
+	log.Info("update reorged swap event", "pairID", tokenCfg.PairID, "swapTxType", swapTxType, "txHash", data.TxHash, "oldBlock", existing.BlockNumber, "newBlock", data.BlockNumber)
+	if err = updateSwapEvent(swapTxType, tokenCfg, data); err != nil {
+		log.Warn("update reorged swap event error", "swapTxType", swapTxType, "txHash", data.TxHash, "err", err)
+		return
+	}
+	markDirtyHeight(tokenCfg.Chain, existing.BlockNumber)
+}
+
+// keep the fields of existing swap event which are set after it is recorded,
+// the confirmed one is kept if the new one is in the same block,
+// as the finalized height used when it is recorded may be stale
+func keepDerivedFields(existing, data *mongodb.SwapEvent) {
+	if !existing.Unconfirmed && existing.BlockNumber == data.BlockNumber {
+		data.Unconfirmed = false
+	}
+	if existing.Matched && !data.Matched {
+		data.Matched = true
+		data.BridgeFee = existing.BridgeFee
+		if data.RefTxHash == "" {
+			data.RefTxHash = existing.RefTxHash // the burn of redeem
+		}
+	}
+}
+
+// compare with the existing swap event, add or update it if not dry run
+func (scanner *ethSwapScanner) recordRescannedSwapEvent(swapTxType SwapTxType, tokenCfg *params.TokenConfig, data *mongodb.SwapEvent) {
+	stats := scanner.rescanStats
+	var writeErr error
+	existing, err := getSwapEvent(swapTxType, tokenCfg, data.TxHash)
+	if err == nil {
+		keepDerivedFields(existing, data)
+	}
+	switch {
+	case err != nil:
+		log.Info("rescan found new swap event", "pairID", tokenCfg.PairID, "swapTxType", swapTxType, "txHash", data.TxHash)
+		atomic.AddUint64(&stats.newCount, 1)
+		if !scanner.dryRun {
+			writeErr = addSwapEvent(swapTxType, tokenCfg, data)
+			if writeErr == nil {
+				alert.OnSwapEvent(tokenCfg.PairID, swapTxType.String(), data)
+			}
+		}
+	case *existing == *data:
+		atomic.AddUint64(&stats.existingCount, 1)
+	default:
+		log.Info("rescan found changed swap event", "pairID", tokenCfg.PairID, "swapTxType", swapTxType, "txHash", data.TxHash, "old", existing, "new", data)
+		atomic.AddUint64(&stats.changedCount, 1)
+		if !scanner.dryRun {
+			if writeErr = updateSwapEvent(swapTxType, tokenCfg, data); writeErr == nil {
+				markDirtyHeight(tokenCfg.Chain, existing.BlockNumber)
+			}
+		}
+	}
+	if writeErr != nil {
+		log.Warn("record rescanned swap event error", "swapTxType", swapTxType, "txHash", data.TxHash, "err", writeErr)
+		atomic.AddUint64(&stats.failedCount, 1)
+	}
+}
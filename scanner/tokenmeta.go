@@ -0,0 +1,148 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anyswap/CrossChain-Bridge/log"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	token "github.com/gaozhengxin/bridgeAccount/dev/token"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+const nativeDecimals = 18
+
+var errUnresolvedDecimals = errors.New("token decimals unresolved")
+
+// tokenMetaRegistry resolved token metadata, the key is token config key
+type tokenMetaRegistry struct {
+	lock  sync.RWMutex
+	metas map[string]*mongodb.TokenMeta
+}
+
+func newTokenMetaRegistry() *tokenMetaRegistry {
+	return &tokenMetaRegistry{metas: make(map[string]*mongodb.TokenMeta)}
+}
+
+func (r *tokenMetaRegistry) get(key string) *mongodb.TokenMeta {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.metas[key]
+}
+
+func (r *tokenMetaRegistry) set(meta *mongodb.TokenMeta) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metas[meta.Key] = meta
+}
+
+// get token metadata from the registry, the database or the token contract in order,
+// the newly resolved metadata is persisted unless dry run. return error if the decimals are unresolved.
+func (scanner *ethSwapScanner) getTokenMeta(tokenCfg *params.TokenConfig) (*mongodb.TokenMeta, error) {
+	if meta := scanner.tokenMetas.get(tokenCfg.Key()); meta != nil {
+		return meta, nil
+	}
+	meta, changed, err := scanner.resolveTokenMeta(tokenCfg)
+	if err != nil {
+		return nil, err
+	}
+	// the decimals in config take precedence
+	if tokenCfg.Decimal != 0 && meta.Decimals != tokenCfg.Decimal {
+		log.Warn("token decimals differ from config, use config", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "resolved", meta.Decimals, "config", tokenCfg.Decimal)
+		meta.Decimals = tokenCfg.Decimal
+		changed = true
+	}
+	if changed && !scanner.dryRun {
+		meta.Timestamp = time.Now().Unix()
+		if err = dbAPI.SetTokenMeta(meta); err != nil {
+			log.Warn("save token meta failed", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "err", err)
+		}
+	}
+	log.Info("resolve token meta success", "chain", meta.Chain, "pairID", meta.PairID, "token", meta.TokenAddress, "decimals", meta.Decimals, "symbol", meta.Symbol, "name", meta.Name)
+	scanner.tokenMetas.set(meta)
+	return meta, nil
+}
+
+// resolve token metadata, return changed if it is not loaded from database
+func (scanner *ethSwapScanner) resolveTokenMeta(tokenCfg *params.TokenConfig) (meta *mongodb.TokenMeta, changed bool, err error) {
+	if stored, err := dbAPI.GetTokenMeta(tokenCfg); err == nil {
+		return stored, false, nil
+	}
+	meta = &mongodb.TokenMeta{
+		Key:          tokenCfg.Key(),
+		Chain:        tokenCfg.Chain,
+		PairID:       tokenCfg.PairID,
+		TokenAddress: tokenCfg.TokenAddress,
+	}
+	if tokenCfg.IsNativeToken() {
+		meta.Decimals = nativeDecimals
+		return meta, true, nil
+	}
+	if err = scanner.fetchTokenMeta(meta); err != nil {
+		if tokenCfg.Decimal == 0 {
+			return nil, false, fmt.Errorf("%w: %v", errUnresolvedDecimals, err)
+		}
+		log.Warn("fetch token meta failed, use decimals in config", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "err", err)
+	}
+	return meta, true, nil
+}
+
+// contract caller of block source, nil if it does not support contract call
+func (scanner *ethSwapScanner) contractCaller() bind.ContractCaller {
+	if scanner.offline != nil {
+		caller, _ := scanner.offline.(bind.ContractCaller)
+		return caller
+	}
+	return scanner.client()
+}
+
+// fetch decimals, symbol and name from token contract,
+// only the decimals are required as symbol and name are not standard in early tokens
+func (scanner *ethSwapScanner) fetchTokenMeta(meta *mongodb.TokenMeta) (err error) {
+	if !common.IsHexAddress(meta.TokenAddress) {
+		return fmt.Errorf("wrong token address '%v'", meta.TokenAddress)
+	}
+	tokenAddress := common.HexToAddress(meta.TokenAddress)
+	var decimals uint8
+	for i := 0; i < scanner.rpcRetryCount; i++ { // with retry
+		caller := scanner.contractCaller()
+		if caller == nil {
+			return errors.New("block source does not support contract call")
+		}
+		var erc20 *token.TokenCaller
+		erc20, err = token.NewTokenCaller(tokenAddress, caller)
+		if err != nil {
+			return err
+		}
+		opts := &bind.CallOpts{Context: scanner.ctx}
+		if decimals, err = erc20.Decimals(opts); err != nil {
+			log.Warn("get token decimals failed", "chain", meta.Chain, "token", meta.TokenAddress, "err", err)
+			scanner.switchClient()
+			time.Sleep(scanner.rpcInterval)
+			continue
+		}
+		meta.Decimals = int(decimals)
+		if meta.Symbol, err = erc20.Symbol(opts); err != nil {
+			log.Warn("get token symbol failed", "chain", meta.Chain, "token", meta.TokenAddress, "err", err)
+		}
+		if meta.Name, err = erc20.Name(opts); err != nil {
+			log.Warn("get token name failed", "chain", meta.Chain, "token", meta.TokenAddress, "err", err)
+		}
+		return nil
+	}
+	return err
+}
+
+// resolve metadata of tokens before scanning, the unresolved ones are retried when their swaps are found
+func (scanner *ethSwapScanner) initTokenMetas() {
+	for _, tokenCfg := range scanner.getTokenConfigs() {
+		if _, err := scanner.getTokenMeta(tokenCfg); err != nil {
+			log.Warn("resolve token meta failed", "chain", tokenCfg.Chain, "pairID", tokenCfg.PairID, "err", err)
+		}
+	}
+}
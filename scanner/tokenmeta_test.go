@@ -0,0 +1,27 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+func TestGetTokenMetaPersistence(t *testing.T) {
+	db := newMemorySyncAPI()
+	defer func(api mongodb.SyncAPI) { dbAPI = api }(dbAPI)
+	dbAPI = db
+
+	for _, dryRun := range []bool{true, false} {
+		tokenCfg := &params.TokenConfig{Chain: "test", PairID: "eth", TokenAddress: "native"}
+		scanner := &ethSwapScanner{chain: "test", dryRun: dryRun, tokenMetas: newTokenMetaRegistry()}
+		meta, err := scanner.getTokenMeta(tokenCfg)
+		if err != nil || meta.Decimals != nativeDecimals {
+			t.Fatalf("token meta mismatch, have %+v err %v", meta, err)
+		}
+		_, err = db.GetTokenMeta(tokenCfg)
+		if stored := err == nil; stored == dryRun {
+			t.Errorf("token meta stored %v in dry run %v", stored, dryRun)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package scanner
+
+import (
+	"fmt"
+
+	"github.com/anyswap/CrossChain-Bridge/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli/v2"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+var (
+	fixFlag = &cli.BoolFlag{
+		Name:  "fix",
+		Usage: "add the swap event into database if it is missing",
+	}
+
+	// TxCommand inspect swap of single transaction
+	TxCommand = &cli.Command{
+		Action:    inspectTx,
+		Name:      "tx",
+		Usage:     "inspect cross chain swap of transaction",
+		ArgsUsage: "<txhash>",
+		Description: `
+verify transaction with every token config of the chain,
+print the matched swap and whether it exists in database.
+`,
+		Flags: []cli.Flag{
+			utils.ConfigFileFlag,
+			chainFlag,
+			fixFlag,
+		},
+	}
+)
+
+func inspectTx(ctx *cli.Context) error {
+	utils.SetLogger(ctx)
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("need exactly one tx hash argument")
+	}
+	txHash := common.HexToHash(ctx.Args().First())
+	cfg := params.LoadConfig(utils.GetConfigFilePath(ctx))
+
+	chainCfg, err := getEVMChainConfig(cfg, ctx.String(chainFlag.Name))
+	if err != nil {
+		return err
+	}
+
+	mongodb.MongoServerInit(ctx.Context, cfg, cfg.MongoDB.DBURLs, cfg.MongoDB.DBName, cfg.MongoDB.UserName, cfg.MongoDB.Password)
+	dbAPI = mongodb.NewSyncAPI()
+
+	scanner := newEthSwapScanner(ctx.Context, chainCfg)
+	scanner.archiveFile = "" // need gateway to get tx by hash
+	if err := scanner.initClient(); err != nil {
+		return err
+	}
+
+	tx, isPending, err := scanner.client().TransactionByHash(scanner.ctx, txHash)
+	if err != nil {
+		return fmt.Errorf("get transaction failed: %w", err)
+	}
+	if isPending {
+		return fmt.Errorf("transaction is pending")
+	}
+	if tx.To() == nil {
+		return fmt.Errorf("transaction is contract creation")
+	}
+	receipt, err := scanner.loopGetTxReceipt(txHash, common.Hash{})
+	if err != nil {
+		return fmt.Errorf("get transaction receipt failed: %w", err)
+	}
+	header, err := scanner.client().HeaderByNumber(scanner.ctx, receipt.BlockNumber)
+	if err != nil {
+		return fmt.Errorf("get block header failed: %w", err)
+	}
+
+	fmt.Printf("tx: %v\nchain: %v\nblock: %v\nstatus: %v\n", txHash.Hex(), chainCfg.Name, receipt.BlockNumber, receipt.Status)
+	matched := 0
+	for _, tokenCfg := range cfg.GetTokenConfigs(chainCfg.Name) {
+		swaps, verifyErr := scanner.verifyTransactionSwaps(tx, receipt, header, tokenCfg)
+		if len(swaps) == 0 && verifyErr == nil {
+			continue
+		}
+		if verifyErr != nil {
+			matched++
+			fmt.Printf("\npairID: %v\ntoken: %v\nverifyError: %v\n", tokenCfg.PairID, tokenCfg.TokenAddress, verifyErr)
+			continue
+		}
+		setTxFee(swaps[0].swapData, tx, receipt, header)
+		for _, swap := range swaps {
+			matched++
+			inspectSwap(ctx, scanner, tokenCfg, swap)
+		}
+	}
+	if matched == 0 {
+		fmt.Println("\nno token config matched")
+	}
+	return nil
+}
+
+func inspectSwap(ctx *cli.Context, scanner *ethSwapScanner, tokenCfg *params.TokenConfig, swap *txSwap) {
+	swapTxType := swap.txType
+	fmt.Printf("\npairID: %v\ntoken: %v\nswapTxType: %v\n", tokenCfg.PairID, tokenCfg.TokenAddress, swapTxType)
+	meta, err := scanner.getTokenMeta(tokenCfg)
+	if err != nil {
+		fmt.Printf("tokenMetaError: %v\n", err)
+		return
+	}
+	mgoSwapEvent := scanner.makeMgoSwapEvent(swapTxType, tokenCfg, swap.swapData, meta.Decimals)
+	if swap.swapData.Index != nil {
+		fmt.Printf("key: %v\n", mgoSwapEvent.TxHash)
+	}
+	fmt.Printf("user: %v\namount: %v\nfamount: %v\n", mgoSwapEvent.User, mgoSwapEvent.Amount, mgoSwapEvent.FAmount)
+	fmt.Printf("gasUsed: %v\ngasPrice: %v\ntxFee: %v\n", mgoSwapEvent.GasUsed, mgoSwapEvent.GasPrice, mgoSwapEvent.FTxFee)
+	if mgoSwapEvent.Bind != "" {
+		fmt.Printf("bind: %v\n", mgoSwapEvent.Bind)
+	}
+	if mgoSwapEvent.InvalidBind {
+		fmt.Println("invalidBind: true")
+	}
+	if mgoSwapEvent.RefTxHash != "" {
+		fmt.Printf("refTxHash: %v\n", mgoSwapEvent.RefTxHash)
+	}
+	if mgoSwapEvent.Matched {
+		fmt.Printf("bridgeFee: %v\n", mgoSwapEvent.BridgeFee)
+	}
+	if _, err = getSwapEvent(swapTxType, tokenCfg, mgoSwapEvent.TxHash); err == nil {
+		fmt.Println("inDatabase: true")
+		return
+	}
+	fmt.Println("inDatabase: false")
+	if ctx.Bool(fixFlag.Name) {
+		if err = addSwapEvent(swapTxType, tokenCfg, mgoSwapEvent); err != nil {
+			fmt.Printf("fix failed: %v\n", err)
+		} else {
+			fmt.Println("fixed: true")
+		}
+	}
+}
@@ -1,49 +1,67 @@
 package scanner
 
 import (
-	"context"
+	"fmt"
 	"math"
 	"math/big"
 	"strings"
 
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 
-	"github.com/gaozhengxin/bridgeAccounting/params"
 	"github.com/gaozhengxin/bridgeAccounting/mongodb"
 )
 
 func convertToMgoSwapEvent(swapEvent *SwapEvent, decimal int) *mongodb.SwapEvent {
-	return &mongodb.SwapEvent{
-		TxHash: strings.ToLower(swapEvent.TxHash.String()),
-		BlockTime: swapEvent.BlockTime,
+	var refTxHash string
+	if swapEvent.RefTxHash != (common.Hash{}) {
+		refTxHash = strings.ToLower(swapEvent.RefTxHash.String())
+	}
+	txHash := strings.ToLower(swapEvent.TxHash.String())
+	if swapEvent.Index != nil {
+		txHash = swapEventKey(txHash, *swapEvent.Index)
+	}
+	event := &mongodb.SwapEvent{
+		TxHash:      txHash,
+		BlockTime:   swapEvent.BlockTime,
 		BlockNumber: swapEvent.BlockNumber.Int64(),
-		Amount: swapEvent.Amount.String(),
-		FAmount: toFloat(swapEvent.Amount, decimal),
-		User: strings.ToLower(swapEvent.User.String()),
+		Amount:      swapEvent.Amount.String(),
+		Decimals:    decimal,
+		FAmount:     toFloat(swapEvent.Amount, decimal),
+		User:        strings.ToLower(swapEvent.User.String()),
+		Bind:        swapEvent.Bind,
+		RefTxHash:   refTxHash,
+		FromChainID: bigIntString(swapEvent.FromChainID),
+		ToChainID:   bigIntString(swapEvent.ToChainID),
+		MPCAddress:  strings.ToLower(swapEvent.MPCAddress),
+	}
+	if swapEvent.GasPrice != nil {
+		txFee := new(big.Int).Mul(new(big.Int).SetUint64(swapEvent.GasUsed), swapEvent.GasPrice)
+		event.GasUsed = swapEvent.GasUsed
+		event.GasPrice = swapEvent.GasPrice.String()
+		event.TxFee = txFee.String()
+		event.FTxFee = toFloat(txFee, nativeDecimals)
 	}
+	return event
 }
 
-func (scanner *ethSwapScanner) cachedDecimal(tokenCfg *params.TokenConfig) int {
-	if tokenCfg.Decimal != 0 {
-		return tokenCfg.Decimal
-	}
-	if tokenCfg.TokenAddress == "" {
-		// native
-		return 18
-	}
-	methodDecimal := common.FromHex("0x313ce567")
-	msg := ethereum.CallMsg {
-		Data: methodDecimal[:],
+// swap event is keyed by tx hash and index if the tx has several swaps of the token, the router swap is always keyed with its log index
+func swapEventKey(txHash string, index uint) string {
+	return fmt.Sprintf("%v:%v", txHash, index)
+}
+
+// tx hash of the swap event key
+func swapEventTxHash(key string) string {
+	if pos := strings.IndexByte(key, ':'); pos >= 0 {
+		return key[:pos]
 	}
-	*msg.To = common.HexToAddress(tokenCfg.TokenAddress)
-	bs, err := scanner.client.CallContract(context.Background(), msg, nil)
-	if err != nil {
-		return 0
+	return key
+}
+
+func bigIntString(value *big.Int) string {
+	if value == nil {
+		return ""
 	}
-	decimal := new(big.Int).SetBytes(bs[0:32]).Uint64()
-	tokenCfg.Decimal = int(decimal)
-	return int(decimal)
+	return value.String()
 }
 
 func toFloat(bigint *big.Int, decimal int) float64 {
@@ -53,4 +71,4 @@ func toFloat(bigint *big.Int, decimal int) float64 {
 	rem := new(big.Int)
 	quo, rem = quo.DivMod(bigint, divider, rem)
 	return float64(quo.Int64()) + float64(rem.Int64())/math.Pow10(decimal)
-}
\ No newline at end of file
+}
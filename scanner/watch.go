@@ -0,0 +1,140 @@
+package scanner
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/gaozhengxin/bridgeAccounting/mongodb"
+	"github.com/gaozhengxin/bridgeAccounting/params"
+)
+
+// erc20 transfer in tx logs
+type tokenTransferLog struct {
+	index  uint
+	token  common.Address
+	from   common.Address
+	to     common.Address
+	amount *big.Int
+}
+
+// the mpc addresses of chain and their tokens, which are the deposit and redeem addresses of all heights.
+// they are the watched addresses in address watch mode.
+func (scanner *ethSwapScanner) getMPCAddresses() map[common.Address][]*params.TokenConfig {
+	mpcAddresses := make(map[common.Address][]*params.TokenConfig)
+	for _, tokenCfg := range scanner.getTokenConfigs() {
+		for _, address := range tokenCfg.GetMPCAddresses() {
+			mpc := common.HexToAddress(address)
+			mpcAddresses[mpc] = append(mpcAddresses[mpc], tokenCfg)
+		}
+	}
+	return mpcAddresses
+}
+
+// record the tx sent from or to the watched addresses in address watch mode,
+// and the tx transfers tokens from or to them if its receipt is scanned.
+// return error if the receipt of watched tx can not be got or the record fails.
+func (scanner *ethSwapScanner) watchTransaction(header *types.Header, tx *types.Transaction, receipt *types.Receipt, watched map[common.Address][]*params.TokenConfig) error {
+	if len(watched) == 0 {
+		return nil
+	}
+	txFrom, err := types.Sender(types.LatestSignerForChainID(scanner.chainId), tx)
+	if err != nil {
+		return nil
+	}
+	involved := make(map[common.Address]struct{})
+	if _, exist := watched[txFrom]; exist {
+		involved[txFrom] = struct{}{}
+	}
+	if tx.To() != nil {
+		if _, exist := watched[*tx.To()]; exist {
+			involved[*tx.To()] = struct{}{}
+		}
+	}
+	if len(involved) != 0 && receipt == nil {
+		if receipt, err = scanner.getBlockTxReceipt(tx, header); err != nil {
+			return err
+		}
+	}
+	transfers := parseTokenTransferLogs(receipt)
+	for _, transfer := range transfers {
+		if _, exist := watched[transfer.from]; exist {
+			involved[transfer.from] = struct{}{}
+		}
+		if _, exist := watched[transfer.to]; exist {
+			involved[transfer.to] = struct{}{}
+		}
+	}
+	if len(involved) == 0 || scanner.dryRun {
+		return nil
+	}
+
+	for address := range involved {
+		addressTx := newAddressTx(scanner.chain, address, txFrom, tx, receipt, header)
+		for _, transfer := range transfers {
+			if transfer.from != address && transfer.to != address {
+				continue
+			}
+			addressTx.TokenTransfers = append(addressTx.TokenTransfers, &mongodb.TokenTransfer{
+				Token:  strings.ToLower(transfer.token.Hex()),
+				From:   strings.ToLower(transfer.from.Hex()),
+				To:     strings.ToLower(transfer.to.Hex()),
+				Amount: transfer.amount.String(),
+			})
+		}
+		if err = dbAPI.SetAddressTx(addressTx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newAddressTx(chain string, address, txFrom common.Address, tx *types.Transaction, receipt *types.Receipt, header *types.Header) *mongodb.AddressTx {
+	txHash := strings.ToLower(tx.Hash().Hex())
+	addressTx := &mongodb.AddressTx{
+		Key:         chain + ":" + txHash + ":" + strings.ToLower(address.Hex()),
+		Chain:       chain,
+		Address:     strings.ToLower(address.Hex()),
+		TxHash:      txHash,
+		BlockNumber: header.Number.Int64(),
+		BlockTime:   int64(header.Time),
+		From:        strings.ToLower(txFrom.Hex()),
+		Value:       tx.Value().String(),
+	}
+	if tx.To() != nil {
+		addressTx.To = strings.ToLower(tx.To().Hex())
+		if len(tx.Data()) >= 4 {
+			addressTx.Selector = hexutil.Encode(tx.Data()[:4])
+		}
+	}
+	if receipt != nil {
+		addressTx.Status = mongodb.AddressTxFailed
+		if receipt.Status == types.ReceiptStatusSuccessful {
+			addressTx.Status = mongodb.AddressTxSuccess
+		}
+	}
+	return addressTx
+}
+
+// parse erc20 transfer logs, the erc721 transfers with indexed token ID are ignored
+func parseTokenTransferLogs(receipt *types.Receipt) (transfers []*tokenTransferLog) {
+	if receipt == nil {
+		return nil
+	}
+	for _, rlog := range receipt.Logs {
+		if rlog.Removed || len(rlog.Topics) != 3 || rlog.Topics[0] != transferLogTopic || len(rlog.Data) != 32 {
+			continue
+		}
+		transfers = append(transfers, &tokenTransferLog{
+			index:  rlog.Index,
+			token:  rlog.Address,
+			from:   common.BytesToAddress(rlog.Topics[1][:]),
+			to:     common.BytesToAddress(rlog.Topics[2][:]),
+			amount: new(big.Int).SetBytes(rlog.Data),
+		})
+	}
+	return transfers
+}